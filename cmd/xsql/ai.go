@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/zx06/xsql/internal/ai"
+	"github.com/zx06/xsql/internal/app"
 	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/secret"
@@ -79,7 +80,12 @@ func NewAICommand() *cobra.Command {
 			aiClient := ai.NewClient(resolved.AI, nil)
 			aiService := ai.NewService(resolved.AI, aiClient)
 
+			// Status events are not logged: stderr output would corrupt the TUI.
+			tunnels := app.NewSSHTunnels(nil)
+			defer func() { _ = tunnels.Close() }()
+
 			m := tui.NewModel(opts, resolved, aiService, prompt, unsafeAllowWrite)
+			m.SetSSHTunnels(tunnels)
 			p := newAIProgramFunc(m)
 			if _, err := p.Run(); err != nil {
 				return fmt.Errorf("error running TUI: %w", err)
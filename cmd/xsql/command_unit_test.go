@@ -12,11 +12,14 @@ import (
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"github.com/zalando/go-keyring"
 
 	"github.com/zx06/xsql/internal/app"
 	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/output"
+	"github.com/zx06/xsql/internal/secret"
 )
 
 func TestParseOutputFormat(t *testing.T) {
@@ -251,6 +254,123 @@ func TestRunQuery_InvalidFormat(t *testing.T) {
 	}
 }
 
+func TestRunQuery_InvalidTableLayout(t *testing.T) {
+	GlobalConfig.Resolved.Profile = configProfile("mysql")
+	GlobalConfig.FormatStr = "table"
+
+	var out bytes.Buffer
+	w := output.New(&out, &bytes.Buffer{})
+	err := runQuery([]string{"select 1"}, &QueryFlags{TableLayout: "diagonal"}, &w)
+	if err == nil {
+		t.Fatal("expected error for invalid table layout")
+	}
+	if xe, ok := errors.As(err); !ok || xe.Code != errors.CodeCfgInvalid {
+		t.Fatalf("expected CodeCfgInvalid, got %v", err)
+	}
+}
+
+func TestParseTableLayout(t *testing.T) {
+	if l, err := parseTableLayout(""); err != nil || l != output.TableLayoutAuto {
+		t.Fatalf("empty layout should default to auto, got %q, %v", l, err)
+	}
+	if l, err := parseTableLayout("vertical"); err != nil || l != output.TableLayoutVertical {
+		t.Fatalf("unexpected result: %q, %v", l, err)
+	}
+	if _, err := parseTableLayout("diagonal"); err == nil {
+		t.Fatal("expected error for invalid layout")
+	}
+}
+
+func TestResolveOutputTemplate(t *testing.T) {
+	if tmpl, xe := resolveOutputTemplate("", ""); xe != nil || tmpl != "" {
+		t.Fatalf("expected empty template, got %q, %v", tmpl, xe)
+	}
+	if tmpl, xe := resolveOutputTemplate("{{.rows}}", ""); xe != nil || tmpl != "{{.rows}}" {
+		t.Fatalf("unexpected result: %q, %v", tmpl, xe)
+	}
+
+	path := filepath.Join(t.TempDir(), "out.tmpl")
+	if err := os.WriteFile(path, []byte("{{len .rows}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, xe := resolveOutputTemplate("", path); xe != nil || tmpl != "{{len .rows}}" {
+		t.Fatalf("unexpected result from file: %q, %v", tmpl, xe)
+	}
+
+	for name, args := range map[string][2]string{
+		"both":         {"{{.}}", path},
+		"missing_file": {"", filepath.Join(t.TempDir(), "nope.tmpl")},
+		"parse_error":  {"{{.rows", ""},
+	} {
+		if _, xe := resolveOutputTemplate(args[0], args[1]); xe == nil || xe.Code != errors.CodeCfgInvalid {
+			t.Errorf("%s: expected CodeCfgInvalid, got %v", name, xe)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"":      0,
+		"512":   512,
+		"10KB":  10 << 10,
+		"100mb": 100 << 20,
+		"1GiB":  1 << 30,
+		"2 M":   2 << 20,
+		"64B":   64,
+	}
+	for in, want := range tests {
+		got, err := parseByteSize(in)
+		if err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"abc", "-1MB", "1TB"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q) should fail", in)
+		}
+	}
+}
+
+func TestParseQueryOut(t *testing.T) {
+	format, opts, err := parseQueryOut(&QueryFlags{Out: "rows.json.gz", SplitRows: 1000, SplitSize: "10MB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "json" || opts.SplitRows != 1000 || opts.SplitBytes != 10<<20 {
+		t.Fatalf("unexpected result: %s %+v", format, opts)
+	}
+
+	for name, flags := range map[string]*QueryFlags{
+		"split_without_out": {SplitRows: 10},
+		"unknown_extension": {Out: "rows.txt"},
+		"bad_compression":   {Out: "rows.csv", Compress: "lz4"},
+		"bad_size":          {Out: "rows.csv", SplitSize: "huge"},
+		"negative_rows":     {Out: "rows.csv", SplitRows: -1},
+	} {
+		if _, _, err := parseQueryOut(flags); err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if xe, ok := errors.As(err); !ok || xe.Code != errors.CodeCfgInvalid {
+			t.Errorf("%s: expected CodeCfgInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestParseProjection(t *testing.T) {
+	p, err := parseProjection(&QueryFlags{Columns: []string{"id"}, Rename: []string{"id=user_id"}, Select: []string{"meta.a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Columns) != 1 || p.Rename["id"] != "user_id" || len(p.Select) != 1 {
+		t.Fatalf("unexpected projection: %+v", p)
+	}
+	if _, err := parseProjection(&QueryFlags{Rename: []string{"id"}}); err == nil {
+		t.Fatal("expected error for invalid rename")
+	}
+	if _, err := parseProjection(&QueryFlags{Select: []string{"meta[oops]"}}); err == nil {
+		t.Fatal("expected error for invalid select")
+	}
+}
+
 func TestRunSchemaDump_MissingDB(t *testing.T) {
 	GlobalConfig.Resolved.Profile = configProfile("")
 	GlobalConfig.FormatStr = "json"
@@ -1193,3 +1313,364 @@ func TestNewProxyCommand(t *testing.T) {
 		t.Fatalf("expected 'proxy [flags]', got %s", cmd.Use)
 	}
 }
+
+func TestConfigSourcesCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "xsql.yaml")
+	if err := os.WriteFile(path, []byte("profiles:\n  dev:\n    db: mysql\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	GlobalConfig.ConfigStr = path
+	GlobalConfig.FormatStr = "json"
+	defer func() { GlobalConfig.ConfigStr = "" }()
+
+	var out bytes.Buffer
+	w := output.New(&out, &bytes.Buffer{})
+	cmd := newConfigSourcesCommand(&w)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config sources failed: %v", err)
+	}
+
+	var resp struct {
+		Data struct {
+			Files    []string `json:"files"`
+			Profiles []struct {
+				Name   string `json:"name"`
+				Source string `json:"source"`
+			} `json:"profiles"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, out.String())
+	}
+	if len(resp.Data.Files) != 1 || resp.Data.Files[0] != path {
+		t.Errorf("unexpected files: %v", resp.Data.Files)
+	}
+	if len(resp.Data.Profiles) != 1 || resp.Data.Profiles[0].Name != "dev" || resp.Data.Profiles[0].Source != path {
+		t.Errorf("unexpected profiles: %+v", resp.Data.Profiles)
+	}
+}
+
+func TestConfigValidateCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "xsql.yaml")
+	if err := os.WriteFile(path, []byte("profiles:\n  dev:\n    db: mysql\n    host: localhost\n    colour: blue\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	GlobalConfig.ConfigStr = path
+	GlobalConfig.FormatStr = "json"
+	defer func() { GlobalConfig.ConfigStr = "" }()
+
+	var out bytes.Buffer
+	w := output.New(&out, &bytes.Buffer{})
+	cmd := newConfigValidateCommand(&w)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("warnings alone should not fail validation: %v", err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte(`"unknown_key"`)) {
+		t.Errorf("expected unknown_key warning, got %s", out.String())
+	}
+
+	cmd = newConfigValidateCommand(&w)
+	cmd.SetArgs([]string{"--strict"})
+	err := cmd.Execute()
+	if xe, ok := errors.As(err); !ok || xe.Code != errors.CodeCfgInvalid {
+		t.Fatalf("expected CfgInvalid with --strict, got %v", err)
+	}
+}
+
+func TestConfigValidateCommand_ReportsLoadErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XSQL_FORMAT", "")
+	userPath := filepath.Join(home, ".config", "xsql", "xsql.yaml")
+	if err := os.MkdirAll(filepath.Dir(userPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(userPath, []byte("ssh_proxies:\n  bastion:\n    port: 22\nprofiles:\n  a:\n    extends: b\n  b:\n    extends: a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	workDir := t.TempDir()
+	projectPath := filepath.Join(workDir, "xsql.yaml")
+	if err := os.WriteFile(projectPath, []byte("profiles:\n  default:\n    db: pg\n    host: ${BROKEN\n    ssh_proxy: nope\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	origDir, _ := os.Getwd()
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(origDir) }()
+
+	oldGlobalConfig := GlobalConfig
+	GlobalConfig = &Config{}
+	defer func() { GlobalConfig = oldGlobalConfig }()
+
+	var out bytes.Buffer
+	w := output.New(&out, &bytes.Buffer{})
+	root := NewRootCommand()
+	root.AddCommand(NewConfigCommand(&w))
+	root.SetArgs([]string{"config", "validate", "-f", "json"})
+	err := root.Execute()
+	xe, ok := errors.As(err)
+	if !ok || xe.Code != errors.CodeCfgInvalid || xe.Message != "config validation failed" {
+		t.Fatalf("expected the issue list, got %v", err)
+	}
+	issues, _ := xe.Details["issues"].([]config.Issue)
+	want := map[string]string{
+		"extends_cycle":         userPath,
+		"missing_host":          userPath,
+		"invalid_env_reference": projectPath,
+		"unknown_ssh_proxy":     projectPath,
+	}
+	for code, file := range want {
+		found := false
+		for _, is := range issues {
+			found = found || (is.Code == code && is.File == file)
+		}
+		if !found {
+			t.Errorf("missing %s in %s; got %+v", code, file, issues)
+		}
+	}
+}
+
+func TestConfigMigrateCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "xsql.yaml")
+	if err := os.WriteFile(path, []byte("# mine\nprofiles:\n  dev:\n    db: mysql\n    host: localhost\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	GlobalConfig.ConfigStr = path
+	GlobalConfig.FormatStr = "json"
+	defer func() { GlobalConfig.ConfigStr = "" }()
+
+	var out bytes.Buffer
+	w := output.New(&out, &bytes.Buffer{})
+	cmd := newConfigMigrateCommand(&w)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte(`"written":true`)) {
+		t.Errorf("expected the file to be written, got %s", out.String())
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("# mine\nversion: 1\n")) {
+		t.Errorf("unexpected migrated file:\n%s", b)
+	}
+}
+
+func TestSecretCommands(t *testing.T) {
+	keyring.MockInit()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "xsql.yaml")
+	cfg := "profiles:\n  dev:\n    db: mysql\n    password: keyring:dev/pw\n  prod:\n    db: pg\n    password: keyring:dev/pw\nai:\n  api_key: keyring:ai/key\n"
+	if err := os.WriteFile(path, []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+	GlobalConfig.ConfigStr = path
+	GlobalConfig.FormatStr = "json"
+	defer func() { GlobalConfig.ConfigStr = "" }()
+
+	run := func(stdin string, args ...string) (map[string]any, error) {
+		var out bytes.Buffer
+		w := output.New(&out, &bytes.Buffer{})
+		cmd := NewSecretCommand(&w)
+		cmd.SetArgs(args)
+		cmd.SetIn(bytes.NewBufferString(stdin))
+		if err := cmd.Execute(); err != nil {
+			return nil, err
+		}
+		var env struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &env); err != nil {
+			t.Fatalf("invalid output %q: %v", out.String(), err)
+		}
+		return env.Data, nil
+	}
+
+	data, err := run("s3cret\n", "set", "dev/pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["ref"] != "keyring:dev/pw" {
+		t.Errorf("unexpected set output %v", data)
+	}
+	data, err = run("", "get", "keyring:dev/pw")
+	if err != nil || data["value"] != "s3cret" {
+		t.Fatalf("get = %v, %v", data, err)
+	}
+
+	data, err = run("", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets, _ := data["secrets"].([]any)
+	if len(secrets) != 2 {
+		t.Fatalf("expected 2 accounts, got %v", data["secrets"])
+	}
+	ai, dev := secrets[0].(map[string]any), secrets[1].(map[string]any)
+	if ai["account"] != "ai/key" || ai["stored"] != false {
+		t.Errorf("unexpected ai entry %v", ai)
+	}
+	if dev["account"] != "dev/pw" || dev["stored"] != true || len(dev["used_by"].([]any)) != 2 {
+		t.Errorf("unexpected dev entry %v", dev)
+	}
+
+	if _, err := run("", "delete", "dev/pw"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run("", "get", "dev/pw"); err == nil {
+		t.Error("expected error after delete")
+	}
+	if _, err := run("\n", "set", "dev/pw"); err == nil {
+		t.Error("expected error for empty secret")
+	}
+}
+
+func TestProfileSetPasswordCommand(t *testing.T) {
+	keyring.MockInit()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "xsql.yaml")
+	if err := os.WriteFile(path, []byte("profiles:\n  dev:\n    db: mysql # local\n    password: plain\n    allow_plaintext: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	GlobalConfig.ConfigStr = path
+	GlobalConfig.FormatStr = "json"
+	defer func() { GlobalConfig.ConfigStr = "" }()
+
+	var out bytes.Buffer
+	w := output.New(&out, &bytes.Buffer{})
+	cmd := newProfileSetPasswordCommand(&w)
+	cmd.SetArgs([]string{"dev"})
+	cmd.SetIn(bytes.NewBufferString("n3w\n"))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("password: keyring:dev/password")) || !bytes.Contains(b, []byte("# local")) {
+		t.Errorf("unexpected config after set-password:\n%s", b)
+	}
+	val, xe := secret.Resolve("keyring:dev/password", secret.Options{})
+	if xe != nil || val != "n3w" {
+		t.Errorf("keyring value = %q, %v", val, xe)
+	}
+
+	cmd = newProfileSetPasswordCommand(&w)
+	cmd.SetArgs([]string{"missing"})
+	cmd.SetIn(bytes.NewBufferString("x\n"))
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestProfileSetPasswordCommand_Layered(t *testing.T) {
+	keyring.MockInit()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	userPath := filepath.Join(home, ".config", "xsql", "xsql.yaml")
+	if err := os.MkdirAll(filepath.Dir(userPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(userPath, []byte("profiles:\n  prod:\n    db: pg\n    host: db.internal\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	workDir := t.TempDir()
+	projectPath := filepath.Join(workDir, "xsql.yaml")
+	project := []byte("profiles:\n  dev:\n    db: mysql\n")
+	if err := os.WriteFile(projectPath, project, 0600); err != nil {
+		t.Fatal(err)
+	}
+	origDir, _ := os.Getwd()
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(origDir) }()
+
+	GlobalConfig.ConfigStr = ""
+	GlobalConfig.FormatStr = "json"
+
+	var out bytes.Buffer
+	w := output.New(&out, &bytes.Buffer{})
+	cmd := newProfileSetPasswordCommand(&w)
+	cmd.SetArgs([]string{"prod"})
+	cmd.SetIn(bytes.NewBufferString("s3cret\n"))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(userPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("password: keyring:prod/password")) || !bytes.Contains(b, []byte("host: db.internal")) {
+		t.Errorf("user config not updated:\n%s", b)
+	}
+	if b, _ := os.ReadFile(projectPath); !bytes.Equal(b, project) {
+		t.Errorf("project config changed:\n%s", b)
+	}
+	if !bytes.Contains(out.Bytes(), []byte(`"config_path":"`+userPath+`"`)) {
+		t.Errorf("output = %s", out.String())
+	}
+}
+
+func TestConfigEncryptionCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(secret.KeyFileEnv, filepath.Join(dir, "keys", "xsql.key"))
+	path := filepath.Join(dir, "xsql.yaml")
+	if err := os.WriteFile(path, []byte("profiles:\n  dev:\n    db: mysql\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	GlobalConfig.ConfigStr = path
+	GlobalConfig.FormatStr = "json"
+	defer func() { GlobalConfig.ConfigStr = "" }()
+
+	run := func(newCmd func(*output.Writer) *cobra.Command, stdin string, args ...string) (string, error) {
+		var out bytes.Buffer
+		w := output.New(&out, &bytes.Buffer{})
+		cmd := newCmd(&w)
+		cmd.SetArgs(args)
+		cmd.SetIn(bytes.NewBufferString(stdin))
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	if _, err := run(newConfigKeygenCommand, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(newConfigKeygenCommand, ""); err == nil {
+		t.Error("expected keygen to refuse replacing the key without --force")
+	}
+
+	out, err := run(newConfigEncryptValueCommand, "s3cret\n")
+	if err != nil || !bytes.Contains([]byte(out), []byte(`"value":"ENC[AES256_GCM,`)) {
+		t.Fatalf("encrypt-value = %s, %v", out, err)
+	}
+
+	if _, err := run(newConfigEncryptCommand, ""); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(b, []byte("ENC[")) {
+		t.Fatalf("expected encrypted file, got %s", b)
+	}
+	if _, err := run(newConfigDecryptCommand, ""); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = os.ReadFile(path)
+	if !bytes.HasPrefix(b, []byte("profiles:")) {
+		t.Errorf("expected decrypted file, got %s", b)
+	}
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
 	"github.com/spf13/cobra"
 
 	"github.com/zx06/xsql/internal/config"
+	"github.com/zx06/xsql/internal/db"
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/output"
+	"github.com/zx06/xsql/internal/secret"
 )
 
 // NewConfigCommand creates the config command group
@@ -17,6 +24,14 @@ func NewConfigCommand(w *output.Writer) *cobra.Command {
 
 	configCmd.AddCommand(newConfigInitCommand(w))
 	configCmd.AddCommand(newConfigSetCommand(w))
+	configCmd.AddCommand(newConfigSourcesCommand(w))
+	configCmd.AddCommand(newConfigValidateCommand(w))
+	configCmd.AddCommand(newConfigMigrateCommand(w))
+	configCmd.AddCommand(newConfigKeygenCommand(w))
+	configCmd.AddCommand(newConfigEncryptCommand(w))
+	configCmd.AddCommand(newConfigDecryptCommand(w))
+	configCmd.AddCommand(newConfigEncryptValueCommand(w))
+	configCmd.AddCommand(newConfigSchemaCommand(w))
 
 	return configCmd
 }
@@ -64,9 +79,13 @@ func newConfigSetCommand(w *output.Writer) *cobra.Command {
 				return err
 			}
 
-			cfgPath := config.FindConfigPath(config.Options{
+			// Entries are edited in the config layer that defines them.
+			cfgPath, xe := config.FindKeyConfigPath(config.Options{
 				ConfigPath: GlobalConfig.ConfigStr,
-			})
+			}, key)
+			if xe != nil {
+				return xe
+			}
 			if cfgPath == "" {
 				return errors.New(errors.CodeCfgNotFound, "no config file found; run 'xsql config init' first", nil)
 			}
@@ -83,3 +102,311 @@ func newConfigSetCommand(w *output.Writer) *cobra.Command {
 		},
 	}
 }
+
+// newConfigSourcesCommand creates the config sources command
+func newConfigSourcesCommand(w *output.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "sources",
+		Short: "Show loaded config files and which file each profile came from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			cfg, cfgPath, xe := config.LoadConfig(config.Options{
+				ConfigPath: GlobalConfig.ConfigStr,
+			})
+			if xe != nil {
+				return xe
+			}
+
+			names := make([]string, 0, len(cfg.Profiles))
+			for name := range cfg.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			profiles := make([]map[string]any, 0, len(names))
+			for _, name := range names {
+				item := map[string]any{"name": name}
+				if files := cfg.ProfileFiles[name]; len(files) > 0 {
+					item["source"] = files[len(files)-1]
+					item["files"] = files
+				}
+				profiles = append(profiles, item)
+			}
+
+			files := cfg.Files
+			if files == nil {
+				files = []string{}
+			}
+			return w.WriteOK(format, map[string]any{
+				"config_path": cfgPath,
+				"files":       files,
+				"profiles":    profiles,
+			})
+		},
+	}
+}
+
+// newConfigValidateCommand creates the config validate command
+func newConfigValidateCommand(w *output.Writer) *cobra.Command {
+	var strict bool
+
+	cmd := &cobra.Command{
+		Use:         "validate",
+		Short:       "Check the configuration and report all problems",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{annotationReportsConfigErrors: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			// Problems that stop other commands from loading the config are
+			// reported as issues alongside the rest.
+			cfg, cfgPath, issues := config.LoadConfigIssues(config.Options{
+				ConfigPath: GlobalConfig.ConfigStr,
+			})
+			if len(cfg.Files) == 0 && len(issues) == 0 {
+				return errors.New(errors.CodeCfgNotFound, "no config file found; run 'xsql config init' first", nil)
+			}
+
+			issues = append(issues, config.Validate(cfg, config.ValidateOptions{
+				DBTypes:     db.RegisteredNames(),
+				CheckParams: db.ValidateParams,
+			})...)
+			config.SortIssues(issues)
+			errCount, warnCount := 0, 0
+			for _, is := range issues {
+				if is.Severity == config.SeverityError {
+					errCount++
+				} else {
+					warnCount++
+				}
+			}
+			if issues == nil {
+				issues = []config.Issue{}
+			}
+
+			if errCount > 0 || (strict && warnCount > 0) {
+				return errors.New(errors.CodeCfgInvalid, "config validation failed", map[string]any{
+					"config_path": cfgPath,
+					"files":       cfg.Files,
+					"errors":      errCount,
+					"warnings":    warnCount,
+					"issues":      issues,
+				})
+			}
+			return w.WriteOK(format, map[string]any{
+				"config_path": cfgPath,
+				"files":       cfg.Files,
+				"valid":       true,
+				"errors":      errCount,
+				"warnings":    warnCount,
+				"issues":      issues,
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+
+	return cmd
+}
+
+// newConfigMigrateCommand creates the config migrate command
+func newConfigMigrateCommand(w *output.Writer) *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade the config file to the current layout version, keeping comments",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			cfgPath := config.FindConfigPath(config.Options{
+				ConfigPath: GlobalConfig.ConfigStr,
+			})
+			result, xe := config.MigrateConfig(cfgPath, dryRun)
+			if xe != nil {
+				return xe
+			}
+			return w.WriteOK(format, result)
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report the changes without writing the file")
+
+	return cmd
+}
+
+// newConfigKeygenCommand creates the config keygen command
+func newConfigKeygenCommand(w *output.Writer) *cobra.Command {
+	var useKeyring, force bool
+
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Create the key used to encrypt config files and values",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			key, xe := secret.GenerateKey()
+			if xe != nil {
+				return xe
+			}
+
+			// Replacing a key makes everything encrypted with it unreadable.
+			if useKeyring {
+				if !force {
+					if _, xe := secret.Resolve(secret.KeyringRef(secret.KeyringKeyAccount), secret.Options{}); xe == nil {
+						return errors.New(errors.CodeCfgInvalid, "a config key already exists in the keyring; use --force to replace it",
+							map[string]any{"account": secret.KeyringKeyAccount})
+					}
+				}
+				if xe := secret.SetKeyring(nil, secret.KeyringKeyAccount, key); xe != nil {
+					return xe
+				}
+				return w.WriteOK(format, map[string]any{"keyring_account": secret.KeyringKeyAccount})
+			}
+
+			path := os.Getenv(secret.KeyFileEnv)
+			if path == "" {
+				path = secret.DefaultKeyPath()
+			}
+			if path == "" {
+				return errors.New(errors.CodeCfgInvalid, "cannot determine the key file path; set "+secret.KeyFileEnv, nil)
+			}
+			if _, err := os.Stat(path); err == nil && !force {
+				return errors.New(errors.CodeCfgInvalid, "key file already exists; use --force to replace it", map[string]any{"path": path})
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				return errors.Wrap(errors.CodeInternal, "failed to create key directory", map[string]any{"path": path}, err)
+			}
+			if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+				return errors.Wrap(errors.CodeInternal, "failed to write key file", map[string]any{"path": path}, err)
+			}
+			return w.WriteOK(format, map[string]any{"key_file": path})
+		},
+	}
+
+	cmd.Flags().BoolVar(&useKeyring, "keyring", false, "Store the key in the OS keyring instead of a key file")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing key")
+
+	return cmd
+}
+
+// newConfigEncryptCommand creates the config encrypt command
+func newConfigEncryptCommand(w *output.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt the whole config file in place",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigCrypt(w, config.EncryptConfigFile, true)
+		},
+	}
+}
+
+// newConfigDecryptCommand creates the config decrypt command
+func newConfigDecryptCommand(w *output.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "decrypt",
+		Short: "Decrypt a whole-file encrypted config file in place",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigCrypt(w, config.DecryptConfigFile, false)
+		},
+	}
+}
+
+func runConfigCrypt(w *output.Writer, fn func(path string) *errors.XError, encrypted bool) error {
+	format, err := parseOutputFormat(GlobalConfig.FormatStr)
+	if err != nil {
+		return err
+	}
+
+	cfgPath := config.FindConfigPath(config.Options{
+		ConfigPath: GlobalConfig.ConfigStr,
+	})
+	if cfgPath == "" {
+		return errors.New(errors.CodeCfgNotFound, "no config file found; run 'xsql config init' first", nil)
+	}
+	if xe := fn(cfgPath); xe != nil {
+		return xe
+	}
+	return w.WriteOK(format, map[string]any{
+		"config_path": cfgPath,
+		"encrypted":   encrypted,
+	})
+}
+
+// newConfigEncryptValueCommand creates the config encrypt-value command
+func newConfigEncryptValueCommand(w *output.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "encrypt-value",
+		Short: "Encrypt a value read from a hidden prompt or stdin, for use in the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			value, xe := readSecretInput(cmd, "Value to encrypt: ", true)
+			if xe != nil {
+				return xe
+			}
+			env, xe := config.EncryptValue(value)
+			if xe != nil {
+				return xe
+			}
+			return w.WriteOK(format, map[string]any{"value": env})
+		},
+	}
+}
+
+// newConfigSchemaCommand creates the config schema command
+func newConfigSchemaCommand(w *output.Writer) *cobra.Command {
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema for xsql.yaml",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			schema := config.JSONSchema(config.SchemaOptions{DBTypes: db.RegisteredNames()})
+			if out == "" {
+				return w.WriteOK(format, schema)
+			}
+
+			// Editors expect the bare schema, without the output envelope.
+			b, err := json.MarshalIndent(schema, "", "  ")
+			if err != nil {
+				return errors.Wrap(errors.CodeInternal, "failed to encode schema", nil, err)
+			}
+			if err := os.WriteFile(out, append(b, '\n'), 0o644); err != nil {
+				return errors.Wrap(errors.CodeInternal, "failed to write schema file", map[string]any{"path": out}, err)
+			}
+			return w.WriteOK(format, map[string]any{"path": out})
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "Write the bare schema to this file instead of stdout")
+
+	return cmd
+}
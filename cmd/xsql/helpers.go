@@ -1,12 +1,16 @@
 package main
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"golang.org/x/term"
 
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/output"
+	"github.com/zx06/xsql/internal/ssh"
 )
 
 // parseOutputFormat parses and validates the output format string
@@ -18,6 +22,82 @@ func parseOutputFormat(s string) (output.Format, error) {
 	return resolveAuto(f), nil
 }
 
+// parseTableLayout parses and validates the table layout string
+func parseTableLayout(s string) (output.TableLayout, error) {
+	l := output.TableLayout(s)
+	if l == "" {
+		return output.TableLayoutAuto, nil
+	}
+	if !output.IsValidTableLayout(l) {
+		return "", errors.New(errors.CodeCfgInvalid, "invalid table layout", map[string]any{"table_layout": s})
+	}
+	return l, nil
+}
+
+// terminalWidth returns the stdout terminal width, or 0 when stdout is not a terminal
+func terminalWidth() int {
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return 0
+	}
+	width, _, err := term.GetSize(fd)
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// parseByteSize parses sizes such as "512", "10KB", "100MB" or "1GiB" (1KB = 1024 bytes).
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	units := []struct {
+		suffix string
+		mult   int64
+	}{
+		{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+		{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+		{"B", 1},
+	}
+	num, mult := strings.ToUpper(s), int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New(errors.CodeCfgInvalid, "invalid size", map[string]any{"size": s})
+	}
+	return n * mult, nil
+}
+
+// resolveOutputTemplate returns the template source from --template or --template-file
+// and validates that it parses.
+func resolveOutputTemplate(text, file string) (string, *errors.XError) {
+	if text != "" && file != "" {
+		return "", errors.New(errors.CodeCfgInvalid, "--template and --template-file are mutually exclusive", nil)
+	}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrap(errors.CodeCfgInvalid, "failed to read template file", map[string]any{"path": file}, err)
+		}
+		text = string(b)
+	}
+	if text == "" {
+		return "", nil
+	}
+	if _, xe := output.ParseTemplate(text); xe != nil {
+		return "", xe
+	}
+	return text, nil
+}
+
 // resolveFormatForError resolves the format for error output
 func resolveFormatForError(s string) output.Format {
 	f := output.Format(s)
@@ -46,3 +126,22 @@ func normalizeErr(err error) *errors.XError {
 	// Preserve original error message
 	return errors.Wrap(errors.CodeInternal, err.Error(), nil, err)
 }
+
+// logSSHTunnelStatus returns a status callback for app.SSHTunnels that logs
+// tunnel state changes with the given prefix.
+func logSSHTunnelStatus(prefix string) func(proxy string, ev ssh.StatusEvent) {
+	return func(proxy string, ev ssh.StatusEvent) {
+		switch ev.Type {
+		case ssh.StatusConnected:
+			log.Printf("[%s] ssh tunnel %s connected", prefix, proxy)
+		case ssh.StatusDisconnected:
+			log.Printf("[%s] ssh tunnel %s lost: %v", prefix, proxy, ev.Error)
+		case ssh.StatusReconnecting:
+			log.Printf("[%s] ssh tunnel %s reconnecting...", prefix, proxy)
+		case ssh.StatusReconnected:
+			log.Printf("[%s] ssh tunnel %s reconnected", prefix, proxy)
+		case ssh.StatusReconnectFailed:
+			log.Printf("[%s] ssh tunnel %s reconnection failed: %v", prefix, proxy, ev.Error)
+		}
+	}
+}
@@ -21,6 +21,7 @@ func run() int {
 
 	// Create root command
 	root := NewRootCommand()
+	bindOutputWriter(root, &w)
 
 	// Add subcommands
 	root.AddCommand(NewSpecCommand(&a, &w))
@@ -31,6 +32,8 @@ func run() int {
 	root.AddCommand(NewMCPCommand())
 	root.AddCommand(NewProxyCommand(&w))
 	root.AddCommand(NewConfigCommand(&w))
+	root.AddCommand(NewSecretCommand(&w))
+	root.AddCommand(NewSSHCommand(&w))
 	root.AddCommand(NewServeCommand(&w))
 	root.AddCommand(NewWebCommand(&w))
 	root.AddCommand(NewStatsCommand(&w))
@@ -13,6 +13,7 @@ import (
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 
+	"github.com/zx06/xsql/internal/app"
 	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/errors"
 	mcp_pkg "github.com/zx06/xsql/internal/mcp"
@@ -64,8 +65,12 @@ func runMCPServer(opts *mcpServerOptions) error {
 		return xe
 	}
 
+	// Queries through an ssh proxy share one reconnecting SSH connection per proxy.
+	tunnels := app.NewSSHTunnels(logSSHTunnelStatus("mcp"))
+	defer func() { _ = tunnels.Close() }()
+
 	// Create MCP server using official SDK
-	server, err := mcp_pkg.CreateServer(version, &cfg, GlobalConfig.Stats)
+	server, err := mcp_pkg.CreateServer(version, &cfg, GlobalConfig.Stats, tunnels)
 	if err != nil {
 		// Convert SDK error to XError if needed
 		if xe, ok := err.(*errors.XError); ok {
@@ -105,9 +110,19 @@ func runMCPServer(opts *mcpServerOptions) error {
 			}
 			return errors.Wrap(errors.CodeInternal, "failed to create streamable http handler", nil, err)
 		}
+		health, err := mcp_pkg.NewHealthHandler(tunnels, resolved.httpAuthToken)
+		if err != nil {
+			if xe, ok := err.(*errors.XError); ok {
+				return xe
+			}
+			return errors.Wrap(errors.CodeInternal, "failed to create health handler", nil, err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle(mcp_pkg.HealthPath, health)
+		mux.Handle("/", handler)
 		httpServer := &http.Server{
 			Addr:         resolved.httpAddr,
-			Handler:      handler,
+			Handler:      mux,
 			ReadTimeout:  30 * time.Second,
 			WriteTimeout: 30 * time.Second,
 			IdleTimeout:  120 * time.Second,
@@ -17,13 +17,16 @@ func NewProfileCommand(w *output.Writer) *cobra.Command {
 
 	profileCmd.AddCommand(newProfileListCommand(w))
 	profileCmd.AddCommand(newProfileShowCommand(w))
+	profileCmd.AddCommand(newProfileSetPasswordCommand(w))
 
 	return profileCmd
 }
 
 // newProfileListCommand creates the profile list command
 func newProfileListCommand(w *output.Writer) *cobra.Command {
-	return &cobra.Command{
+	var tags []string
+
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List all configured profiles",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -34,7 +37,7 @@ func newProfileListCommand(w *output.Writer) *cobra.Command {
 
 			result, xe := app.LoadProfiles(config.Options{
 				ConfigPath: GlobalConfig.ConfigStr,
-			})
+			}, tags...)
 			if xe != nil {
 				return xe
 			}
@@ -42,6 +45,10 @@ func newProfileListCommand(w *output.Writer) *cobra.Command {
 			return w.WriteOK(format, result)
 		},
 	}
+
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Only list profiles with this tag (repeatable; all must match)")
+
+	return cmd
 }
 
 // newProfileShowCommand creates the profile show command
@@ -7,7 +7,9 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/zx06/xsql/internal/app"
+	"github.com/zx06/xsql/internal/db"
 	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/export"
 	"github.com/zx06/xsql/internal/output"
 )
 
@@ -24,6 +26,15 @@ type QueryFlags struct {
 	SSHSkipHostKey   bool
 	QueryTimeout     int
 	QueryTimeoutSet  bool
+	TableLayout      string
+	Out              string
+	Compress         string
+	SplitRows        int
+	SplitSize        string
+	Columns          []string
+	ExcludeColumns   []string
+	Rename           []string
+	Select           []string
 }
 
 // NewQueryCommand creates the query command
@@ -44,6 +55,15 @@ func NewQueryCommand(w *output.Writer) *cobra.Command {
 	cmd.Flags().BoolVar(&flags.AllowPlaintext, "allow-plaintext", false, "Allow plaintext secrets in config")
 	cmd.Flags().BoolVar(&flags.SSHSkipHostKey, "ssh-skip-known-hosts-check", false, "Skip SSH known_hosts check (dangerous)")
 	cmd.Flags().IntVar(&flags.QueryTimeout, "query-timeout", 0, "Query timeout in seconds (default: 30)")
+	cmd.Flags().StringVar(&flags.TableLayout, "table-layout", string(output.TableLayoutAuto), "Table layout: auto|horizontal|vertical (auto switches to vertical when rows exceed terminal width)")
+	cmd.Flags().StringSliceVar(&flags.Columns, "columns", nil, "Only output these columns, in this order (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.ExcludeColumns, "exclude-columns", nil, "Drop these columns from the output (comma-separated)")
+	cmd.Flags().StringSliceVar(&flags.Rename, "rename", nil, "Rename output columns: old=new (repeatable)")
+	cmd.Flags().StringArrayVar(&flags.Select, "select", nil, "Extract a nested JSON value as a new column: [name=]column.path[0].key (repeatable)")
+	cmd.Flags().StringVar(&flags.Out, "out", "", "Write results to a file instead of stdout; format from extension (.csv|.json|.md|.html, optionally .gz|.zst)")
+	cmd.Flags().StringVar(&flags.Compress, "compress", "", "Compression for --out: gzip|zstd|none (default: from extension)")
+	cmd.Flags().IntVar(&flags.SplitRows, "split-rows", 0, "Split --out into files of at most N rows (writes a manifest)")
+	cmd.Flags().StringVar(&flags.SplitSize, "split-size", "", "Split --out into files of about this uncompressed size, e.g. 100MB (writes a manifest)")
 
 	return cmd
 }
@@ -55,6 +75,18 @@ func runQuery(args []string, flags *QueryFlags, w *output.Writer) error {
 	if err != nil {
 		return err
 	}
+	layout, err := parseTableLayout(flags.TableLayout)
+	if err != nil {
+		return err
+	}
+	outFormat, outOpts, err := parseQueryOut(flags)
+	if err != nil {
+		return err
+	}
+	projection, err := parseProjection(flags)
+	if err != nil {
+		return err
+	}
 
 	p := GlobalConfig.Resolved.Profile
 	timeout := app.QueryTimeout(p, flags.QueryTimeout, flags.QueryTimeoutSet, DefaultQueryTimeout)
@@ -81,6 +113,75 @@ func runQuery(args []string, flags *QueryFlags, w *output.Writer) error {
 	if xe != nil {
 		return xe
 	}
+	result, xe = result.Project(projection)
+	if xe != nil {
+		return xe
+	}
+
+	if flags.Out != "" {
+		outOpts.Meta = export.Meta{Query: sql, Profile: GlobalConfig.ProfileStr, Duration: duration}
+		manifest, xe := export.ExportFiles(result, outFormat, flags.Out, outOpts)
+		if xe != nil {
+			return xe
+		}
+		return w.WriteOK(format, manifest)
+	}
+
+	qw := *w
+	qw.TableLayout = layout
+	qw.TermWidth = terminalWidth()
+	return qw.WriteOK(format, result)
+}
 
-	return w.WriteOK(format, result)
+// parseQueryOut validates the --out related flags before the query runs.
+func parseQueryOut(flags *QueryFlags) (export.ExportFormat, export.Options, error) {
+	var opts export.Options
+	if flags.Out == "" {
+		if flags.Compress != "" || flags.SplitRows != 0 || flags.SplitSize != "" {
+			return "", opts, errors.New(errors.CodeCfgInvalid, "--compress, --split-rows and --split-size require --out", nil)
+		}
+		return "", opts, nil
+	}
+
+	format, ok := export.DetectFormat(flags.Out)
+	if !ok {
+		return "", opts, errors.New(errors.CodeCfgInvalid, "cannot infer export format from --out file extension", map[string]any{
+			"out":        flags.Out,
+			"extensions": []string{".csv", ".json", ".md", ".html"},
+		})
+	}
+	compression, xe := export.ParseCompression(flags.Compress)
+	if xe != nil {
+		return "", opts, xe
+	}
+	if flags.SplitRows < 0 {
+		return "", opts, errors.New(errors.CodeCfgInvalid, "--split-rows must not be negative", map[string]any{"split_rows": flags.SplitRows})
+	}
+	splitBytes, err := parseByteSize(flags.SplitSize)
+	if err != nil {
+		return "", opts, err
+	}
+
+	opts.Compression = compression
+	opts.SplitRows = flags.SplitRows
+	opts.SplitBytes = splitBytes
+	return format, opts, nil
+}
+
+// parseProjection builds the post-query projection from the column flags.
+func parseProjection(flags *QueryFlags) (db.Projection, error) {
+	rename, xe := db.ParseRename(flags.Rename)
+	if xe != nil {
+		return db.Projection{}, xe
+	}
+	p := db.Projection{
+		Columns: flags.Columns,
+		Exclude: flags.ExcludeColumns,
+		Rename:  rename,
+		Select:  flags.Select,
+	}
+	if xe := p.Validate(); xe != nil {
+		return db.Projection{}, xe
+	}
+	return p, nil
 }
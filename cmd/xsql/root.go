@@ -9,6 +9,7 @@ import (
 	_ "github.com/zx06/xsql/internal/db/mysql"
 	_ "github.com/zx06/xsql/internal/db/pg"
 	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/output"
 	"github.com/zx06/xsql/internal/stats"
 )
 
@@ -24,14 +25,23 @@ type Config struct {
 	FormatStr  string
 	ConfigStr  string
 	ProfileStr string
-	Resolved   config.Resolved
-	Stats      stats.StatsConfig
-	Attrs      map[string]string
+	// TemplateStr and TemplateFile are the raw --template / --template-file flags.
+	TemplateStr  string
+	TemplateFile string
+	// Template is the resolved output template source for the template format.
+	Template string
+	Resolved config.Resolved
+	Stats    stats.StatsConfig
+	Attrs    map[string]string
 }
 
 // GlobalConfig holds the global configuration state
 var GlobalConfig = &Config{}
 
+// annotationReportsConfigErrors marks commands that report config problems
+// themselves, so the root does not stop on them before the command runs.
+const annotationReportsConfigErrors = "xsql/reports-config-errors"
+
 // NewRootCommand creates the root command
 func NewRootCommand() *cobra.Command {
 	root := &cobra.Command{
@@ -73,9 +83,11 @@ func NewRootCommand() *cobra.Command {
 
 	var cliAttrs []string
 
-	root.PersistentFlags().StringVar(&GlobalConfig.ConfigStr, "config", "", "Config file path (YAML); default: ./xsql.yaml or $HOME/.config/xsql/xsql.yaml")
-	root.PersistentFlags().StringVarP(&GlobalConfig.ProfileStr, "profile", "p", "", "Profile name (config: profiles.<name>)")
-	root.PersistentFlags().StringVarP(&GlobalConfig.FormatStr, "format", "f", "auto", "Output format: json|yaml|table|csv|auto")
+	root.PersistentFlags().StringVar(&GlobalConfig.ConfigStr, "config", "", "Config file path (YAML); default: merge system, user, conf.d and ./xsql.yaml")
+	root.PersistentFlags().StringVarP(&GlobalConfig.ProfileStr, "profile", "p", "", "Profile name (config: profiles.<name>), tag:<tag> selector, or database URL")
+	root.PersistentFlags().StringVarP(&GlobalConfig.FormatStr, "format", "f", "auto", "Output format: json|yaml|table|csv|template|auto")
+	root.PersistentFlags().StringVar(&GlobalConfig.TemplateStr, "template", "", "Go text/template for template output (implies --format template)")
+	root.PersistentFlags().StringVar(&GlobalConfig.TemplateFile, "template-file", "", "Path to a Go text/template file for template output (implies --format template)")
 	root.PersistentFlags().StringArrayVar(&cliAttrs, "attr", nil, "Attribute key=value pair (repeatable)")
 
 	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
@@ -102,12 +114,34 @@ func NewRootCommand() *cobra.Command {
 			HomeDir:       os.Getenv("XSQL_HOMEDIR"),
 		})
 		if xe != nil {
-			return xe
+			if cmd.Annotations[annotationReportsConfigErrors] == "" {
+				return xe
+			}
+			// Keep going with the format from --format / XSQL_FORMAT.
+			r = config.Resolved{Format: "auto", ProfileName: GlobalConfig.ProfileStr}
+			if env := os.Getenv("XSQL_FORMAT"); env != "" {
+				r.Format = env
+			}
+			if formatSet {
+				r.Format = GlobalConfig.FormatStr
+			}
 		}
 		GlobalConfig.Resolved = r
 		GlobalConfig.FormatStr = r.Format
 		GlobalConfig.ProfileStr = r.ProfileName
 
+		tmpl, xe := resolveOutputTemplate(GlobalConfig.TemplateStr, GlobalConfig.TemplateFile)
+		if xe != nil {
+			return xe
+		}
+		GlobalConfig.Template = tmpl
+		if tmpl != "" && !formatSet {
+			GlobalConfig.FormatStr = string(output.FormatTemplate)
+		}
+		if GlobalConfig.FormatStr == string(output.FormatTemplate) && tmpl == "" {
+			return errors.New(errors.CodeCfgInvalid, "template format requires --template or --template-file", nil)
+		}
+
 		// Parse attributes: CLI > ENV
 		GlobalConfig.Attrs = stats.ParseAttrs(cliAttrs, stats.GetXSQLAttrEnv())
 
@@ -120,6 +154,21 @@ func NewRootCommand() *cobra.Command {
 	return root
 }
 
+// bindOutputWriter wraps the root PersistentPreRunE so the shared writer picks up
+// the resolved output template before any subcommand writes output.
+func bindOutputWriter(root *cobra.Command, w *output.Writer) {
+	preRun := root.PersistentPreRunE
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if preRun != nil {
+			if err := preRun(cmd, args); err != nil {
+				return err
+			}
+		}
+		w.Template = GlobalConfig.Template
+		return nil
+	}
+}
+
 // loadStatsConfig loads stats configuration from the config file.
 func loadStatsConfig(configPath string) stats.StatsConfig {
 	cfg, _, xe := config.LoadConfig(config.Options{ConfigPath: configPath})
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/zx06/xsql/internal/config"
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/output"
+	"github.com/zx06/xsql/internal/secret"
+)
+
+// maxSecretInput limits how much is read from a non-terminal stdin.
+const maxSecretInput = 64 << 10
+
+// NewSecretCommand creates the secret command group
+func NewSecretCommand(w *output.Writer) *cobra.Command {
+	secretCmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Manage secrets stored in the OS keyring (service xsql)",
+	}
+
+	secretCmd.AddCommand(newSecretSetCommand(w))
+	secretCmd.AddCommand(newSecretGetCommand(w))
+	secretCmd.AddCommand(newSecretDeleteCommand(w))
+	secretCmd.AddCommand(newSecretListCommand(w))
+
+	return secretCmd
+}
+
+// newSecretSetCommand creates the secret set command
+func newSecretSetCommand(w *output.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <account>",
+		Short: "Store a secret in the keyring; the value is read from a hidden prompt or stdin",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			account := keyringAccount(args[0])
+			value, xe := readSecretInput(cmd, fmt.Sprintf("Secret for %s: ", account), true)
+			if xe != nil {
+				return xe
+			}
+			if xe := secret.SetKeyring(nil, account, value); xe != nil {
+				return xe
+			}
+
+			return w.WriteOK(format, map[string]any{
+				"account": account,
+				"ref":     secret.KeyringRef(account),
+			})
+		},
+	}
+}
+
+// newSecretGetCommand creates the secret get command
+func newSecretGetCommand(w *output.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <account>",
+		Short: "Print a secret stored in the keyring",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			account := keyringAccount(args[0])
+			value, xe := secret.Resolve(secret.KeyringRef(account), secret.Options{})
+			if xe != nil {
+				return xe
+			}
+
+			return w.WriteOK(format, map[string]any{
+				"account": account,
+				"value":   value,
+			})
+		},
+	}
+}
+
+// newSecretDeleteCommand creates the secret delete command
+func newSecretDeleteCommand(w *output.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <account>",
+		Short: "Remove a secret from the keyring",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			account := keyringAccount(args[0])
+			if xe := secret.DeleteKeyring(nil, account); xe != nil {
+				return xe
+			}
+
+			return w.WriteOK(format, map[string]any{
+				"account": account,
+				"deleted": true,
+			})
+		},
+	}
+}
+
+// newSecretListCommand creates the secret list command
+func newSecretListCommand(w *output.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List keyring accounts referenced by the config and whether each is stored",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			cfg, _, xe := config.LoadConfig(config.Options{
+				ConfigPath: GlobalConfig.ConfigStr,
+			})
+			if xe != nil {
+				return xe
+			}
+
+			// The OS keyrings cannot enumerate entries, so list what the config uses.
+			usedBy := map[string][]string{}
+			for _, f := range config.SecretFields(&cfg) {
+				if secret.IsKeyringRef(f.Value) {
+					account := strings.TrimPrefix(f.Value, "keyring:")
+					usedBy[account] = append(usedBy[account], f.Path)
+				}
+			}
+			accounts := make([]string, 0, len(usedBy))
+			for account := range usedBy {
+				accounts = append(accounts, account)
+			}
+			sort.Strings(accounts)
+
+			items := make([]map[string]any, 0, len(accounts))
+			for _, account := range accounts {
+				_, xe := secret.Resolve(secret.KeyringRef(account), secret.Options{})
+				items = append(items, map[string]any{
+					"account": account,
+					"ref":     secret.KeyringRef(account),
+					"stored":  xe == nil,
+					"used_by": usedBy[account],
+				})
+			}
+
+			return w.WriteOK(format, map[string]any{
+				"files":   cfg.Files,
+				"secrets": items,
+			})
+		},
+	}
+}
+
+// newProfileSetPasswordCommand creates the profile set-password command
+func newProfileSetPasswordCommand(w *output.Writer) *cobra.Command {
+	var account string
+
+	cmd := &cobra.Command{
+		Use:   "set-password <profile>",
+		Short: "Store the profile password in the keyring and point the profile at it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			opts := config.Options{ConfigPath: GlobalConfig.ConfigStr}
+			cfg, _, xe := config.LoadConfig(opts)
+			if xe != nil {
+				return xe
+			}
+			merged, ok := cfg.Profiles[name]
+			if !ok {
+				return errors.New(errors.CodeCfgInvalid, "profile not found", map[string]any{"name": name})
+			}
+
+			// Point the file that defines the profile at the keyring, so a
+			// higher layer never gains a password-only stub of it.
+			cfgPath, xe := config.FindEntryConfigPath(opts, "profiles", name)
+			if xe != nil {
+				return xe
+			}
+			p, ok, xe := config.FileProfile(cfgPath, name)
+			if xe != nil {
+				return xe
+			}
+			if !ok {
+				return errors.New(errors.CodeCfgInvalid, "profile is not defined in the config file", map[string]any{"name": name, "config_path": cfgPath})
+			}
+			if account == "" {
+				// Reuse the account the profile already points at.
+				if secret.IsKeyringRef(merged.Password) {
+					account = strings.TrimPrefix(merged.Password, "keyring:")
+				} else {
+					account = name + "/password"
+				}
+			}
+			account = keyringAccount(account)
+
+			value, xe := readSecretInput(cmd, fmt.Sprintf("Password for profile %s: ", name), true)
+			if xe != nil {
+				return xe
+			}
+			if xe := secret.SetKeyring(nil, account, value); xe != nil {
+				return xe
+			}
+			p.Password = secret.KeyringRef(account)
+			if xe := config.SaveProfile(cfgPath, name, p); xe != nil {
+				return xe
+			}
+
+			return w.WriteOK(format, map[string]any{
+				"config_path": cfgPath,
+				"profile":     name,
+				"account":     account,
+				"password":    p.Password,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&account, "account", "", "Keyring account (default: the profile's current keyring account, or <profile>/password)")
+
+	return cmd
+}
+
+// keyringAccount accepts either an account or a keyring:<account> reference.
+func keyringAccount(s string) string {
+	return strings.TrimPrefix(strings.TrimSpace(s), "keyring:")
+}
+
+// readSecretInput reads a secret from a hidden terminal prompt, asking twice
+// when confirm is set, or from stdin (trailing newline trimmed) when stdin is
+// not a terminal.
+func readSecretInput(cmd *cobra.Command, prompt string, confirm bool) (string, *errors.XError) {
+	in := cmd.InOrStdin()
+	var value string
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		read := func(prompt string) (string, *errors.XError) {
+			fmt.Fprint(cmd.ErrOrStderr(), prompt)
+			b, err := term.ReadPassword(int(f.Fd()))
+			fmt.Fprintln(cmd.ErrOrStderr())
+			if err != nil {
+				return "", errors.Wrap(errors.CodeInternal, "failed to read secret", nil, err)
+			}
+			return string(b), nil
+		}
+		v, xe := read(prompt)
+		if xe != nil {
+			return "", xe
+		}
+		if confirm && v != "" {
+			again, xe := read("Repeat: ")
+			if xe != nil {
+				return "", xe
+			}
+			if again != v {
+				return "", errors.New(errors.CodeCfgInvalid, "secrets do not match", nil)
+			}
+		}
+		value = v
+	} else {
+		b, err := io.ReadAll(io.LimitReader(in, maxSecretInput))
+		if err != nil {
+			return "", errors.Wrap(errors.CodeInternal, "failed to read secret from stdin", nil, err)
+		}
+		value = strings.TrimRight(string(b), "\r\n")
+	}
+	if value == "" {
+		return "", errors.New(errors.CodeCfgInvalid, "secret value is empty", nil)
+	}
+	return value, nil
+}
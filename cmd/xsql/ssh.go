@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/zx06/xsql/internal/app"
+	"github.com/zx06/xsql/internal/config"
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/output"
+	"github.com/zx06/xsql/internal/ssh"
+)
+
+// NewSSHCommand creates the ssh command group
+func NewSSHCommand(w *output.Writer) *cobra.Command {
+	sshCmd := &cobra.Command{
+		Use:   "ssh",
+		Short: "Manage SSH proxy host keys",
+	}
+
+	sshCmd.AddCommand(newSSHTrustCommand(w))
+
+	return sshCmd
+}
+
+// newSSHTrustCommand creates the ssh trust command
+func newSSHTrustCommand(w *output.Writer) *cobra.Command {
+	var yes, allowPlaintext bool
+
+	cmd := &cobra.Command{
+		Use:   "trust <ssh_proxy>",
+		Short: "Show the host key fingerprints of an SSH proxy and its jump hosts, and record unknown keys in known_hosts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			format, err := parseOutputFormat(GlobalConfig.FormatStr)
+			if err != nil {
+				return err
+			}
+
+			cfg, _, xe := config.LoadConfig(config.Options{
+				ConfigPath: GlobalConfig.ConfigStr,
+			})
+			if xe != nil {
+				return xe
+			}
+			proxy, xe := config.ResolveSSHProxy(cfg.SSHProxies, name)
+			if xe != nil {
+				return xe
+			}
+
+			confirm := confirmHostKey(cmd)
+			if yes {
+				confirm = func(*ssh.HostKey) *errors.XError { return nil }
+			}
+			hosts, xe := app.TrustSSHProxyHostKeys(context.Background(), *proxy, allowPlaintext, confirm)
+			if xe != nil {
+				return xe
+			}
+
+			return w.WriteOK(format, map[string]any{
+				"ssh_proxy": name,
+				"hosts":     hosts,
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Record unknown host keys without asking")
+	cmd.Flags().BoolVar(&allowPlaintext, "allow-plaintext", false, "Allow plaintext secrets in config")
+
+	return cmd
+}
+
+// confirmHostKey asks on the terminal whether to trust an unknown host key.
+// Without a terminal the key is not trusted; --yes records it instead.
+func confirmHostKey(cmd *cobra.Command) func(*ssh.HostKey) *errors.XError {
+	var reader *bufio.Reader
+	return func(hk *ssh.HostKey) *errors.XError {
+		details := map[string]any{
+			"host":        hk.Host,
+			"port":        hk.Port,
+			"key_type":    hk.Type,
+			"fingerprint": hk.Fingerprint,
+		}
+		f, ok := cmd.InOrStdin().(*os.File)
+		if !ok || !term.IsTerminal(int(f.Fd())) {
+			return errors.New(errors.CodeSSHHostKeyMismatch, "ssh host key is not in known_hosts; check the fingerprint and run again on a terminal, or pass --yes", details)
+		}
+		if reader == nil {
+			reader = bufio.NewReader(f)
+		}
+
+		errOut := cmd.ErrOrStderr()
+		fmt.Fprintf(errOut, "Host %s (%s) is not in %s.\n", hk.Name, net.JoinHostPort(hk.Host, strconv.Itoa(hk.Port)), hk.KnownHostsFile)
+		fmt.Fprintf(errOut, "%s key fingerprint is %s.\n", hk.Type, hk.Fingerprint)
+		fmt.Fprintf(errOut, "Trust this host key? [y/N]: ")
+		input, _ := reader.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y", "yes":
+			return nil
+		}
+		return errors.New(errors.CodeSSHHostKeyMismatch, "ssh host key was not trusted", details)
+	}
+}
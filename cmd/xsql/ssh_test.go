@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/output"
+)
+
+// startHostKeyServer runs an SSH server that only completes key exchange,
+// which is all ssh trust needs.
+func startHostKeyServer(t *testing.T) (string, gossh.PublicKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &gossh.ServerConfig{NoClientAuth: true}
+	cfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _, _, _ = gossh.NewServerConn(conn, cfg)
+			}()
+		}
+	}()
+	return ln.Addr().String(), signer.PublicKey()
+}
+
+func TestSSHTrustCommand(t *testing.T) {
+	addr, hostKey := startHostKeyServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	dir := t.TempDir()
+	knownHosts := filepath.Join(dir, "known_hosts")
+	path := filepath.Join(dir, "xsql.yaml")
+	cfg := fmt.Sprintf("ssh_proxies:\n  bastion:\n    host: %s\n    port: %s\n    known_hosts_file: %s\n", host, port, knownHosts)
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	GlobalConfig.ConfigStr = path
+	GlobalConfig.FormatStr = "json"
+	defer func() { GlobalConfig.ConfigStr = "" }()
+
+	run := func(args ...string) (map[string]any, error) {
+		var out bytes.Buffer
+		w := output.New(&out, &bytes.Buffer{})
+		cmd := NewSSHCommand(&w)
+		cmd.SetArgs(args)
+		cmd.SetIn(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			return nil, err
+		}
+		var env struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &env); err != nil {
+			t.Fatalf("invalid output %q: %v", out.String(), err)
+		}
+		return env.Data, nil
+	}
+	status := func(data map[string]any) any {
+		hosts, _ := data["hosts"].([]any)
+		if len(hosts) != 1 {
+			t.Fatalf("hosts = %v", data["hosts"])
+		}
+		h := hosts[0].(map[string]any)
+		if h["fingerprint"] != gossh.FingerprintSHA256(hostKey) || h["name"] != "bastion" {
+			t.Errorf("host = %v", h)
+		}
+		return h["status"]
+	}
+
+	// Without a terminal, unknown keys need --yes.
+	_, err := run("trust", "bastion")
+	if xe, ok := err.(*errors.XError); !ok || xe.Code != errors.CodeSSHHostKeyMismatch || xe.Details["fingerprint"] != gossh.FingerprintSHA256(hostKey) {
+		t.Fatalf("non-interactive trust: %v", err)
+	}
+	if _, err := os.Stat(knownHosts); !os.IsNotExist(err) {
+		t.Errorf("known_hosts written without confirmation: %v", err)
+	}
+
+	data, err := run("trust", "bastion", "--yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := status(data); s != "added" {
+		t.Errorf("status = %v, want added", s)
+	}
+	data, err = run("trust", "bastion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := status(data); s != "known" {
+		t.Errorf("status = %v, want known", s)
+	}
+
+	if _, err := run("trust", "missing"); err == nil {
+		t.Error("expected unknown ssh_proxy to fail")
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/zx06/xsql/internal/app"
 	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/output"
@@ -93,6 +94,10 @@ func runWebCommand(opts *webCommandOptions, w *output.Writer) error {
 		_ = listener.Close()
 	}()
 
+	// Queries through an ssh proxy share one reconnecting SSH connection per proxy.
+	tunnels := app.NewSSHTunnels(logSSHTunnelStatus("web"))
+	defer func() { _ = tunnels.Close() }()
+
 	handler := webpkg.NewHandler(webpkg.HandlerOptions{
 		ConfigPath:       GlobalConfig.ConfigStr,
 		InitialProfile:   GlobalConfig.ProfileStr,
@@ -101,6 +106,7 @@ func runWebCommand(opts *webCommandOptions, w *output.Writer) error {
 		AuthRequired:     resolved.authRequired,
 		AuthToken:        resolved.authToken,
 		Stats:            GlobalConfig.Stats,
+		SSHTunnels:       tunnels,
 	})
 	server := webpkg.NewServer(listener, handler)
 	url := webpkg.PublicURL(server.Addr())
@@ -104,7 +104,7 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []ChatMessage) (*A
 	exportToolDef := openai.ChatCompletionToolParam{
 		Function: shared.FunctionDefinitionParam{
 			Name:        "export_data",
-			Description: openai.String("Export a cached session dataset (e.g. res1, res2) to a local file in CSV, JSON, or Markdown format after human confirmation."),
+			Description: openai.String("Export a cached session dataset (e.g. res1, res2) to a local file in CSV, JSON, Markdown, or HTML report format after human confirmation."),
 			Parameters: shared.FunctionParameters{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -114,12 +114,12 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []ChatMessage) (*A
 					},
 					"format": map[string]interface{}{
 						"type":        "string",
-						"description": "Export file format: 'csv', 'json', or 'markdown'.",
-						"enum":        []string{"csv", "json", "markdown"},
+						"description": "Export file format: 'csv', 'json', 'markdown', or 'html' (self-contained report with sortable/filterable table).",
+						"enum":        []string{"csv", "json", "markdown", "html"},
 					},
 					"filepath": map[string]interface{}{
 						"type":        "string",
-						"description": "Target file path (e.g. 'result.csv', 'report.json').",
+						"description": "Target file path (e.g. 'result.csv', 'report.json', 'report.html').",
 					},
 					"explanation": map[string]interface{}{
 						"type":        "string",
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"github.com/zx06/xsql/internal/config"
+	"github.com/zx06/xsql/internal/db"
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/output"
 	"github.com/zx06/xsql/internal/spec"
@@ -18,9 +20,11 @@ func New(version, commit, date string) App {
 
 func (a App) BuildSpec() spec.Spec {
 	globalFlags := []spec.FlagSpec{
-		{Name: "config", Default: "", Description: "Config file path (YAML); default: ./xsql.yaml or $HOME/.config/xsql/xsql.yaml"},
-		{Name: "profile", Shorthand: "p", Env: "XSQL_PROFILE", Default: "", Description: "Profile name (config: profiles.<name>)"},
-		{Name: "format", Shorthand: "f", Env: "XSQL_FORMAT", Default: "auto", Description: "Output format: json|yaml|table|csv|auto"},
+		{Name: "config", Default: "", Description: "Config file path (YAML); default: merge system, user, conf.d and ./xsql.yaml"},
+		{Name: "profile", Shorthand: "p", Env: "XSQL_PROFILE", Default: "", Description: "Profile name (config: profiles.<name>), tag:<tag> selector, or database URL"},
+		{Name: "format", Shorthand: "f", Env: "XSQL_FORMAT", Default: "auto", Description: "Output format: json|yaml|table|csv|template|auto"},
+		{Name: "template", Default: "", Description: "Go text/template for template output (implies --format template)"},
+		{Name: "template-file", Default: "", Description: "Path to a Go text/template file for template output (implies --format template)"},
 		{Name: "attr", Env: "XSQL_ATTR", Default: "", Description: "Attribute key=value pair (repeatable)"},
 	}
 	return spec.Spec{
@@ -43,18 +47,64 @@ func (a App) BuildSpec() spec.Spec {
 					spec.FlagSpec{Name: "unsafe-allow-write", Default: "false", Description: "Allow writes when profile unsafe_allow_write is true"},
 					spec.FlagSpec{Name: "allow-plaintext", Default: "false", Description: "Allow plaintext secrets in config"},
 					spec.FlagSpec{Name: "ssh-skip-known-hosts-check", Default: "false", Description: "Skip SSH known_hosts check (dangerous)"},
+					spec.FlagSpec{Name: "table-layout", Default: "auto", Description: "Table layout: auto|horizontal|vertical"},
+					spec.FlagSpec{Name: "columns", Default: "", Description: "Only output these columns, in this order (comma-separated)"},
+					spec.FlagSpec{Name: "exclude-columns", Default: "", Description: "Drop these columns from the output (comma-separated)"},
+					spec.FlagSpec{Name: "rename", Default: "", Description: "Rename output columns: old=new (repeatable)"},
+					spec.FlagSpec{Name: "select", Default: "", Description: "Extract a nested JSON value as a new column: [name=]column.path[0].key (repeatable)"},
+					spec.FlagSpec{Name: "out", Default: "", Description: "Write results to a file (.csv|.json|.md|.html, optionally .gz|.zst)"},
+					spec.FlagSpec{Name: "compress", Default: "", Description: "Compression for --out: gzip|zstd|none"},
+					spec.FlagSpec{Name: "split-rows", Default: "0", Description: "Split --out into files of at most N rows"},
+					spec.FlagSpec{Name: "split-size", Default: "", Description: "Split --out into files of about this uncompressed size (e.g. 100MB)"},
 				),
 			},
 			{
 				Name:        "profile list",
 				Description: "List all configured profiles",
-				Flags:       globalFlags,
+				Flags: append(globalFlags,
+					spec.FlagSpec{Name: "tag", Default: "", Description: "Only list profiles with this tag (repeatable; all must match)"},
+				),
 			},
 			{
 				Name:        "profile show",
 				Description: "Show profile details (passwords are masked)",
 				Flags:       globalFlags,
 			},
+			{
+				Name:        "profile set-password",
+				Description: "Store the profile password in the keyring and point the profile at it",
+				Flags: append(globalFlags,
+					spec.FlagSpec{Name: "account", Default: "", Description: "Keyring account (default: the profile's current keyring account, or <profile>/password)"},
+				),
+			},
+			{
+				Name:        "secret set",
+				Description: "Store a secret in the keyring; the value is read from a hidden prompt or stdin",
+				Flags:       globalFlags,
+			},
+			{
+				Name:        "secret get",
+				Description: "Print a secret stored in the keyring",
+				Flags:       globalFlags,
+			},
+			{
+				Name:        "secret delete",
+				Description: "Remove a secret from the keyring",
+				Flags:       globalFlags,
+			},
+			{
+				Name:        "secret list",
+				Description: "List keyring accounts referenced by the config and whether each is stored",
+				Flags:       globalFlags,
+			},
+			{
+				Name:        "ssh trust",
+				Description: "Show the host key fingerprints of an SSH proxy and its jump hosts, and record unknown keys in known_hosts",
+				Flags: append(globalFlags,
+					spec.FlagSpec{Name: "yes", Shorthand: "y", Default: "false", Description: "Record unknown host keys without asking"},
+					spec.FlagSpec{Name: "allow-plaintext", Default: "false", Description: "Allow plaintext secrets in config"},
+				),
+			},
 			{
 				Name:        "schema dump",
 				Description: "Dump database schema (tables, columns, indexes, foreign keys)",
@@ -95,6 +145,55 @@ func (a App) BuildSpec() spec.Spec {
 					spec.FlagSpec{Name: "ssh-skip-known-hosts-check", Default: "false", Description: "Skip SSH known_hosts check (dangerous)"},
 				),
 			},
+			{
+				Name:        "config sources",
+				Description: "Show loaded config files and which file each profile came from",
+				Flags:       globalFlags,
+			},
+			{
+				Name:        "config validate",
+				Description: "Check the configuration and report all problems",
+				Flags: append(globalFlags,
+					spec.FlagSpec{Name: "strict", Default: "false", Description: "Treat warnings as errors"},
+				),
+			},
+			{
+				Name:        "config migrate",
+				Description: "Upgrade the config file to the current layout version, keeping comments",
+				Flags: append(globalFlags,
+					spec.FlagSpec{Name: "dry-run", Default: "false", Description: "Report the changes without writing the file"},
+				),
+			},
+			{
+				Name:        "config keygen",
+				Description: "Create the key used to encrypt config files and values",
+				Flags: append(globalFlags,
+					spec.FlagSpec{Name: "keyring", Default: "false", Description: "Store the key in the OS keyring instead of a key file"},
+					spec.FlagSpec{Name: "force", Default: "false", Description: "Replace an existing key"},
+				),
+			},
+			{
+				Name:        "config encrypt",
+				Description: "Encrypt the whole config file in place",
+				Flags:       globalFlags,
+			},
+			{
+				Name:        "config decrypt",
+				Description: "Decrypt a whole-file encrypted config file in place",
+				Flags:       globalFlags,
+			},
+			{
+				Name:        "config encrypt-value",
+				Description: "Encrypt a value read from a hidden prompt or stdin, for use in the config file",
+				Flags:       globalFlags,
+			},
+			{
+				Name:        "config schema",
+				Description: "Print the JSON Schema for xsql.yaml",
+				Flags: append(globalFlags,
+					spec.FlagSpec{Name: "out", Default: "", Description: "Write the bare schema to this file instead of stdout"},
+				),
+			},
 			{
 				Name:        "stats",
 				Description: "Show usage statistics",
@@ -103,7 +202,8 @@ func (a App) BuildSpec() spec.Spec {
 				),
 			},
 		},
-		ErrorCodes: errors.AllCodes(),
+		ErrorCodes:   errors.AllCodes(),
+		ConfigSchema: config.JSONSchema(config.SchemaOptions{DBTypes: db.RegisteredNames()}),
 	}
 }
 
@@ -23,6 +23,9 @@ func TestBuildSpecHasSchemaVersion(t *testing.T) {
 	if !seenProfile {
 		t.Fatalf("expected profile flag in spec")
 	}
+	if s.ConfigSchema == nil || s.ConfigSchema["$defs"] == nil {
+		t.Fatalf("expected config schema in spec")
+	}
 }
 
 func TestVersionInfo(t *testing.T) {
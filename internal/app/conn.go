@@ -2,13 +2,16 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"sync"
+	"time"
 
 	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/db"
 	_ "github.com/zx06/xsql/internal/db/mysql"
 	_ "github.com/zx06/xsql/internal/db/pg"
+	"github.com/zx06/xsql/internal/dbauth"
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/secret"
 	"github.com/zx06/xsql/internal/ssh"
@@ -53,31 +56,90 @@ type ConnectionOptions struct {
 	Profile          config.Profile
 	AllowPlaintext   bool
 	SkipHostKeyCheck bool
+	// OnTLSHandshake receives the state of each TLS handshake to the database.
+	OnTLSHandshake func(tls.ConnectionState)
+	// SSHTunnels, when set, provides a shared SSH connection for the profile's
+	// ssh proxy instead of one opened for (and closed with) this connection.
+	SSHTunnels *SSHTunnels
 }
 
 func ResolveConnection(ctx context.Context, opts ConnectionOptions) (*Connection, *errors.XError) {
 	allowPlaintext := opts.AllowPlaintext || opts.Profile.AllowPlaintext
 
-	password := opts.Profile.Password
+	closeHooks := make([]func(), 0, 1)
+	var hooksMu sync.Mutex
+	runHooks := func() {
+		for _, fn := range closeHooks {
+			if fn != nil {
+				fn()
+			}
+		}
+	}
+
+	user, password := opts.Profile.User, opts.Profile.Password
+	var token db.TokenSource
+	if auth := opts.Profile.Auth; auth.Provider != "" {
+		if password != "" {
+			return nil, errors.New(errors.CodeCfgInvalid, "password must not be set together with auth", map[string]any{"provider": auth.Provider})
+		}
+		src, xe := dbauth.NewSource(auth.Provider, dbauth.Request{
+			DB:       opts.Profile.DB,
+			Host:     opts.Profile.Host,
+			Port:     opts.Profile.Port,
+			User:     user,
+			Database: opts.Profile.Database,
+			Command:  auth.Command,
+			Region:   auth.Region,
+			TTL:      time.Duration(auth.TTL) * time.Second,
+		})
+		if xe != nil {
+			return nil, xe
+		}
+		token = src
+	}
 	if password != "" {
-		pw, xe := secret.Resolve(password, secret.Options{AllowPlaintext: allowPlaintext})
+		creds, xe := secret.ResolveCredentials(password, secret.Options{AllowPlaintext: allowPlaintext})
 		if xe != nil {
 			return nil, xe
 		}
-		password = pw
+		password = creds.Password
+		if creds.Username != "" {
+			user = creds.Username
+		}
+		if creds.Revoke != nil {
+			// Leased credentials (e.g. Vault dynamic secrets) live as long as the connection.
+			closeHooks = append(closeHooks, func() { _ = creds.Revoke() })
+		}
 	}
 
-	var sshClient *ssh.Client
-	if opts.Profile.SSHConfig != nil {
+	var (
+		sshClient *ssh.Client
+		dialer    db.Dialer
+	)
+	switch {
+	case opts.Profile.SSHConfig != nil && opts.SSHTunnels != nil:
+		rd, release, xe := opts.SSHTunnels.Dialer(ctx, *opts.Profile.SSHConfig, allowPlaintext, opts.SkipHostKeyCheck)
+		if xe != nil {
+			runHooks()
+			return nil, xe
+		}
+		// Hold the tunnel until the connection closes, so replacing it does
+		// not cut off this connection's queries.
+		closeHooks = append(closeHooks, release)
+		dialer = rd
+	case opts.Profile.SSHConfig != nil:
 		sshOpts, xe := resolveSSHOptions(opts.Profile, allowPlaintext, opts.SkipHostKeyCheck)
 		if xe != nil {
+			runHooks()
 			return nil, xe
 		}
 		sc, xe := ssh.Connect(ctx, sshOpts)
 		if xe != nil {
+			runHooks()
 			return nil, xe
 		}
 		sshClient = sc
+		dialer = sc
 	}
 
 	drv, ok := db.Get(opts.Profile.DB)
@@ -85,18 +147,28 @@ func ResolveConnection(ctx context.Context, opts ConnectionOptions) (*Connection
 		if sshClient != nil {
 			_ = sshClient.Close()
 		}
+		runHooks()
 		return nil, errors.New(errors.CodeDBDriverUnsupported, "unsupported db driver", map[string]any{"db": opts.Profile.DB})
 	}
 
-	closeHooks := make([]func(), 0, 1)
-	var hooksMu sync.Mutex
 	connOpts := db.ConnOptions{
 		DSN:      opts.Profile.DSN,
 		Host:     opts.Profile.Host,
 		Port:     opts.Profile.Port,
-		User:     opts.Profile.User,
+		User:     user,
 		Password: password,
 		Database: opts.Profile.Database,
+		Params:   opts.Profile.ConnParams(),
+		TLS: db.TLSOptions{
+			Mode:       opts.Profile.TLS.Mode,
+			CAFile:     opts.Profile.TLS.CAFile,
+			CertFile:   opts.Profile.TLS.CertFile,
+			KeyFile:    opts.Profile.TLS.KeyFile,
+			ServerName: opts.Profile.TLS.ServerName,
+		},
+		Dialer:         dialer,
+		Token:          token,
+		OnTLSHandshake: opts.OnTLSHandshake,
 		RegisterCloseHook: func(fn func()) {
 			if fn != nil {
 				hooksMu.Lock()
@@ -105,20 +177,12 @@ func ResolveConnection(ctx context.Context, opts ConnectionOptions) (*Connection
 			}
 		},
 	}
-	if sshClient != nil {
-		connOpts.Dialer = sshClient
-	}
-
 	conn, xe := drv.Open(ctx, connOpts)
 	if xe != nil {
 		if sshClient != nil {
 			_ = sshClient.Close()
 		}
-		for _, fn := range closeHooks {
-			if fn != nil {
-				fn()
-			}
-		}
+		runHooks()
 		return nil, xe
 	}
 
@@ -179,7 +243,13 @@ func ResolveReconnectableSSH(ctx context.Context, profile config.Profile, allowP
 
 // resolveSSHOptions builds SSH options from a profile, resolving secrets.
 func resolveSSHOptions(profile config.Profile, allowPlaintext, skipHostKeyCheck bool) (ssh.Options, *errors.XError) {
-	passphrase := profile.SSHConfig.Passphrase
+	return sshProxyOptions(*profile.SSHConfig, allowPlaintext, skipHostKeyCheck)
+}
+
+// sshProxyOptions builds SSH options for a proxy and its jump hosts, resolving
+// their passphrases and passwords.
+func sshProxyOptions(sp config.SSHProxy, allowPlaintext, skipHostKeyCheck bool) (ssh.Options, *errors.XError) {
+	passphrase := sp.Passphrase
 	if passphrase != "" {
 		pp, xe := secret.Resolve(passphrase, secret.Options{AllowPlaintext: allowPlaintext})
 		if xe != nil {
@@ -187,14 +257,38 @@ func resolveSSHOptions(profile config.Profile, allowPlaintext, skipHostKeyCheck
 		}
 		passphrase = pp
 	}
+	password := sp.Password
+	if password != "" {
+		pw, xe := secret.Resolve(password, secret.Options{AllowPlaintext: allowPlaintext})
+		if xe != nil {
+			return ssh.Options{}, xe
+		}
+		password = pw
+	}
 
-	return ssh.Options{
-		Host:                profile.SSHConfig.Host,
-		Port:                profile.SSHConfig.Port,
-		User:                profile.SSHConfig.User,
-		IdentityFile:        profile.SSHConfig.IdentityFile,
+	opts := ssh.Options{
+		Host:                sp.Host,
+		Port:                sp.Port,
+		User:                sp.User,
+		IdentityFile:        sp.IdentityFile,
 		Passphrase:          passphrase,
-		KnownHostsFile:      profile.SSHConfig.KnownHostsFile,
-		SkipKnownHostsCheck: skipHostKeyCheck || profile.SSHConfig.SkipHostKey,
-	}, nil
+		KnownHostsFile:      sp.KnownHostsFile,
+		IdentityAgent:       sp.IdentityAgent,
+		CertificateFile:     sp.CertificateFile,
+		Password:            password,
+		AcceptNewHostKey:    sp.StrictHostKeyChecking == "accept-new",
+		SkipKnownHostsCheck: skipHostKeyCheck || sp.SkipHostKey,
+		Name:                sp.Name,
+	}
+	if sp.KeyboardInteractive {
+		opts.KeyboardInteractive = ssh.TerminalChallenge
+	}
+	for _, hop := range sp.JumpHosts {
+		hopOpts, xe := sshProxyOptions(hop, allowPlaintext, skipHostKeyCheck)
+		if xe != nil {
+			return ssh.Options{}, xe
+		}
+		opts.Jump = append(opts.Jump, hopOpts)
+	}
+	return opts, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/db"
 	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/secret"
 	"github.com/zx06/xsql/internal/ssh"
 )
 
@@ -436,3 +438,128 @@ func TestResolveSSHOptions_Success(t *testing.T) {
 		t.Error("expected SkipKnownHostsCheck=true")
 	}
 }
+
+func TestResolveConnection_LeasedCredentials(t *testing.T) {
+	var revoked int32
+	secret.Register("testlease", leaseBackend{revoked: &revoked})
+
+	var gotUser, gotPassword string
+	driverName := registerTestDriver(t, &testDriver{
+		openFn: func(ctx context.Context, opts db.ConnOptions) (*sql.DB, *errors.XError) {
+			gotUser, gotPassword = opts.User, opts.Password
+			return nil, nil
+		},
+	})
+
+	conn, xe := ResolveConnection(context.Background(), ConnectionOptions{
+		Profile: config.Profile{DB: driverName, User: "static", Password: "testlease:role"},
+	})
+	if xe != nil {
+		t.Fatalf("unexpected error: %v", xe)
+	}
+	if gotUser != "leased-user" || gotPassword != "leased-pass" {
+		t.Errorf("driver got %q/%q, want leased credentials", gotUser, gotPassword)
+	}
+	if atomic.LoadInt32(&revoked) != 0 {
+		t.Fatal("lease revoked before Close")
+	}
+	_ = conn.Close()
+	if got := atomic.LoadInt32(&revoked); got != 1 {
+		t.Errorf("expected lease revoked once on Close, got %d", got)
+	}
+
+	// A failed connection must not leak the lease.
+	failing := registerTestDriver(t, &testDriver{
+		openFn: func(ctx context.Context, opts db.ConnOptions) (*sql.DB, *errors.XError) {
+			return nil, errors.New(errors.CodeDBConnectFailed, "open failed", nil)
+		},
+	})
+	if _, xe := ResolveConnection(context.Background(), ConnectionOptions{
+		Profile: config.Profile{DB: failing, Password: "testlease:role"},
+	}); xe == nil {
+		t.Fatal("expected error")
+	}
+	if got := atomic.LoadInt32(&revoked); got != 2 {
+		t.Errorf("expected lease revoked after failed open, got %d", got)
+	}
+}
+
+// leaseBackend hands out leased credentials like a Vault database secrets engine.
+type leaseBackend struct{ revoked *int32 }
+
+func (leaseBackend) Resolve(ref string, opts secret.Options) (string, *errors.XError) {
+	return "", errors.New(errors.CodeCfgInvalid, "leased only", nil)
+}
+
+func (b leaseBackend) Credentials(ref string, opts secret.Options) (*secret.Credentials, *errors.XError) {
+	return &secret.Credentials{
+		Username: "leased-user",
+		Password: "leased-pass",
+		LeaseID:  ref + "/1",
+		Revoke: func() *errors.XError {
+			atomic.AddInt32(b.revoked, 1)
+			return nil
+		},
+	}, nil
+}
+
+func TestResolveConnection_TokenAuth(t *testing.T) {
+	var got db.ConnOptions
+	driverName := registerTestDriver(t, &testDriver{
+		openFn: func(ctx context.Context, opts db.ConnOptions) (*sql.DB, *errors.XError) {
+			got = opts
+			return nil, nil
+		},
+	})
+
+	profile := config.Profile{DB: driverName, Host: "db.example", User: "iam_user", Auth: config.AuthConfig{Provider: "stub", TTL: 60}}
+	conn, xe := ResolveConnection(context.Background(), ConnectionOptions{Profile: profile})
+	if xe != nil {
+		t.Fatalf("unexpected error: %v", xe)
+	}
+	_ = conn.Close()
+	if got.Token == nil || got.Password != "" {
+		t.Fatalf("expected a token source and no password, got %+v", got)
+	}
+	token, xe := got.Token.Token(context.Background())
+	if xe != nil || !strings.HasPrefix(token, "stub-iam_user-") {
+		t.Errorf("token = %q, %v", token, xe)
+	}
+
+	profile.Password = "keyring:db/pw"
+	if _, xe := ResolveConnection(context.Background(), ConnectionOptions{Profile: profile}); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("password with auth: got %v, want CfgInvalid", xe)
+	}
+}
+
+func TestSSHProxyOptions_JumpHosts(t *testing.T) {
+	t.Setenv("XSQL_TEST_HOP_PASS", "hop-secret")
+	sp := config.SSHProxy{
+		Name:                "db-gw",
+		Host:                "gw.internal",
+		Password:            "env:XSQL_TEST_HOP_PASS",
+		KeyboardInteractive: true,
+		JumpHosts: []config.SSHProxy{
+			{Name: "bastion-a", Host: "a.example.com", Passphrase: "env:XSQL_TEST_HOP_PASS", StrictHostKeyChecking: "accept-new"},
+			{Name: "bastion-b", Host: "b.example.com", SkipHostKey: true, IdentityAgent: "none"},
+		},
+	}
+	opts, xe := sshProxyOptions(sp, false, false)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if opts.Name != "db-gw" || len(opts.Jump) != 2 || opts.Password != "hop-secret" || opts.KeyboardInteractive == nil {
+		t.Fatalf("opts = %+v", opts)
+	}
+	if a := opts.Jump[0]; a.Name != "bastion-a" || a.Passphrase != "hop-secret" || a.SkipKnownHostsCheck || !a.AcceptNewHostKey || a.KeyboardInteractive != nil {
+		t.Errorf("first hop = %+v", a)
+	}
+	if b := opts.Jump[1]; b.Name != "bastion-b" || !b.SkipKnownHostsCheck || b.AcceptNewHostKey || b.IdentityAgent != "none" {
+		t.Errorf("second hop = %+v", b)
+	}
+
+	sp.JumpHosts[1].Passphrase = "plain"
+	if _, xe := sshProxyOptions(sp, false, false); xe == nil {
+		t.Error("expected plaintext hop passphrase to be rejected")
+	}
+}
@@ -2,7 +2,10 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/zx06/xsql/internal/ai"
@@ -10,7 +13,6 @@ import (
 	"github.com/zx06/xsql/internal/db"
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/output"
-	"github.com/zx06/xsql/internal/secret"
 	"github.com/zx06/xsql/internal/ssh"
 )
 
@@ -33,6 +35,7 @@ func (r *ProfileListResult) ToProfileListData() (string, []output.ProfileListIte
 			Description: profile.Description,
 			DB:          profile.DB,
 			Mode:        profile.Mode,
+			Tags:        profile.Tags,
 		})
 	}
 	return r.ConfigPath, profiles, true
@@ -45,6 +48,7 @@ type QueryRequest struct {
 	AllowPlaintext   bool
 	SkipHostKeyCheck bool
 	UnsafeAllowWrite bool
+	SSHTunnels       *SSHTunnels // shared SSH connections; nil connects per request
 }
 
 // SchemaDumpRequest contains options for a schema dump operation.
@@ -54,6 +58,7 @@ type SchemaDumpRequest struct {
 	IncludeSystem    bool
 	AllowPlaintext   bool
 	SkipHostKeyCheck bool
+	SSHTunnels       *SSHTunnels // shared SSH connections; nil connects per request
 }
 
 // TableListRequest contains options for loading the lightweight table list.
@@ -63,6 +68,7 @@ type TableListRequest struct {
 	IncludeSystem    bool
 	AllowPlaintext   bool
 	SkipHostKeyCheck bool
+	SSHTunnels       *SSHTunnels // shared SSH connections; nil connects per request
 }
 
 // TableDescribeRequest contains options for loading a single table schema.
@@ -72,10 +78,12 @@ type TableDescribeRequest struct {
 	Name             string
 	AllowPlaintext   bool
 	SkipHostKeyCheck bool
+	SSHTunnels       *SSHTunnels // shared SSH connections; nil connects per request
 }
 
-// LoadProfiles loads and summarizes the configured profiles.
-func LoadProfiles(opts config.Options) (*ProfileListResult, *errors.XError) {
+// LoadProfiles loads and summarizes the configured profiles. When tags are given,
+// only profiles carrying all of them are returned.
+func LoadProfiles(opts config.Options, tags ...string) (*ProfileListResult, *errors.XError) {
 	cfg, cfgPath, xe := config.LoadConfig(opts)
 	if xe != nil {
 		return nil, xe
@@ -83,6 +91,9 @@ func LoadProfiles(opts config.Options) (*ProfileListResult, *errors.XError) {
 
 	profiles := make([]config.ProfileInfo, 0, len(cfg.Profiles))
 	for name, profile := range cfg.Profiles {
+		if !profile.HasTags(tags) {
+			continue
+		}
 		profiles = append(profiles, config.ProfileToInfo(name, profile))
 	}
 	sort.Slice(profiles, func(i, j int) bool {
@@ -102,6 +113,10 @@ func LoadProfileDetail(opts config.Options, name string) (map[string]any, *error
 		return nil, xe
 	}
 
+	name, xe = config.SelectProfile(cfg.Profiles, name)
+	if xe != nil {
+		return nil, xe
+	}
 	profile, xe := ResolveProfile(cfg, name)
 	if xe != nil {
 		return nil, xe
@@ -119,10 +134,25 @@ func LoadProfileDetail(opts config.Options, name string) (map[string]any, *error
 		"unsafe_allow_write": profile.UnsafeAllowWrite,
 		"allow_plaintext":    profile.AllowPlaintext,
 	}
+	if len(profile.Tags) > 0 {
+		result["tags"] = profile.Tags
+	}
 
 	if profile.DSN != "" {
 		result["dsn"] = "***"
 	}
+	if profile.URL != "" {
+		result["url"] = config.RedactURL(profile.URL)
+	}
+	if params := profile.ConnParams(); len(params) > 0 {
+		result["params"] = params
+	}
+	if profile.TLS != (config.TLSConfig{}) {
+		result["tls"] = profile.TLS
+	}
+	if profile.Auth != (config.AuthConfig{}) {
+		result["auth"] = profile.Auth
+	}
 	if profile.Password != "" {
 		result["password"] = "***"
 	}
@@ -137,22 +167,42 @@ func LoadProfileDetail(opts config.Options, name string) (map[string]any, *error
 			}
 		}
 	}
+	// With layered config the profile may be defined in several files; the last one wins.
+	if files := cfg.ProfileFiles[name]; len(files) > 0 {
+		result["source"] = files[len(files)-1]
+		if len(files) > 1 {
+			result["sources"] = files
+		}
+	}
+	// Inherited profiles show the merged values plus where each key came from.
+	if profile.Extends != "" {
+		result["extends"] = profile.Extends
+		if sources := cfg.ProfileSources[name]; sources != nil {
+			result["provenance"] = sources
+		}
+	}
 
 	return result, nil
 }
 
 // ResolveProfile returns a fully prepared profile with ssh config and default ports.
+// name may also be a tag selector such as "tag:prod".
 func ResolveProfile(cfg config.File, name string) (config.Profile, *errors.XError) {
+	name, xe := config.SelectProfile(cfg.Profiles, name)
+	if xe != nil {
+		return config.Profile{}, xe
+	}
 	profile, ok := cfg.Profiles[name]
 	if !ok {
 		return config.Profile{}, errors.New(errors.CodeCfgInvalid, "profile not found", map[string]any{"name": name})
 	}
 	if profile.SSHProxy != "" {
-		proxy, ok := cfg.SSHProxies[profile.SSHProxy]
-		if !ok {
-			return config.Profile{}, errors.New(errors.CodeCfgInvalid, "ssh_proxy not found", map[string]any{"profile": name, "ssh_proxy": profile.SSHProxy})
+		proxy, xe := config.ResolveSSHProxy(cfg.SSHProxies, profile.SSHProxy)
+		if xe != nil {
+			xe.Details["profile"] = name
+			return config.Profile{}, xe
 		}
-		profile.SSHConfig = &proxy
+		profile.SSHConfig = proxy
 	}
 	if profile.Port == 0 {
 		switch profile.DB {
@@ -175,6 +225,7 @@ func Query(ctx context.Context, req QueryRequest) (*db.QueryResult, *errors.XErr
 		Profile:          req.Profile,
 		AllowPlaintext:   req.AllowPlaintext,
 		SkipHostKeyCheck: req.SkipHostKeyCheck,
+		SSHTunnels:       req.SSHTunnels,
 	})
 	if xe != nil {
 		return nil, xe
@@ -197,6 +248,7 @@ func DumpSchema(ctx context.Context, req SchemaDumpRequest) (*db.SchemaInfo, *er
 		Profile:          req.Profile,
 		AllowPlaintext:   req.AllowPlaintext,
 		SkipHostKeyCheck: req.SkipHostKeyCheck,
+		SSHTunnels:       req.SSHTunnels,
 	})
 	if xe != nil {
 		return nil, xe
@@ -219,6 +271,7 @@ func ListTables(ctx context.Context, req TableListRequest) (*db.TableList, *erro
 		Profile:          req.Profile,
 		AllowPlaintext:   req.AllowPlaintext,
 		SkipHostKeyCheck: req.SkipHostKeyCheck,
+		SSHTunnels:       req.SSHTunnels,
 	})
 	if xe != nil {
 		return nil, xe
@@ -241,6 +294,7 @@ func DescribeTable(ctx context.Context, req TableDescribeRequest) (*db.Table, *e
 		Profile:          req.Profile,
 		AllowPlaintext:   req.AllowPlaintext,
 		SkipHostKeyCheck: req.SkipHostKeyCheck,
+		SSHTunnels:       req.SSHTunnels,
 	})
 	if xe != nil {
 		return nil, xe
@@ -276,16 +330,28 @@ func SchemaTimeout(profile config.Profile, overrideSeconds int, overrideSet bool
 }
 
 // TestProfileConnection tests a database profile connection and measures latency.
+// When the connection uses TLS, the negotiated version and cipher are reported
+// under "tls" (null for plaintext connections).
 func TestProfileConnection(ctx context.Context, profile config.Profile, allowPlaintext, skipHostKey bool) (map[string]any, *errors.XError) {
 	if profile.DB == "" {
 		return nil, errors.New(errors.CodeCfgInvalid, "db type is required (mysql|pg)", nil)
 	}
 
+	var (
+		tlsMu    sync.Mutex
+		tlsState *db.TLSState
+	)
 	start := time.Now()
 	conn, xe := ResolveConnection(ctx, ConnectionOptions{
 		Profile:          profile,
 		AllowPlaintext:   allowPlaintext,
 		SkipHostKeyCheck: skipHostKey,
+		OnTLSHandshake: func(cs tls.ConnectionState) {
+			st := db.NewTLSState(cs)
+			tlsMu.Lock()
+			tlsState = &st
+			tlsMu.Unlock()
+		},
 	})
 	if xe != nil {
 		return nil, xe
@@ -297,10 +363,13 @@ func TestProfileConnection(ctx context.Context, profile config.Profile, allowPla
 	}
 	latency := time.Since(start).Milliseconds()
 
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
 	return map[string]any{
 		"ok":         true,
 		"latency_ms": latency,
 		"db":         profile.DB,
+		"tls":        tlsState,
 	}, nil
 }
 
@@ -311,37 +380,93 @@ func TestSSHProxyConnection(ctx context.Context, proxy config.SSHProxy, allowPla
 	}
 
 	start := time.Now()
-	passphrase := proxy.Passphrase
-	if passphrase != "" {
-		pp, xe := secret.Resolve(passphrase, secret.Options{AllowPlaintext: allowPlaintext})
-		if xe != nil {
-			return nil, xe
-		}
-		passphrase = pp
+	sshOpts, xe := sshProxyOptions(proxy, allowPlaintext, skipHostKey)
+	if xe != nil {
+		return nil, xe
 	}
 
-	client, xe := ssh.Connect(ctx, ssh.Options{
-		Host:                proxy.Host,
-		Port:                proxy.Port,
-		User:                proxy.User,
-		IdentityFile:        proxy.IdentityFile,
-		Passphrase:          passphrase,
-		KnownHostsFile:      proxy.KnownHostsFile,
-		SkipKnownHostsCheck: skipHostKey || proxy.SkipHostKey,
-	})
+	client, xe := ssh.Connect(ctx, sshOpts)
 	if xe != nil {
 		return nil, xe
 	}
 	defer func() { _ = client.Close() }()
 	latency := time.Since(start).Milliseconds()
 
-	return map[string]any{
+	result := map[string]any{
 		"ok":         true,
 		"latency_ms": latency,
 		"host":       proxy.Host,
 		"port":       proxy.Port,
 		"user":       proxy.User,
-	}, nil
+	}
+	if len(proxy.JumpHosts) > 0 {
+		jump := make([]string, 0, len(proxy.JumpHosts))
+		for _, hop := range proxy.JumpHosts {
+			jump = append(jump, hop.Name)
+		}
+		result["jump"] = jump
+	}
+	return result, nil
+}
+
+// hostKeyScanTimeout bounds reading the host key of one hop, including the
+// connection through the hops before it.
+const hostKeyScanTimeout = 15 * time.Second
+
+// TrustSSHProxyHostKeys checks the host keys of an SSH proxy and of its jump
+// hosts, first hop first, against their known_hosts files. Unknown keys that
+// confirm accepts are recorded; confirm returns the error to report otherwise.
+// A key that differs from the recorded one is never replaced.
+func TrustSSHProxyHostKeys(ctx context.Context, proxy config.SSHProxy, allowPlaintext bool, confirm func(*ssh.HostKey) *errors.XError) ([]map[string]any, *errors.XError) {
+	if proxy.Host == "" {
+		return nil, errors.New(errors.CodeCfgInvalid, "ssh host is required", nil)
+	}
+	sshOpts, xe := sshProxyOptions(proxy, allowPlaintext, false)
+	if xe != nil {
+		return nil, xe
+	}
+	target := sshOpts
+	target.Jump = nil
+	hops := append(append([]ssh.Options{}, sshOpts.Jump...), target)
+
+	hosts := make([]map[string]any, 0, len(hops))
+	for i, hop := range hops {
+		hop.Jump = hops[:i]
+		scanCtx, cancel := context.WithTimeout(ctx, hostKeyScanTimeout)
+		hk, xe := ssh.ScanHostKey(scanCtx, hop)
+		cancel()
+		if xe != nil {
+			return nil, xe
+		}
+		details := map[string]any{
+			"host":        hk.Host,
+			"port":        hk.Port,
+			"key_type":    hk.Type,
+			"fingerprint": hk.Fingerprint,
+			"known_hosts": hk.KnownHostsFile,
+		}
+		switch hk.Status {
+		case ssh.HostKeyChanged:
+			return nil, errors.New(errors.CodeSSHHostKeyMismatch, fmt.Sprintf("ssh host key of %s does not match known_hosts; remove the old entry (ssh-keygen -R) only if the change is expected", hk.Name), details)
+		case ssh.HostKeyUnknown:
+			if xe := confirm(hk); xe != nil {
+				return nil, xe
+			}
+			if xe := ssh.TrustHostKey(hk); xe != nil {
+				return nil, xe
+			}
+		}
+		hosts = append(hosts, map[string]any{
+			"name":             hk.Name,
+			"host":             hk.Host,
+			"port":             hk.Port,
+			"key_type":         hk.Type,
+			"fingerprint":      hk.Fingerprint,
+			"status":           hk.Status,
+			"known_hosts_file": hk.KnownHostsFile,
+		})
+	}
+	return hosts, nil
 }
 
 // TestAIConnection tests AI configuration and measures latency.
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -121,7 +122,7 @@ func TestLoadProfileDetail_ProfileNotFound(t *testing.T) {
 
 	profile, xe := ResolveProfile(cfg, "nonexistent")
 
-	if profile != (config.Profile{}) {
+	if !reflect.DeepEqual(profile, config.Profile{}) {
 		t.Fatal("expected zero profile")
 	}
 
@@ -231,7 +232,7 @@ func TestResolveProfile_NotFound(t *testing.T) {
 
 	profile, xe := ResolveProfile(cfg, "missing")
 
-	if profile != (config.Profile{}) {
+	if !reflect.DeepEqual(profile, config.Profile{}) {
 		t.Errorf("expected zero profile, got %+v", profile)
 	}
 
@@ -295,7 +296,7 @@ func TestResolveProfile_SSHProxyNotFound(t *testing.T) {
 
 	profile, xe := ResolveProfile(cfg, "remote")
 
-	if profile != (config.Profile{}) {
+	if !reflect.DeepEqual(profile, config.Profile{}) {
 		t.Errorf("expected zero profile, got %+v", profile)
 	}
 
@@ -1182,3 +1183,30 @@ func TestTestAIConnection_HttpError(t *testing.T) {
 		t.Fatal("expected error for 401 response")
 	}
 }
+
+func TestLoadProfileDetail_Extends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xsql.yaml")
+	content := `profiles:
+  base:
+    db: mysql
+    host: db.internal
+  child:
+    extends: base
+    database: shop
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, xe := LoadProfileDetail(config.Options{ConfigPath: path}, "child")
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if result["host"] != "db.internal" || result["database"] != "shop" || result["extends"] != "base" {
+		t.Fatalf("unexpected merged detail: %v", result)
+	}
+	provenance, ok := result["provenance"].(map[string]string)
+	if !ok || provenance["db"] != "base" || provenance["host"] != "base" || provenance["database"] != "child" {
+		t.Fatalf("unexpected provenance: %v", result["provenance"])
+	}
+}
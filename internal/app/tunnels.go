@@ -0,0 +1,320 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zx06/xsql/internal/config"
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/ssh"
+)
+
+// sshTunnelConnectTimeout bounds each SSH connection attempt of a shared tunnel.
+const sshTunnelConnectTimeout = 30 * time.Second
+
+// SSH tunnel states reported by SSHTunnels.Status.
+const (
+	TunnelConnecting      = "connecting"
+	TunnelConnected       = "connected"
+	TunnelDisconnected    = "disconnected"
+	TunnelReconnecting    = "reconnecting"
+	TunnelReconnectFailed = "reconnect_failed"
+	TunnelFailed          = "failed" // the initial connection failed; retried on next use
+)
+
+// SSHTunnelStatus describes one shared SSH tunnel.
+type SSHTunnelStatus struct {
+	SSHProxy   string    `json:"ssh_proxy" yaml:"ssh_proxy"`
+	Host       string    `json:"host" yaml:"host"`
+	Port       int       `json:"port" yaml:"port"`
+	State      string    `json:"state" yaml:"state"`
+	Since      time.Time `json:"since" yaml:"since"`
+	Reconnects int       `json:"reconnects" yaml:"reconnects"`
+	LastError  string    `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+}
+
+// SSHTunnels keeps one reconnecting SSH connection (ssh.ReconnectDialer) per
+// ssh proxy for long-lived processes such as the MCP server, the web server and
+// the TUI. Queries then reuse one SSH session instead of connecting each time,
+// and keepalive plus reconnection carry the session over bastion restarts.
+type SSHTunnels struct {
+	onStatus func(proxy string, ev ssh.StatusEvent)
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu      sync.Mutex
+	tunnels map[tunnelKey]*sshTunnel
+	retired map[*sshTunnel]struct{} // replaced tunnels still in use
+	closed  bool
+}
+
+// tunnelKey separates tunnels whose secrets or host key checks were resolved
+// differently, so one profile's opt-ins never apply to another's connections.
+type tunnelKey struct {
+	name             string
+	allowPlaintext   bool
+	skipHostKeyCheck bool
+}
+
+type sshTunnel struct {
+	key   tunnelKey
+	proxy config.SSHProxy
+
+	ready  chan struct{} // closed when the first connection attempt finishes
+	dialer *ssh.ReconnectDialer
+	err    *errors.XError
+
+	// Guarded by SSHTunnels.mu: the number of callers holding the dialer, and
+	// whether the tunnel was replaced and closes once that drops to zero.
+	users   int
+	retired bool
+
+	mu         sync.Mutex
+	state      string
+	since      time.Time
+	reconnects int
+	lastError  string
+}
+
+// NewSSHTunnels creates an empty tunnel set. onStatus, if set, receives the
+// status events of every tunnel (e.g. to log them). Close releases the tunnels.
+func NewSSHTunnels(onStatus func(proxy string, ev ssh.StatusEvent)) *SSHTunnels {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SSHTunnels{
+		onStatus: onStatus,
+		ctx:      ctx,
+		cancel:   cancel,
+		tunnels:  map[tunnelKey]*sshTunnel{},
+		retired:  map[*sshTunnel]struct{}{},
+	}
+}
+
+// Dialer returns the shared dialer for proxy, connecting on first use, and a
+// release func to call once the caller stops dialing through it. A tunnel whose
+// proxy configuration changed since it was opened is replaced; the old one stays
+// open until its last caller releases it, so queries in flight are not cut off.
+// A tunnel whose initial connection failed is retried. ctx bounds only the wait.
+func (t *SSHTunnels) Dialer(ctx context.Context, proxy config.SSHProxy, allowPlaintext, skipHostKeyCheck bool) (*ssh.ReconnectDialer, func(), *errors.XError) {
+	key := tunnelKey{name: tunnelName(proxy), allowPlaintext: allowPlaintext, skipHostKeyCheck: skipHostKeyCheck}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, nil, errors.New(errors.CodeInternal, "ssh tunnels are closed", map[string]any{"ssh_proxy": key.name})
+	}
+	tun := t.tunnels[key]
+	if tun != nil && (!reflect.DeepEqual(tun.proxy, proxy) || tun.failed()) {
+		t.retire(tun)
+		tun = nil
+	}
+	if tun == nil {
+		tun = &sshTunnel{key: key, proxy: proxy, ready: make(chan struct{}), state: TunnelConnecting, since: time.Now()}
+		t.tunnels[key] = tun
+		go t.open(tun)
+	}
+	tun.users++
+	t.mu.Unlock()
+
+	var once sync.Once
+	release := func() { once.Do(func() { t.release(tun) }) }
+
+	select {
+	case <-tun.ready:
+	case <-ctx.Done():
+		release()
+		return nil, nil, errors.Wrap(errors.CodeSSHDialFailed, "timed out waiting for ssh connection", map[string]any{"ssh_proxy": key.name, "host": proxy.Host}, ctx.Err())
+	}
+	if tun.err != nil {
+		release()
+		return nil, nil, tun.err
+	}
+	return tun.dialer, release, nil
+}
+
+// retire removes tun from the set; it is closed once no caller holds it.
+// t.mu must be held.
+func (t *SSHTunnels) retire(tun *sshTunnel) {
+	delete(t.tunnels, tun.key)
+	tun.retired = true
+	if tun.users == 0 {
+		tun.close()
+		return
+	}
+	t.retired[tun] = struct{}{}
+}
+
+// release drops one caller of tun, closing it if it was the last one of a
+// replaced tunnel.
+func (t *SSHTunnels) release(tun *sshTunnel) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tun.users--
+	if tun.retired && tun.users == 0 {
+		delete(t.retired, tun)
+		tun.close()
+	}
+}
+
+// open makes the first connection of tun.
+func (t *SSHTunnels) open(tun *sshTunnel) {
+	sshOpts, xe := sshProxyOptions(tun.proxy, tun.key.allowPlaintext, tun.key.skipHostKeyCheck)
+	if xe == nil {
+		onStatus := func(ev ssh.StatusEvent) {
+			tun.record(ev)
+			if t.onStatus != nil {
+				t.onStatus(tun.key.name, ev)
+			}
+		}
+		rd, err := ssh.NewReconnectDialer(t.ctx, sshOpts, ssh.WithStatusCallback(onStatus), ssh.WithConnectTimeout(sshTunnelConnectTimeout))
+		if err != nil {
+			var ok bool
+			if xe, ok = err.(*errors.XError); !ok {
+				xe = errors.Wrap(errors.CodeSSHDialFailed, "failed to establish reconnectable ssh connection", map[string]any{"host": tun.proxy.Host}, err)
+			}
+		}
+		tun.dialer = rd
+	}
+	if xe != nil {
+		tun.err = xe
+		tun.mu.Lock()
+		tun.state, tun.since, tun.lastError = TunnelFailed, time.Now(), xe.Error()
+		tun.mu.Unlock()
+		close(tun.ready)
+		return
+	}
+	close(tun.ready)
+
+	t.mu.Lock()
+	stale := t.closed || (tun.retired && tun.users == 0)
+	t.mu.Unlock()
+	if stale {
+		// Closed, or replaced and released, while connecting.
+		_ = tun.dialer.Close()
+	}
+}
+
+// record tracks the state of tun from its status events.
+func (tun *sshTunnel) record(ev ssh.StatusEvent) {
+	tun.mu.Lock()
+	defer tun.mu.Unlock()
+	state := tun.state
+	switch ev.Type {
+	case ssh.StatusConnected:
+		state = TunnelConnected
+	case ssh.StatusReconnected:
+		state = TunnelConnected
+		tun.reconnects++
+	case ssh.StatusDisconnected:
+		state = TunnelDisconnected
+	case ssh.StatusReconnecting:
+		state = TunnelReconnecting
+	case ssh.StatusReconnectFailed:
+		state = TunnelReconnectFailed
+	}
+	if state != tun.state {
+		tun.state, tun.since = state, time.Now()
+	}
+	if ev.Error != nil {
+		tun.lastError = ev.Error.Error()
+	}
+}
+
+// failed reports whether the first connection attempt of tun failed.
+func (tun *sshTunnel) failed() bool {
+	select {
+	case <-tun.ready:
+		return tun.err != nil
+	default:
+		return false
+	}
+}
+
+// close closes the dialer of tun once connected; a tunnel that is still
+// connecting is closed by open.
+func (tun *sshTunnel) close() {
+	select {
+	case <-tun.ready:
+		if tun.dialer != nil {
+			_ = tun.dialer.Close()
+		}
+	default:
+	}
+}
+
+// Status lists the tunnels, sorted by ssh proxy.
+func (t *SSHTunnels) Status() []SSHTunnelStatus {
+	t.mu.Lock()
+	tunnels := make([]*sshTunnel, 0, len(t.tunnels))
+	for _, tun := range t.tunnels {
+		tunnels = append(tunnels, tun)
+	}
+	t.mu.Unlock()
+
+	out := make([]SSHTunnelStatus, 0, len(tunnels))
+	for _, tun := range tunnels {
+		port := tun.proxy.Port
+		if port == 0 {
+			port = 22
+		}
+		tun.mu.Lock()
+		out = append(out, SSHTunnelStatus{
+			SSHProxy:   tun.key.name,
+			Host:       tun.proxy.Host,
+			Port:       port,
+			State:      tun.state,
+			Since:      tun.since,
+			Reconnects: tun.reconnects,
+			LastError:  tun.lastError,
+		})
+		tun.mu.Unlock()
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].SSHProxy < out[j].SSHProxy })
+	return out
+}
+
+// Close closes every tunnel, including replaced ones still in use; later Dialer
+// calls fail.
+func (t *SSHTunnels) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	tunnels := make([]*sshTunnel, 0, len(t.tunnels)+len(t.retired))
+	for _, tun := range t.tunnels {
+		tunnels = append(tunnels, tun)
+	}
+	for tun := range t.retired {
+		tunnels = append(tunnels, tun)
+	}
+	t.tunnels = map[tunnelKey]*sshTunnel{}
+	t.retired = map[*sshTunnel]struct{}{}
+	t.mu.Unlock()
+
+	t.cancel()
+	for _, tun := range tunnels {
+		tun.close()
+	}
+	return nil
+}
+
+// tunnelName identifies proxy: its ssh_proxies name, or its address for
+// proxies defined inline.
+func tunnelName(proxy config.SSHProxy) string {
+	if proxy.Name != "" {
+		return proxy.Name
+	}
+	port := proxy.Port
+	if port == 0 {
+		port = 22
+	}
+	if proxy.User != "" {
+		return fmt.Sprintf("%s@%s:%d", proxy.User, proxy.Host, port)
+	}
+	return fmt.Sprintf("%s:%d", proxy.Host, port)
+}
@@ -0,0 +1,265 @@
+package app
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/zx06/xsql/internal/config"
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/ssh"
+)
+
+// startForwardingSSHServer runs an SSH server that accepts any password and
+// forwards direct-tcpip channels. It returns its address and a counter of the
+// SSH sessions it accepted.
+func startForwardingSSHServer(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &gossh.ServerConfig{
+		PasswordCallback: func(gossh.ConnMetadata, []byte) (*gossh.Permissions, error) { return nil, nil },
+	}
+	cfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	var sessions atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := gossh.NewServerConn(conn, cfg)
+				if err != nil {
+					return
+				}
+				sessions.Add(1)
+				go gossh.DiscardRequests(reqs)
+				for nc := range chans {
+					var dest struct {
+						Host     string
+						Port     uint32
+						OrigHost string
+						OrigPort uint32
+					}
+					if nc.ChannelType() != "direct-tcpip" || gossh.Unmarshal(nc.ExtraData(), &dest) != nil {
+						_ = nc.Reject(gossh.UnknownChannelType, "unsupported")
+						continue
+					}
+					target, err := net.Dial("tcp", net.JoinHostPort(dest.Host, strconv.Itoa(int(dest.Port))))
+					if err != nil {
+						_ = nc.Reject(gossh.ConnectionFailed, err.Error())
+						continue
+					}
+					ch, chReqs, err := nc.Accept()
+					if err != nil {
+						_ = target.Close()
+						continue
+					}
+					go gossh.DiscardRequests(chReqs)
+					go func() {
+						_, _ = io.Copy(ch, target)
+						_ = ch.Close()
+					}()
+					go func() {
+						_, _ = io.Copy(target, ch)
+						_ = target.Close()
+					}()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), &sessions
+}
+
+func TestSSHTunnels_SharesConnection(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSH_AUTH_SOCK", "")
+	addr, sessions := startForwardingSSHServer(t)
+	host, portStr, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portStr)
+
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			c, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(c, c)
+				_ = c.Close()
+			}()
+		}
+	}()
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	tunnels := NewSSHTunnels(func(proxy string, ev ssh.StatusEvent) {
+		mu.Lock()
+		events = append(events, proxy)
+		mu.Unlock()
+	})
+	defer tunnels.Close()
+
+	proxy := config.SSHProxy{Name: "bastion", Host: host, Port: port, User: "u", Password: "pw", IdentityAgent: "none"}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Concurrent first uses share one connection.
+	dialers := make([]*ssh.ReconnectDialer, 4)
+	releases := make([]func(), len(dialers))
+	var wg sync.WaitGroup
+	for i := range dialers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d, release, xe := tunnels.Dialer(ctx, proxy, true, true)
+			if xe != nil {
+				t.Errorf("dialer: %v", xe)
+			}
+			dialers[i], releases[i] = d, release
+		}(i)
+	}
+	wg.Wait()
+	for _, d := range dialers[1:] {
+		if d != dialers[0] {
+			t.Fatal("expected one shared dialer")
+		}
+	}
+	if n := sessions.Load(); n != 1 {
+		t.Errorf("ssh sessions = %d, want 1", n)
+	}
+
+	conn, err := dialers[0].DialContext(ctx, "tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("ping")
+	buf := make([]byte, len(msg))
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("echo = %q, %v", buf, err)
+	}
+	_ = conn.Close()
+
+	st := tunnels.Status()
+	if len(st) != 1 || st[0].SSHProxy != "bastion" || st[0].State != TunnelConnected || st[0].Port != port {
+		t.Errorf("status = %+v", st)
+	}
+	mu.Lock()
+	if len(events) == 0 || events[0] != "bastion" {
+		t.Errorf("status events = %v", events)
+	}
+	mu.Unlock()
+
+	// A changed proxy configuration replaces the tunnel; the old one stays
+	// open until its last user releases it.
+	proxy.User = "other"
+	d, release, xe := tunnels.Dialer(ctx, proxy, true, true)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	defer release()
+	if d == dialers[0] {
+		t.Error("expected a new dialer after the proxy changed")
+	}
+	for _, release := range releases[1:] {
+		release()
+	}
+	conn, err = dialers[0].DialContext(ctx, "tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatalf("replaced dialer closed while in use: %v", err)
+	}
+	_ = conn.Close()
+	releases[0]()
+	releases[0]() // releasing twice is harmless
+	if _, err := dialers[0].DialContext(ctx, "tcp", echo.Addr().String()); err == nil {
+		t.Error("expected the replaced dialer to be closed after its last release")
+	}
+	if st := tunnels.Status(); len(st) != 1 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// Different opt-ins do not share a tunnel.
+	if _, _, xe := tunnels.Dialer(ctx, proxy, false, true); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("plaintext password without opt-in: %v", xe)
+	}
+	failed := 0
+	st = tunnels.Status()
+	for _, s := range st {
+		if s.State == TunnelFailed {
+			failed++
+		}
+	}
+	if len(st) != 2 || failed != 1 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// Close also closes replaced tunnels that are still in use.
+	proxy.User = "third"
+	if _, _, xe := tunnels.Dialer(ctx, proxy, true, true); xe != nil {
+		t.Fatal(xe)
+	}
+	_ = tunnels.Close()
+	if _, err := d.DialContext(ctx, "tcp", echo.Addr().String()); err == nil {
+		t.Error("expected Close to close the replaced dialer still in use")
+	}
+	if _, _, xe := tunnels.Dialer(ctx, proxy, true, true); xe == nil {
+		t.Error("expected closed tunnels to refuse dialers")
+	}
+}
+
+func TestSSHTunnels_RetriesFailedConnection(t *testing.T) {
+	tunnels := NewSSHTunnels(nil)
+	defer tunnels.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	proxy := config.SSHProxy{Host: "127.0.0.1", Port: port, Password: "pw", IdentityAgent: "none"}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if _, _, xe := tunnels.Dialer(ctx, proxy, true, true); xe == nil || xe.Code != errors.CodeSSHDialFailed {
+			t.Fatalf("attempt %d: got %v, want %s", i+1, xe, errors.CodeSSHDialFailed)
+		}
+	}
+	st := tunnels.Status()
+	if len(st) != 1 || st[0].State != TunnelFailed || st[0].LastError == "" || st[0].SSHProxy != "127.0.0.1:"+strconv.Itoa(port) {
+		t.Errorf("status = %+v", st)
+	}
+}
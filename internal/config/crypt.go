@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/secret"
+)
+
+// Config files may be encrypted as a whole (the file is one ENC[...] envelope
+// of type "file") or value by value. Encrypted values in secret fields such as
+// profiles.<name>.password stay encrypted after loading and are decrypted by
+// secret.Resolve when used, so they count as references rather than plaintext;
+// all other encrypted values are decrypted on load.
+
+const fileEnvelopeType = "file"
+
+// readConfigBytes reads a config file, decrypting it when the whole file is
+// one envelope. encrypted reports whether it was.
+func readConfigBytes(path string) (b []byte, encrypted bool, xe *errors.XError) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, errors.New(errors.CodeCfgNotFound, "config file not found", map[string]any{"path": path})
+		}
+		return nil, false, errors.Wrap(errors.CodeCfgInvalid, "failed to read config file", map[string]any{"path": path}, err)
+	}
+	text := string(bytes.TrimSpace(b))
+	if !secret.IsEncrypted(text) {
+		return b, false, nil
+	}
+	key, xe := secret.LoadKey(nil)
+	if xe != nil {
+		return nil, false, withDetail(xe, "path", path)
+	}
+	plain, typ, xe := key.Decrypt(text)
+	if xe != nil {
+		return nil, false, withDetail(xe, "path", path)
+	}
+	if typ != fileEnvelopeType {
+		return nil, false, errors.New(errors.CodeCfgInvalid, "encrypted config file has the wrong type",
+			map[string]any{"path": path, "type": typ})
+	}
+	return []byte(plain), true, nil
+}
+
+// encryptedValue records a scalar that held an envelope before decryption.
+type encryptedValue struct {
+	envelope string
+	plain    string
+	style    yaml.Style
+}
+
+// decryptNodes decrypts the encrypted scalars under root, except those in
+// secret fields, and returns what they held. The key is only loaded when an
+// envelope is found.
+func decryptNodes(root *yaml.Node) (map[*yaml.Node]encryptedValue, *errors.XError) {
+	var key *secret.Key
+	found := map[*yaml.Node]encryptedValue{}
+	var walk func(n *yaml.Node, path []string) *errors.XError
+	walk = func(n *yaml.Node, path []string) *errors.XError {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, c := range n.Content {
+				if xe := walk(c, path); xe != nil {
+					return xe
+				}
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if xe := walk(n.Content[i+1], append(path, n.Content[i].Value)); xe != nil {
+					return xe
+				}
+			}
+		case yaml.ScalarNode:
+			if !secret.IsEncrypted(n.Value) || isSecretPath(path) {
+				return nil
+			}
+			if key == nil {
+				k, xe := secret.LoadKey(nil)
+				if xe != nil {
+					return xe
+				}
+				key = k
+			}
+			plain, typ, xe := key.Decrypt(n.Value)
+			if xe != nil {
+				return withDetail(xe, "key", strings.Join(path, "."))
+			}
+			switch typ {
+			case "str", "int", "float", "bool":
+			default:
+				return errors.New(errors.CodeCfgInvalid, "encrypted value has an unsupported type",
+					map[string]any{"key": strings.Join(path, "."), "type": typ})
+			}
+			found[n] = encryptedValue{envelope: n.Value, plain: plain, style: n.Style}
+			n.Value, n.Tag, n.Style = plain, "!!"+typ, 0
+		}
+		return nil
+	}
+	if xe := walk(root, nil); xe != nil {
+		return nil, xe
+	}
+	return found, nil
+}
+
+// reencryptNodes puts back the envelopes recorded by decryptNodes. Unchanged
+// values keep their original envelope; changed values are encrypted anew.
+func reencryptNodes(nodes map[*yaml.Node]encryptedValue) *errors.XError {
+	var key *secret.Key
+	for n, enc := range nodes {
+		if n.Kind != yaml.ScalarNode || n.ShortTag() == "!!null" {
+			continue
+		}
+		value := enc.envelope
+		if n.Value != enc.plain {
+			if key == nil {
+				k, xe := secret.LoadKey(nil)
+				if xe != nil {
+					return xe
+				}
+				key = k
+			}
+			v, xe := key.Encrypt(n.Value, strings.TrimPrefix(n.ShortTag(), "!!"))
+			if xe != nil {
+				return xe
+			}
+			value = v
+		}
+		n.Value, n.Tag, n.Style = value, "!!str", enc.style
+	}
+	return nil
+}
+
+// EncryptValue returns the envelope for a string value, to be pasted into a
+// config file.
+func EncryptValue(plaintext string) (string, *errors.XError) {
+	key, xe := secret.LoadKey(nil)
+	if xe != nil {
+		return "", xe
+	}
+	return key.Encrypt(plaintext, "str")
+}
+
+// EncryptConfigFile encrypts the whole config file at path in place.
+func EncryptConfigFile(path string) *errors.XError {
+	b, encrypted, xe := readConfigBytes(path)
+	if xe != nil {
+		return xe
+	}
+	if encrypted {
+		return errors.New(errors.CodeCfgInvalid, "config file is already encrypted", map[string]any{"path": path})
+	}
+	// Refuse to encrypt a file the loader could not read back.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return errors.Wrap(errors.CodeCfgInvalid, "invalid config file", map[string]any{"path": path}, err)
+	}
+	out, xe := encryptFileBytes(b)
+	if xe != nil {
+		return xe
+	}
+	return writeConfigBytes(path, out)
+}
+
+// DecryptConfigFile replaces a whole-file envelope at path by its plaintext.
+// Encrypted values inside the file are left as they are.
+func DecryptConfigFile(path string) *errors.XError {
+	b, encrypted, xe := readConfigBytes(path)
+	if xe != nil {
+		return xe
+	}
+	if !encrypted {
+		return errors.New(errors.CodeCfgInvalid, "config file is not encrypted", map[string]any{"path": path})
+	}
+	return writeConfigBytes(path, b)
+}
+
+func encryptFileBytes(b []byte) ([]byte, *errors.XError) {
+	key, xe := secret.LoadKey(nil)
+	if xe != nil {
+		return nil, xe
+	}
+	env, xe := key.Encrypt(string(b), fileEnvelopeType)
+	if xe != nil {
+		return nil, xe
+	}
+	return []byte(env + "\n"), nil
+}
+
+func writeConfigBytes(path string, b []byte) *errors.XError {
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		return errors.Wrap(errors.CodeInternal, "failed to write config file", map[string]any{"path": path}, err)
+	}
+	return nil
+}
+
+func withDetail(xe *errors.XError, key string, value any) *errors.XError {
+	if xe.Details == nil {
+		xe.Details = map[string]any{}
+	}
+	xe.Details[key] = value
+	return xe
+}
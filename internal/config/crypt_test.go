@@ -0,0 +1,148 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/secret"
+)
+
+func setupConfigKey(t *testing.T) *secret.Key {
+	t.Helper()
+	text, xe := secret.GenerateKey()
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(secret.KeyFileEnv, path)
+	key, xe := secret.ParseKey(text, path)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	return key
+}
+
+func mustEncrypt(t *testing.T, key *secret.Key, plain, typ string) string {
+	t.Helper()
+	env, xe := key.Encrypt(plain, typ)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	return env
+}
+
+func TestLoadConfig_EncryptedValues(t *testing.T) {
+	key := setupConfigKey(t)
+	path := writeConfig(t, fmt.Sprintf("profiles:\n  dev:\n    db: pg\n    host: %s\n    port: %s\n    password: %s\n",
+		mustEncrypt(t, key, "db.internal", "str"),
+		mustEncrypt(t, key, "6432", "int"),
+		mustEncrypt(t, key, "s3cret", "str")))
+
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	p := cfg.Profiles["dev"]
+	if p.Host != "db.internal" || p.Port != 6432 {
+		t.Errorf("expected decrypted host and port, got %q %d", p.Host, p.Port)
+	}
+	// Secret fields stay encrypted until used.
+	if !secret.IsEncrypted(p.Password) {
+		t.Fatalf("expected password to stay encrypted, got %q", p.Password)
+	}
+	if pw, xe := secret.Resolve(p.Password, secret.Options{}); xe != nil || pw != "s3cret" {
+		t.Errorf("Resolve = %q, %v", pw, xe)
+	}
+	for _, is := range Validate(cfg, ValidateOptions{}) {
+		if is.Code == "plaintext_secret" {
+			t.Errorf("encrypted password reported as plaintext: %+v", is)
+		}
+	}
+
+	t.Setenv(secret.KeyFileEnv, filepath.Join(t.TempDir(), "missing"))
+	if _, _, xe := LoadConfig(Options{ConfigPath: path}); xe == nil || xe.Details["path"] != path {
+		t.Errorf("expected key error with path, got %v", xe)
+	}
+}
+
+func TestWriteFile_KeepsEncryptedValues(t *testing.T) {
+	key := setupConfigKey(t)
+	hostEnv := mustEncrypt(t, key, "db.internal", "str")
+	pwEnv := mustEncrypt(t, key, "s3cret", "str")
+	path := writeConfig(t, fmt.Sprintf("profiles:\n  dev:\n    db: pg\n    host: %s\n    password: %s\n", hostEnv, pwEnv))
+
+	if xe := SetConfigValue(path, "profile.dev.database", "app"); xe != nil {
+		t.Fatal(xe)
+	}
+	b, _ := os.ReadFile(path)
+	if !bytes.Contains(b, []byte(hostEnv)) || !bytes.Contains(b, []byte(pwEnv)) || bytes.Contains(b, []byte("db.internal")) {
+		t.Fatalf("unchanged encrypted values should be kept as written:\n%s", b)
+	}
+
+	if xe := SetConfigValue(path, "profile.dev.host", "db2.internal"); xe != nil {
+		t.Fatal(xe)
+	}
+	b, _ = os.ReadFile(path)
+	if bytes.Contains(b, []byte("db2.internal")) || bytes.Contains(b, []byte(hostEnv)) {
+		t.Fatalf("changed encrypted value should be re-encrypted:\n%s", b)
+	}
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if got := cfg.Profiles["dev"]; got.Host != "db2.internal" || got.Database != "app" {
+		t.Errorf("unexpected profile after writes: %+v", got)
+	}
+}
+
+func TestEncryptConfigFile(t *testing.T) {
+	setupConfigKey(t)
+	path := writeConfig(t, "# team config\nprofiles:\n  dev:\n    db: mysql\n    password: hunter2\n    allow_plaintext: true\n")
+
+	if xe := EncryptConfigFile(path); xe != nil {
+		t.Fatal(xe)
+	}
+	b, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(b), "ENC[AES256_GCM,") || bytes.Contains(b, []byte("hunter2")) {
+		t.Fatalf("expected an encrypted file, got:\n%s", b)
+	}
+	if xe := EncryptConfigFile(path); xe == nil {
+		t.Error("expected error encrypting twice")
+	}
+
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if cfg.Profiles["dev"].Password != "hunter2" {
+		t.Errorf("unexpected profile %+v", cfg.Profiles["dev"])
+	}
+
+	// Writes keep the file encrypted.
+	if xe := SaveProfile(path, "prod", Profile{DB: "pg", Host: "db.internal"}); xe != nil {
+		t.Fatal(xe)
+	}
+	b, _ = os.ReadFile(path)
+	if !strings.HasPrefix(string(b), "ENC[AES256_GCM,") {
+		t.Fatalf("write should keep the file encrypted, got:\n%s", b)
+	}
+
+	if xe := DecryptConfigFile(path); xe != nil {
+		t.Fatal(xe)
+	}
+	b, _ = os.ReadFile(path)
+	if !bytes.HasPrefix(b, []byte("# team config\n")) || !bytes.Contains(b, []byte("db.internal")) {
+		t.Errorf("unexpected decrypted file:\n%s", b)
+	}
+	if xe := DecryptConfigFile(path); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("expected error decrypting a plaintext file, got %v", xe)
+	}
+}
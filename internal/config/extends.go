@@ -0,0 +1,170 @@
+package config
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// resolveExtends merges profiles that declare `extends: <base>` with their base
+// profiles, in place on the parsed YAML document. Merging happens on YAML nodes
+// rather than decoded structs so explicit overrides such as `allow_plaintext: false`
+// or `port: 0` in a child are kept.
+//
+// It returns, for every profile that uses extends, the profile each key was taken from.
+// With onError set, a profile whose extends cannot be resolved is passed to it and
+// left unmerged.
+func resolveExtends(doc *yaml.Node, onError func(name string, xe *errors.XError)) (map[string]map[string]string, *errors.XError) {
+	profilesNode := mappingValue(documentRoot(doc), "profiles")
+	if profilesNode == nil || profilesNode.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	raw := make(map[string]*yaml.Node, len(profilesNode.Content)/2)
+	for i := 0; i+1 < len(profilesNode.Content); i += 2 {
+		raw[profilesNode.Content[i].Value] = profilesNode.Content[i+1]
+	}
+
+	type merged struct {
+		node    *yaml.Node
+		sources map[string]string
+	}
+	done := make(map[string]merged, len(raw))
+
+	var resolve func(name string, chain []string) (merged, *errors.XError)
+	resolve = func(name string, chain []string) (merged, *errors.XError) {
+		if m, ok := done[name]; ok {
+			return m, nil
+		}
+		for _, c := range chain {
+			if c == name {
+				return merged{}, errors.New(errors.CodeCfgInvalid, "profile extends cycle", map[string]any{
+					"profile": chain[0],
+					"chain":   strings.Join(append(chain, name), " -> "),
+				})
+			}
+		}
+		node := raw[name]
+		sources := map[string]string{}
+		base := ""
+		if node != nil && node.Kind == yaml.MappingNode {
+			if v := mappingValue(node, "extends"); v != nil {
+				base = strings.TrimSpace(v.Value)
+			}
+		}
+		if base == "" {
+			if node != nil && node.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					sources[node.Content[i].Value] = name
+				}
+			}
+			m := merged{node: node, sources: sources}
+			done[name] = m
+			return m, nil
+		}
+
+		if _, ok := raw[base]; !ok {
+			return merged{}, errors.New(errors.CodeCfgInvalid, "extended profile not found", map[string]any{
+				"profile": name,
+				"extends": base,
+			})
+		}
+		parent, xe := resolve(base, append(chain, name))
+		if xe != nil {
+			return merged{}, xe
+		}
+
+		out := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: node.Line, Column: node.Column}
+		var parentContent []*yaml.Node
+		if parent.node != nil && parent.node.Kind == yaml.MappingNode {
+			parentContent = parent.node.Content
+		}
+		for i := 0; i+1 < len(parentContent); i += 2 {
+			key := parentContent[i].Value
+			if key == "extends" {
+				continue
+			}
+			out.Content = append(out.Content, parentContent[i], parentContent[i+1])
+			sources[key] = parent.sources[key]
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i], node.Content[i+1]
+			setMappingValue(out, key, val)
+			sources[key.Value] = name
+		}
+		m := merged{node: out, sources: sources}
+		done[name] = m
+		return m, nil
+	}
+
+	provenance := map[string]map[string]string{}
+	for i := 0; i+1 < len(profilesNode.Content); i += 2 {
+		name := profilesNode.Content[i].Value
+		node := profilesNode.Content[i+1]
+		if node.Kind != yaml.MappingNode || mappingValue(node, "extends") == nil {
+			continue
+		}
+		m, xe := resolve(name, nil)
+		if xe != nil {
+			if onError == nil {
+				return nil, xe
+			}
+			onError(name, xe)
+			continue
+		}
+		provenance[name] = m.sources
+	}
+	// Replace nodes only after every profile is resolved so bases are read unmerged.
+	for i := 0; i+1 < len(profilesNode.Content); i += 2 {
+		name := profilesNode.Content[i].Value
+		if _, ok := provenance[name]; ok {
+			profilesNode.Content[i+1] = done[name].node
+		}
+	}
+	return provenance, nil
+}
+
+// documentRoot returns the top-level mapping of a parsed YAML document.
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc != nil && doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		return doc.Content[0]
+	}
+	return doc
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key to val in a mapping node. Nested mappings are merged
+// so a child can override a single entry of a map-valued field.
+func setMappingValue(m *yaml.Node, key, val *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != key.Value {
+			continue
+		}
+		if existing := m.Content[i+1]; existing.Kind == yaml.MappingNode && val.Kind == yaml.MappingNode {
+			mergedMap := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: val.Line, Column: val.Column}
+			mergedMap.Content = append(mergedMap.Content, existing.Content...)
+			for j := 0; j+1 < len(val.Content); j += 2 {
+				setMappingValue(mergedMap, val.Content[j], val.Content[j+1])
+			}
+			m.Content[i+1] = mergedMap
+			return
+		}
+		m.Content[i+1] = val
+		return
+	}
+	m.Content = append(m.Content, key, val)
+}
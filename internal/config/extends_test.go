@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "xsql.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_ProfileExtends(t *testing.T) {
+	path := writeConfig(t, `ssh_proxies:
+  bastion:
+    host: bastion.example.com
+profiles:
+  base:
+    db: pg
+    host: db.internal
+    user: app
+    ssh_proxy: bastion
+    allow_plaintext: true
+    query_timeout: 60
+  reporting:
+    extends: base
+    database: reports
+  orders:
+    extends: reporting
+    database: orders
+    allow_plaintext: false
+`)
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+
+	orders := cfg.Profiles["orders"]
+	if orders.DB != "pg" || orders.Host != "db.internal" || orders.User != "app" || orders.SSHProxy != "bastion" || orders.QueryTimeout != 60 {
+		t.Fatalf("inherited fields missing: %+v", orders)
+	}
+	if orders.Database != "orders" || orders.Extends != "reporting" {
+		t.Fatalf("override not applied: %+v", orders)
+	}
+	if orders.AllowPlaintext {
+		t.Fatal("explicit false in child must override base true")
+	}
+	if !cfg.Profiles["reporting"].AllowPlaintext || cfg.Profiles["reporting"].Database != "reports" {
+		t.Fatalf("unexpected reporting profile: %+v", cfg.Profiles["reporting"])
+	}
+	if base := cfg.Profiles["base"]; base.Database != "" || base.Extends != "" {
+		t.Fatalf("base profile must not be modified: %+v", base)
+	}
+
+	src := cfg.ProfileSources["orders"]
+	want := map[string]string{"host": "base", "query_timeout": "base", "database": "orders", "allow_plaintext": "orders", "extends": "orders"}
+	for k, v := range want {
+		if src[k] != v {
+			t.Errorf("provenance[%s] = %q, want %q", k, src[k], v)
+		}
+	}
+	if _, ok := cfg.ProfileSources["base"]; ok {
+		t.Error("profiles without extends should have no provenance")
+	}
+}
+
+func TestLoadConfig_ProfileExtendsErrors(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		message string
+	}{
+		"missing_base": {`profiles:
+  a:
+    extends: nope
+`, "extended profile not found"},
+		"cycle": {`profiles:
+  a:
+    extends: b
+  b:
+    extends: a
+`, "profile extends cycle"},
+		"self": {`profiles:
+  a:
+    extends: a
+`, "profile extends cycle"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, xe := LoadConfig(Options{ConfigPath: writeConfig(t, tt.content)})
+			if xe == nil || xe.Code != errors.CodeCfgInvalid || !strings.Contains(xe.Message, tt.message) {
+				t.Fatalf("expected %q, got %v", tt.message, xe)
+			}
+		})
+	}
+}
+
+func TestSaveProfile_KeepsExtendsUnmerged(t *testing.T) {
+	path := writeConfig(t, `profiles:
+  base:
+    db: mysql
+    host: db.internal
+  child:
+    extends: base
+    database: app
+`)
+	if xe := SaveProfile(path, "other", Profile{DB: "pg", Host: "h"}); xe != nil {
+		t.Fatal(xe)
+	}
+	raw, xe := readRawFile(path)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if child := raw.Profiles["child"]; child.Host != "" || child.Extends != "base" {
+		t.Fatalf("inherited values must not be written into child: %+v", child)
+	}
+}
+
+func TestLoadConfig_EmptyFile(t *testing.T) {
+	cfg, _, xe := LoadConfig(Options{ConfigPath: writeConfig(t, "")})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if cfg.Profiles == nil || cfg.SSHProxies == nil {
+		t.Fatal("expected initialized maps")
+	}
+}
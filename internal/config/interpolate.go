@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// expandEnvEnabled reports whether the document opts out via `expand_env: false`.
+func expandEnvEnabled(doc *yaml.Node) bool {
+	v := mappingValue(documentRoot(doc), "expand_env")
+	if v == nil {
+		return true
+	}
+	var enabled bool
+	if err := v.Decode(&enabled); err != nil {
+		return true
+	}
+	return enabled
+}
+
+// expandEnvNodes replaces ${VAR} and ${VAR:-default} in every scalar value of the
+// document. Mapping keys are left untouched. Plain (unquoted) scalars are re-typed
+// after expansion so `port: ${DB_PORT}` still decodes into an int. With onError
+// set, an invalid reference is passed to it and left as written.
+func expandEnvNodes(n *yaml.Node, onError func(*errors.XError)) *errors.XError {
+	if n == nil {
+		return nil
+	}
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "$") {
+			return nil
+		}
+		v, err := expandEnv(n.Value, os.LookupEnv)
+		if err != nil {
+			// The value itself is not reported since it may hold a secret.
+			xe := errors.New(errors.CodeCfgInvalid, "invalid environment variable reference", map[string]any{
+				"line":   n.Line,
+				"reason": err.Error(),
+			})
+			if onError == nil {
+				return xe
+			}
+			onError(xe)
+			return nil
+		}
+		if v != n.Value {
+			n.Value = v
+			if n.Style == 0 {
+				n.Tag = ""
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if xe := expandEnvNodes(n.Content[i], onError); xe != nil {
+				return xe
+			}
+		}
+	default:
+		for _, c := range n.Content {
+			if xe := expandEnvNodes(c, onError); xe != nil {
+				return xe
+			}
+		}
+	}
+	return nil
+}
+
+// isOpaqueEnvRef reports whether n is an unexpanded ${VAR} reference standing
+// for a value of non-string type t, e.g. `port: ${DB_PORT}`. Such a value cannot
+// be decoded as written.
+func isOpaqueEnvRef(n *yaml.Node, t reflect.Type) bool {
+	if n == nil || n.Kind != yaml.ScalarNode || !strings.Contains(n.Value, "${") || t == nil {
+		return false
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() != reflect.String && t.Kind() != reflect.Interface
+}
+
+// maskEnvRefs nulls the opaque env references in n, described by type t, so the
+// unexpanded document decodes. writeFile keeps the references on disk.
+func maskEnvRefs(n *yaml.Node, t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if n == nil || t == nil {
+		return
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			maskEnvRefs(c, t)
+		}
+	case yaml.ScalarNode:
+		if isOpaqueEnvRef(n, t) {
+			n.Value, n.Tag, n.Style = "", "!!null", 0
+		}
+	case yaml.SequenceNode:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for _, c := range n.Content {
+				maskEnvRefs(c, t.Elem())
+			}
+		}
+	case yaml.MappingNode:
+		var fields map[string]reflect.Type
+		if t.Kind() == reflect.Struct {
+			fields = yamlFields(t)
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			switch t.Kind() {
+			case reflect.Struct:
+				maskEnvRefs(n.Content[i+1], fields[n.Content[i].Value])
+			case reflect.Map:
+				maskEnvRefs(n.Content[i+1], t.Elem())
+			}
+		}
+	}
+}
+
+// expandEnv expands ${VAR} and ${VAR:-default} in s. Unset variables expand to
+// the empty string; ":-" also uses the default when the variable is set but empty.
+// "$${" produces a literal "${". Other uses of "$" are kept as is.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		if strings.HasPrefix(s[i:], "$${") {
+			sb.WriteString("${")
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			sb.WriteByte('$')
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${")
+		}
+		expr := s[i+2 : i+end]
+		name, def, hasDef := strings.Cut(expr, ":-")
+		if !validEnvName(name) {
+			return "", fmt.Errorf("invalid variable name %q", name)
+		}
+		val, ok := lookup(name)
+		if hasDef && (!ok || val == "") {
+			val = def
+		}
+		sb.WriteString(val)
+		i += end
+	}
+	return sb.String(), nil
+}
+
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
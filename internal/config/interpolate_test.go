@@ -0,0 +1,201 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/secret"
+)
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{"HOST": "db.internal", "EMPTY": ""}
+	lookup := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${HOST}", want: "db.internal"},
+		{in: "tcp://${HOST}:5432", want: "tcp://db.internal:5432"},
+		{in: "${MISSING}", want: ""},
+		{in: "${MISSING:-fallback}", want: "fallback"},
+		{in: "${EMPTY:-fallback}", want: "fallback"},
+		{in: "${HOST:-fallback}", want: "db.internal"},
+		{in: "${MISSING:-}", want: ""},
+		{in: "$${HOST}", want: "${HOST}"},
+		{in: "pa$$word$", want: "pa$$word$"},
+		{in: "$HOST", want: "$HOST"},
+		{in: "${HOST", wantErr: true},
+		{in: "${}", wantErr: true},
+		{in: "${1BAD}", wantErr: true},
+		{in: "${BAD-NAME}", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := expandEnv(tt.in, lookup)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("expandEnv(%q) expected error, got %q", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expandEnv(%q) unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expandEnv(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_ExpandEnv(t *testing.T) {
+	t.Setenv("XSQL_TEST_HOST", "db.internal")
+	t.Setenv("XSQL_TEST_PORT", "5433")
+	t.Setenv("XSQL_TEST_PASSWORD", "s3cret")
+	path := writeConfig(t, `profiles:
+  base:
+    db: pg
+    host: ${XSQL_TEST_HOST}
+    port: ${XSQL_TEST_PORT}
+    user: ${XSQL_TEST_USER:-app}
+    password: ${XSQL_TEST_PASSWORD}
+    description: "${XSQL_TEST_PORT}"
+  child:
+    extends: base
+    database: ${XSQL_TEST_DB:-orders}
+`)
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	base := cfg.Profiles["base"]
+	if base.Host != "db.internal" || base.Port != 5433 || base.User != "app" || base.Password != "s3cret" {
+		t.Fatalf("unexpected expansion: %+v", base)
+	}
+	if base.Description != "5433" {
+		t.Errorf("quoted value should stay a string, got %q", base.Description)
+	}
+	child := cfg.Profiles["child"]
+	if child.Host != "db.internal" || child.Database != "orders" {
+		t.Errorf("expansion not applied through extends: %+v", child)
+	}
+
+	// Expanded secrets are still plaintext and need allow_plaintext.
+	if _, xe := secret.Resolve(base.Password, secret.Options{AllowPlaintext: base.AllowPlaintext}); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("expected plaintext password to be rejected, got %v", xe)
+	}
+}
+
+func TestLoadConfig_ExpandEnvDisabled(t *testing.T) {
+	t.Setenv("XSQL_TEST_HOST", "db.internal")
+	path := writeConfig(t, `expand_env: false
+profiles:
+  dev:
+    db: pg
+    host: ${XSQL_TEST_HOST}
+    password: "pa${ss"
+`)
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	dev := cfg.Profiles["dev"]
+	if dev.Host != "${XSQL_TEST_HOST}" || dev.Password != "pa${ss" {
+		t.Errorf("values should be literal when expand_env is false: %+v", dev)
+	}
+}
+
+func TestLoadConfig_ExpandEnvInvalid(t *testing.T) {
+	path := writeConfig(t, `profiles:
+  dev:
+    db: pg
+    password: "pa${ss"
+`)
+	_, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Fatalf("expected CfgInvalid, got %v", xe)
+	}
+	if xe.Details["path"] != path {
+		t.Errorf("expected path in details, got %v", xe.Details)
+	}
+	if strings.Contains(xe.Error(), "pa${ss") {
+		t.Errorf("error should not echo the raw value: %v", xe)
+	}
+}
+
+func TestSaveProfile_KeepsEnvReferences(t *testing.T) {
+	t.Setenv("XSQL_TEST_HOST", "db.internal")
+	path := writeConfig(t, `profiles:
+  dev:
+    db: pg
+    host: ${XSQL_TEST_HOST}
+`)
+	if xe := SaveProfile(path, "other", Profile{DB: "mysql", Host: "localhost"}); xe != nil {
+		t.Fatal(xe)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "${XSQL_TEST_HOST}") || strings.Contains(string(b), "db.internal") {
+		t.Errorf("env reference should be written back unexpanded:\n%s", b)
+	}
+}
+
+func TestSetConfigValue_KeepsNonStringEnvReferences(t *testing.T) {
+	t.Setenv("PGPORT", "")
+	path := writeConfig(t, `profiles:
+  ci:
+    db: pg
+    host: localhost
+    port: ${PGPORT:-5432}
+    unsafe_allow_write: ${CI_WRITE:-false}
+`)
+	if xe := SetConfigValue(path, "profile.ci.user", "bob"); xe != nil {
+		t.Fatalf("set user: %v", xe)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"port: ${PGPORT:-5432}", "unsafe_allow_write: ${CI_WRITE:-false}", "user: bob"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("missing %q:\n%s", want, b)
+		}
+	}
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if p := cfg.Profiles["ci"]; p.Port != 5432 || p.User != "bob" {
+		t.Errorf("profile = %+v", p)
+	}
+
+	// Saving a profile read from the file (profile set-password, web UI) keeps it too.
+	p, ok, xe := FileProfile(path, "ci")
+	if xe != nil || !ok {
+		t.Fatalf("file profile: %v %v", ok, xe)
+	}
+	p.Password = "keyring:ci/password"
+	if xe := SaveProfile(path, "ci", p); xe != nil {
+		t.Fatalf("save profile: %v", xe)
+	}
+	if b, _ := os.ReadFile(path); !strings.Contains(string(b), "port: ${PGPORT:-5432}") {
+		t.Errorf("save dropped the port reference:\n%s", b)
+	}
+
+	// An explicit value replaces the reference.
+	if xe := SetConfigValue(path, "profile.ci.port", "6543"); xe != nil {
+		t.Fatalf("set port: %v", xe)
+	}
+	if b, _ := os.ReadFile(path); strings.Contains(string(b), "PGPORT") || !strings.Contains(string(b), "port: 6543") {
+		t.Errorf("port reference not replaced:\n%s", b)
+	}
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 
@@ -20,62 +23,302 @@ func defaultConfigPaths(workDir, homeDir string) []string {
 	return paths
 }
 
+// defaultSystemConfigDir returns the directory holding the system-wide config file.
+func defaultSystemConfigDir() string {
+	if runtime.GOOS == "windows" {
+		if pd := os.Getenv("ProgramData"); pd != "" {
+			return filepath.Join(pd, "xsql")
+		}
+		return ""
+	}
+	return filepath.Join("/etc", "xsql")
+}
+
+// configLayers returns the config files that take part in layered loading, from
+// lowest to highest precedence: system file, user file, user conf.d/*.yaml
+// (lexical order), project file.
+func configLayers(workDir, homeDir, systemDir string) []string {
+	var paths []string
+	if systemDir != "" {
+		paths = append(paths, filepath.Join(systemDir, "xsql.yaml"))
+	}
+	if homeDir != "" {
+		userDir := filepath.Join(homeDir, ".config", "xsql")
+		paths = append(paths, filepath.Join(userDir, "xsql.yaml"))
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, _ := filepath.Glob(filepath.Join(userDir, "conf.d", pattern))
+			sort.Strings(matches)
+			paths = append(paths, matches...)
+		}
+	}
+	if workDir != "" {
+		paths = append(paths, filepath.Join(workDir, "xsql.yaml"))
+	}
+	return paths
+}
+
 func readFile(path string) (File, *errors.XError) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return File{}, errors.New(errors.CodeCfgNotFound, "config file not found", map[string]any{"path": path})
+	return readFileOpts(path, true)
+}
+
+// readRawFile reads the config file as written, without resolving profile
+// inheritance or expanding environment variables. It is used when the file is
+// modified and written back, so expanded values never end up on disk. References
+// in non-string fields read as unset (see maskEnvRefs).
+func readRawFile(path string) (File, *errors.XError) {
+	return readFileOpts(path, false)
+}
+
+func readFileOpts(path string, resolve bool) (File, *errors.XError) {
+	doc, xe := readDocument(path, resolve)
+	if xe != nil {
+		return File{}, xe
+	}
+	if !resolve {
+		maskEnvRefs(doc, reflect.TypeOf(File{}))
+	}
+	var sources map[string]map[string]string
+	if resolve {
+		sources, xe = resolveExtends(doc, nil)
+		if xe != nil {
+			xe.Details["path"] = path
+			return File{}, xe
 		}
-		return File{}, errors.Wrap(errors.CodeCfgInvalid, "failed to read config file", map[string]any{"path": path}, err)
 	}
-	var f File
-	if err := yaml.Unmarshal(b, &f); err != nil {
-		return File{}, errors.Wrap(errors.CodeCfgInvalid, "invalid config file", map[string]any{"path": path}, err)
+	f, xe := decodeDocument(doc, path)
+	if xe != nil {
+		return File{}, xe
+	}
+	if resolve {
+		if xe := applyProfileURLs(&f, nil); xe != nil {
+			xe.Details["path"] = path
+			return File{}, xe
+		}
+	}
+	f.ProfileSources = sources
+	return f, nil
+}
+
+// loadReporter receives a problem that a tolerant load skipped over: an issue
+// code, the file and dotted key path it belongs to, and the error.
+type loadReporter func(code, file, path string, xe *errors.XError)
+
+// readDocument parses a config file into a YAML node tree, expanding environment
+// variables when expand is set and the file does not opt out.
+func readDocument(path string, expand bool) (*yaml.Node, *errors.XError) {
+	return loadDocument(path, expand, nil)
+}
+
+// loadDocument is readDocument; with report set, invalid environment variable
+// references are reported and left unexpanded instead of failing the file.
+func loadDocument(path string, expand bool, report loadReporter) (*yaml.Node, *errors.XError) {
+	b, _, xe := readConfigBytes(path)
+	if xe != nil {
+		return nil, xe
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrap(errors.CodeCfgInvalid, "invalid config file", map[string]any{"path": path}, err)
+	}
+	// Older layouts are upgraded in memory; `xsql config migrate` persists it.
+	if _, _, xe := migrateDocument(&doc); xe != nil {
+		xe.Details["path"] = path
+		return nil, xe
 	}
+	if expand && expandEnvEnabled(&doc) {
+		var onError func(*errors.XError)
+		if report != nil {
+			onError = func(xe *errors.XError) { report("invalid_env_reference", path, "", xe) }
+		}
+		if xe := expandEnvNodes(&doc, onError); xe != nil {
+			xe.Details["path"] = path
+			return nil, xe
+		}
+	}
+	// Decrypt after expansion so decrypted values are taken literally.
+	if _, xe := decryptNodes(&doc); xe != nil {
+		return nil, withDetail(xe, "path", path)
+	}
+	return &doc, nil
+}
+
+// decodeDocument decodes doc into a File. On a type mismatch the error is
+// returned together with everything else that could be decoded.
+func decodeDocument(doc *yaml.Node, path string) (File, *errors.XError) {
+	var f File
+	err := doc.Decode(&f)
 	if f.Profiles == nil {
 		f.Profiles = map[string]Profile{}
 	}
 	if f.SSHProxies == nil {
 		f.SSHProxies = map[string]SSHProxy{}
 	}
+	if err != nil {
+		return f, errors.Wrap(errors.CodeCfgInvalid, "invalid config file", map[string]any{"path": path}, err)
+	}
 	return f, nil
 }
 
-// LoadConfig loads the configuration file and returns the full config along with its path.
-func LoadConfig(opts Options) (File, string, *errors.XError) {
-	workDir := opts.WorkDir
-	if workDir == "" {
-		wd, _ := os.Getwd()
-		workDir = wd
+// entryOrigins appends path to origins[name] for every entry of the section
+// mapping in root.
+func entryOrigins(origins map[string][]string, root *yaml.Node, section, path string) {
+	if m := mappingValue(root, section); m != nil && m.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			name := m.Content[i].Value
+			origins[name] = append(origins[name], path)
+		}
 	}
-	if opts.HomeDir == "" {
-		if hd, err := os.UserHomeDir(); err == nil {
-			opts.HomeDir = hd
+}
+
+// readLayers loads and merges the given config files. Later files take
+// precedence key by key; nested mappings such as a single profile are merged
+// rather than replaced. Missing files are skipped. The returned path is the
+// highest-precedence file that was loaded.
+func readLayers(paths []string) (File, string, *errors.XError) {
+	return loadLayers(paths, nil)
+}
+
+// loadLayers is readLayers; with report set, problems are reported and the part
+// they affect (a file, a value, a profile's extends or url) is skipped, so the
+// rest still loads.
+func loadLayers(paths []string, report loadReporter) (File, string, *errors.XError) {
+	var merged *yaml.Node
+	var loaded []string
+	origins := map[string][]string{}
+	proxyOrigins := map[string][]string{}
+	for _, p := range paths {
+		doc, xe := loadDocument(p, true, report)
+		if xe != nil {
+			if xe.Code == errors.CodeCfgNotFound {
+				continue
+			}
+			if report != nil {
+				report("invalid_file", p, "", xe)
+				continue
+			}
+			return File{}, "", xe
 		}
+		root := documentRoot(doc)
+		if root == nil || root.Kind != yaml.MappingNode {
+			// Empty file: counts as loaded but contributes nothing.
+			loaded = append(loaded, p)
+			continue
+		}
+		if report != nil {
+			// Report type mismatches per file; the merged document loses track.
+			if _, xe := decodeDocument(doc, p); xe != nil {
+				report("invalid_value", p, "", xe)
+			}
+		}
+		entryOrigins(origins, root, "profiles", p)
+		entryOrigins(proxyOrigins, root, "ssh_proxies", p)
+		if merged == nil {
+			merged = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			setMappingValue(merged, root.Content[i], root.Content[i+1])
+		}
+		loaded = append(loaded, p)
+	}
+	if len(loaded) == 0 {
+		return File{Profiles: map[string]Profile{}, SSHProxies: map[string]SSHProxy{}}, "", nil
+	}
+	primary := loaded[len(loaded)-1]
+	if merged == nil {
+		return File{Profiles: map[string]Profile{}, SSHProxies: map[string]SSHProxy{}, Files: loaded}, primary, nil
 	}
 
-	if opts.ConfigPath != "" {
-		abs := opts.ConfigPath
-		if !filepath.IsAbs(abs) {
-			abs = filepath.Join(workDir, abs)
+	profileFile := func(name string) string {
+		if files := origins[name]; len(files) > 0 {
+			return files[len(files)-1]
+		}
+		return primary
+	}
+	var onExtendsError, onURLError func(string, *errors.XError)
+	if report != nil {
+		onExtendsError = func(name string, xe *errors.XError) {
+			code := "unknown_extends"
+			if _, ok := xe.Details["chain"]; ok {
+				code = "extends_cycle"
+			}
+			report(code, profileFile(name), "profiles."+name+".extends", xe)
 		}
+		onURLError = func(name string, xe *errors.XError) {
+			report("invalid_url", profileFile(name), "profiles."+name+".url", xe)
+		}
+	}
+
+	// Extends is resolved on the merged document so a project profile can build
+	// on one from the user file.
+	sources, xe := resolveExtends(merged, onExtendsError)
+	if xe != nil {
+		xe.Details["paths"] = loaded
+		return File{}, "", xe
+	}
+	f, xe := decodeDocument(merged, primary)
+	if xe != nil && report == nil {
+		return File{}, "", xe
+	}
+	if xe := applyProfileURLs(&f, onURLError); xe != nil {
+		xe.Details["path"] = profileFile(xe.Details["profile"].(string))
+		return File{}, "", xe
+	}
+	f.ProfileSources = sources
+	f.ProfileFiles = origins
+	f.SSHProxyFiles = proxyOrigins
+	f.Files = loaded
+	return f, primary, nil
+}
+
+// LoadConfig loads the configuration and returns the merged config along with the
+// path of its highest-precedence file. With an explicit ConfigPath only that file
+// is read; otherwise the layers from configLayers are merged.
+func LoadConfig(opts Options) (File, string, *errors.XError) {
+	paths := configPaths(opts)
+	if opts.ConfigPath != "" {
+		abs := paths[0]
 		f, xe := readFile(abs)
 		if xe != nil {
 			return File{}, "", xe
 		}
+		f.Files = []string{abs}
+		f.ProfileFiles = make(map[string][]string, len(f.Profiles))
+		for name := range f.Profiles {
+			f.ProfileFiles[name] = []string{abs}
+		}
+		f.SSHProxyFiles = make(map[string][]string, len(f.SSHProxies))
+		for name := range f.SSHProxies {
+			f.SSHProxyFiles[name] = []string{abs}
+		}
 		return f, abs, nil
 	}
+	return readLayers(paths)
+}
 
-	for _, p := range defaultConfigPaths(workDir, opts.HomeDir) {
-		f, xe := readFile(p)
-		if xe != nil {
-			if xe.Code == errors.CodeCfgNotFound {
-				continue
-			}
-			return File{}, "", xe
+// configPaths returns the files LoadConfig reads: the explicit ConfigPath
+// (made absolute), or the layers from configLayers.
+func configPaths(opts Options) []string {
+	workDir := opts.WorkDir
+	if workDir == "" {
+		wd, _ := os.Getwd()
+		workDir = wd
+	}
+	if opts.ConfigPath != "" {
+		abs := opts.ConfigPath
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(workDir, abs)
 		}
-		return f, p, nil
+		return []string{abs}
 	}
-
-	return File{Profiles: map[string]Profile{}, SSHProxies: map[string]SSHProxy{}}, "", nil
+	homeDir := opts.HomeDir
+	if homeDir == "" {
+		if hd, err := os.UserHomeDir(); err == nil {
+			homeDir = hd
+		}
+	}
+	systemDir := opts.SystemDir
+	if systemDir == "" {
+		systemDir = defaultSystemConfigDir()
+	}
+	return configLayers(workDir, homeDir, systemDir)
 }
@@ -8,7 +8,7 @@ import (
 
 func TestLoadConfig_NoConfig(t *testing.T) {
 	tmp := t.TempDir()
-	cfg, path, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp})
+	cfg, path, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -51,7 +51,7 @@ func TestLoadConfig_WorkDirConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	file, cfgPath, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp})
+	file, cfgPath, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -104,7 +104,7 @@ func TestLoadConfig_HomeDirConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	file, cfgPath, xe := LoadConfig(Options{WorkDir: workDir, HomeDir: homeDir})
+	file, cfgPath, xe := LoadConfig(Options{WorkDir: workDir, HomeDir: homeDir, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -116,7 +116,7 @@ func TestLoadConfig_HomeDirConfig(t *testing.T) {
 	}
 }
 
-func TestLoadConfig_WorkDirTakesPrecedence(t *testing.T) {
+func TestLoadConfig_WorkDirLayeredOverHome(t *testing.T) {
 	workDir := t.TempDir()
 	homeDir := t.TempDir()
 
@@ -141,7 +141,7 @@ func TestLoadConfig_WorkDirTakesPrecedence(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	file, cfgPath, xe := LoadConfig(Options{WorkDir: workDir, HomeDir: homeDir})
+	file, cfgPath, xe := LoadConfig(Options{WorkDir: workDir, HomeDir: homeDir, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -151,8 +151,8 @@ func TestLoadConfig_WorkDirTakesPrecedence(t *testing.T) {
 	if _, ok := file.Profiles["work"]; !ok {
 		t.Fatal("expected 'work' profile from work dir")
 	}
-	if _, ok := file.Profiles["home"]; ok {
-		t.Fatal("should not have 'home' profile from home dir")
+	if _, ok := file.Profiles["home"]; !ok {
+		t.Fatal("expected 'home' profile from home dir to be merged in")
 	}
 }
 
@@ -164,7 +164,7 @@ func TestLoadConfig_InvalidYAML(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp})
+	_, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe == nil {
 		t.Fatal("expected error for invalid YAML")
 	}
@@ -221,7 +221,7 @@ profiles:
 		t.Fatal(err)
 	}
 
-	file, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp})
+	file, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -271,7 +271,7 @@ func TestLoadConfig_ProfileWithDescription(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	file, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp})
+	file, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -302,7 +302,7 @@ func TestLoadConfig_ProfileWithoutDescription(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	file, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp})
+	file, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -328,7 +328,7 @@ func TestLoadConfig_ProfileWithEmptyDescription(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	file, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp})
+	file, _, xe := LoadConfig(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -339,3 +339,118 @@ func TestLoadConfig_ProfileWithEmptyDescription(t *testing.T) {
 		t.Errorf("expected empty description, got %q", profile.Description)
 	}
 }
+
+func TestLoadConfig_Layers(t *testing.T) {
+	systemDir := t.TempDir()
+	homeDir := t.TempDir()
+	workDir := t.TempDir()
+	userDir := filepath.Join(homeDir, ".config", "xsql")
+	confD := filepath.Join(userDir, "conf.d")
+	if err := os.MkdirAll(confD, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(systemDir, "xsql.yaml"): `profiles:
+  shared:
+    db: pg
+    host: system.example.com
+    query_timeout: 10
+ai:
+  model: system-model
+`,
+		filepath.Join(userDir, "xsql.yaml"): `profiles:
+  shared:
+    host: user.example.com
+  personal:
+    db: mysql
+    host: localhost
+`,
+		filepath.Join(confD, "20-team.yaml"): `profiles:
+  team:
+    db: pg
+    host: team.example.com
+`,
+		filepath.Join(confD, "10-base.yaml"): `profiles:
+  team:
+    db: mysql
+    user: team
+`,
+		filepath.Join(workDir, "xsql.yaml"): `profiles:
+  shared:
+    database: project
+  app:
+    extends: personal
+    database: app
+`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, cfgPath, xe := LoadConfig(Options{WorkDir: workDir, HomeDir: homeDir, SystemDir: systemDir})
+	if xe != nil {
+		t.Fatalf("unexpected error: %v", xe)
+	}
+	if cfgPath != filepath.Join(workDir, "xsql.yaml") {
+		t.Errorf("expected project file as config path, got %q", cfgPath)
+	}
+
+	shared := cfg.Profiles["shared"]
+	if shared.DB != "pg" || shared.Host != "user.example.com" || shared.Database != "project" || shared.QueryTimeout != 10 {
+		t.Errorf("per-key merge failed: %+v", shared)
+	}
+	team := cfg.Profiles["team"]
+	if team.DB != "pg" || team.User != "team" || team.Host != "team.example.com" {
+		t.Errorf("conf.d files should merge in lexical order: %+v", team)
+	}
+	app := cfg.Profiles["app"]
+	if app.DB != "mysql" || app.Host != "localhost" || app.Database != "app" {
+		t.Errorf("project profile should extend a user profile: %+v", app)
+	}
+	if cfg.AI.Model != "system-model" {
+		t.Errorf("expected ai settings from system file, got %q", cfg.AI.Model)
+	}
+
+	wantFiles := []string{
+		filepath.Join(systemDir, "xsql.yaml"),
+		filepath.Join(userDir, "xsql.yaml"),
+		filepath.Join(confD, "10-base.yaml"),
+		filepath.Join(confD, "20-team.yaml"),
+		filepath.Join(workDir, "xsql.yaml"),
+	}
+	if len(cfg.Files) != len(wantFiles) {
+		t.Fatalf("Files = %v, want %v", cfg.Files, wantFiles)
+	}
+	for i := range wantFiles {
+		if cfg.Files[i] != wantFiles[i] {
+			t.Errorf("Files[%d] = %q, want %q", i, cfg.Files[i], wantFiles[i])
+		}
+	}
+	if got := cfg.ProfileFiles["shared"]; len(got) != 3 || got[2] != filepath.Join(workDir, "xsql.yaml") {
+		t.Errorf("unexpected files for shared: %v", got)
+	}
+	if got := cfg.ProfileFiles["personal"]; len(got) != 1 || got[0] != filepath.Join(userDir, "xsql.yaml") {
+		t.Errorf("unexpected files for personal: %v", got)
+	}
+}
+
+func TestLoadConfig_ExplicitPathSkipsLayers(t *testing.T) {
+	systemDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(systemDir, "xsql.yaml"), []byte("profiles:\n  system:\n    db: pg\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfig(t, "profiles:\n  only:\n    db: mysql\n")
+
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path, SystemDir: systemDir})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if _, ok := cfg.Profiles["system"]; ok {
+		t.Error("explicit --config should not merge other layers")
+	}
+	if got := cfg.ProfileFiles["only"]; len(got) != 1 || got[0] != path {
+		t.Errorf("unexpected files for only: %v", got)
+	}
+}
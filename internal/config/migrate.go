@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// CurrentVersion is the config layout version this xsql reads and writes.
+// Files without a version key are treated as version 0.
+const CurrentVersion = 1
+
+// MigrateResult describes the outcome of MigrateConfig.
+type MigrateResult struct {
+	ConfigPath  string   `json:"config_path" yaml:"config_path"`
+	FromVersion int      `json:"from_version" yaml:"from_version"`
+	ToVersion   int      `json:"to_version" yaml:"to_version"`
+	Changes     []string `json:"changes" yaml:"changes"`
+	Written     bool     `json:"written" yaml:"written"`
+}
+
+// MigrateConfig upgrades the config file at path to CurrentVersion, editing
+// the YAML tree in place so comments and key order are kept. With dryRun the
+// changes are reported but the file is left untouched.
+func MigrateConfig(path string, dryRun bool) (*MigrateResult, *errors.XError) {
+	if path == "" {
+		return nil, errors.New(errors.CodeCfgNotFound, "no config file found; run 'xsql config init' first", nil)
+	}
+	b, encrypted, xe := readConfigBytes(path)
+	if xe != nil {
+		return nil, xe
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrap(errors.CodeCfgInvalid, "invalid config file", map[string]any{"path": path}, err)
+	}
+
+	from, changes, xe := migrateDocument(&doc)
+	if xe != nil {
+		xe.Details["path"] = path
+		return nil, xe
+	}
+	result := &MigrateResult{
+		ConfigPath:  path,
+		FromVersion: from,
+		ToVersion:   CurrentVersion,
+		Changes:     changes,
+	}
+	if result.Changes == nil {
+		result.Changes = []string{}
+	}
+	if len(changes) == 0 || dryRun {
+		return result, nil
+	}
+
+	// Refuse to write a file the loader would reject.
+	encValues, xe := decryptNodes(&doc)
+	if xe != nil {
+		return nil, withDetail(xe, "path", path)
+	}
+	if _, xe := decodeDocument(&doc, path); xe != nil {
+		return nil, xe
+	}
+	if xe := reencryptNodes(encValues); xe != nil {
+		return nil, xe
+	}
+	out, xe := encodeDocument(&doc)
+	if xe != nil {
+		return nil, xe
+	}
+	if encrypted {
+		if out, xe = encryptFileBytes(out); xe != nil {
+			return nil, xe
+		}
+	}
+	if xe := writeConfigBytes(path, out); xe != nil {
+		return nil, xe
+	}
+	result.Written = true
+	return result, nil
+}
+
+// migrateDocument upgrades doc to CurrentVersion in place. It returns the
+// version the document started at and a description of each change.
+func migrateDocument(doc *yaml.Node) (int, []string, *errors.XError) {
+	root := documentRoot(doc)
+	if root == nil || root.Kind != yaml.MappingNode {
+		return CurrentVersion, nil, nil
+	}
+	from, xe := documentVersion(root)
+	if xe != nil {
+		return 0, nil, xe
+	}
+	if from > CurrentVersion {
+		return 0, nil, errors.New(errors.CodeCfgInvalid, "config file requires a newer xsql", map[string]any{
+			"version":   from,
+			"supported": CurrentVersion,
+		})
+	}
+	if from == CurrentVersion {
+		return from, nil, nil
+	}
+
+	// Version 1 introduced the version key itself; no other layout changed.
+	setVersion(root, CurrentVersion)
+	return from, []string{fmt.Sprintf("version: %d -> %d", from, CurrentVersion)}, nil
+}
+
+func documentVersion(root *yaml.Node) (int, *errors.XError) {
+	n := mappingValue(root, "version")
+	if n == nil {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(n.Value)
+	if n.Kind != yaml.ScalarNode || err != nil || v < 0 {
+		return 0, errors.New(errors.CodeCfgInvalid, "config version must be a non-negative integer", map[string]any{
+			"line": n.Line,
+		})
+	}
+	return v, nil
+}
+
+// setVersion sets the version key, adding it as the first key when missing.
+func setVersion(root *yaml.Node, version int) {
+	value := strconv.Itoa(version)
+	if n := mappingValue(root, "version"); n != nil {
+		n.Kind, n.Tag, n.Value, n.Style = yaml.ScalarNode, "!!int", value, 0
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	val := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+	if len(root.Content) > 0 {
+		// Keep a leading comment at the top of the file.
+		key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+	}
+	root.Content = append([]*yaml.Node{key, val}, root.Content...)
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+const legacyConfig = `# legacy layout
+profiles:
+  # reporting replica
+  reports:
+    db: pg
+    host: 10.0.0.5
+    user: ops # shared account
+`
+
+func TestMigrateConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xsql.yaml")
+	if err := os.WriteFile(path, []byte(legacyConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	res, xe := MigrateConfig(path, true)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if res.Written || res.FromVersion != 0 || res.ToVersion != CurrentVersion {
+		t.Errorf("unexpected dry-run result: %+v", res)
+	}
+	wantChanges := []string{"version: 0 -> 1"}
+	if !reflect.DeepEqual(res.Changes, wantChanges) {
+		t.Errorf("changes = %v, want %v", res.Changes, wantChanges)
+	}
+	if b, _ := os.ReadFile(path); string(b) != legacyConfig {
+		t.Error("dry run must not modify the file")
+	}
+
+	res, xe = MigrateConfig(path, false)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if !res.Written {
+		t.Fatal("expected the file to be written")
+	}
+	b, _ := os.ReadFile(path)
+	for _, want := range []string{"# legacy layout\nversion: 1", "# reporting replica", "user: ops # shared account"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("migrated file should contain %q:\n%s", want, b)
+		}
+	}
+
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if cfg.Version != CurrentVersion || cfg.Profiles["reports"].User != "ops" {
+		t.Errorf("unexpected migrated config: %+v", cfg)
+	}
+
+	res, xe = MigrateConfig(path, false)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if res.Written || len(res.Changes) != 0 {
+		t.Errorf("second migration should be a no-op: %+v", res)
+	}
+}
+
+func TestLoadConfig_MigratesInMemory(t *testing.T) {
+	path := writeConfig(t, legacyConfig)
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if cfg.Version != CurrentVersion || cfg.Profiles["reports"].Host != "10.0.0.5" {
+		t.Errorf("old layout should load as the current version: %+v", cfg)
+	}
+	if b, _ := os.ReadFile(path); string(b) != legacyConfig {
+		t.Error("loading must not modify the file")
+	}
+}
+
+func TestMigrateConfig_NewerVersion(t *testing.T) {
+	path := writeConfig(t, "version: 99\nprofiles: {}\n")
+	for _, fn := range []func() *errors.XError{
+		func() *errors.XError { _, xe := MigrateConfig(path, false); return xe },
+		func() *errors.XError { _, _, xe := LoadConfig(Options{ConfigPath: path}); return xe },
+	} {
+		xe := fn()
+		if xe == nil || xe.Code != errors.CodeCfgInvalid || xe.Details["version"] != 99 {
+			t.Errorf("expected CfgInvalid for a newer version, got %v", xe)
+		}
+	}
+}
@@ -1,52 +1,18 @@
 package config
 
 import (
-	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/sshconfig"
 )
 
 // Resolve performs phase-1 config/profile/format merging: CLI > ENV > Config.
 func Resolve(opts Options) (Resolved, *errors.XError) {
-	workDir := opts.WorkDir
-	if workDir == "" {
-		wd, _ := os.Getwd()
-		workDir = wd
-	}
-	if opts.HomeDir == "" {
-		if hd, err := os.UserHomeDir(); err == nil {
-			opts.HomeDir = hd
-		}
-	}
-
-	// 1) Read config file (if any)
-	var cfg File
-	var cfgPath string
-	if opts.ConfigPath != "" {
-		abs := opts.ConfigPath
-		if !filepath.IsAbs(abs) {
-			abs = filepath.Join(workDir, abs)
-		}
-		f, xe := readFile(abs)
-		if xe != nil {
-			return Resolved{}, xe
-		}
-		cfg = f
-		cfgPath = abs
-	} else {
-		for _, p := range defaultConfigPaths(workDir, opts.HomeDir) {
-			f, xe := readFile(p)
-			if xe != nil {
-				if xe.Code == errors.CodeCfgNotFound {
-					continue
-				}
-				return Resolved{}, xe
-			}
-			cfg = f
-			cfgPath = p
-			break
-		}
+	// 1) Read config files (if any)
+	cfg, cfgPath, xe := LoadConfig(opts)
+	if xe != nil {
+		return Resolved{}, xe
 	}
 
 	// 2) Select profile: --profile > XSQL_PROFILE > profiles.default > empty
@@ -61,37 +27,50 @@ func Resolve(opts Options) (Resolved, *errors.XError) {
 		}
 	}
 
+	// A URL selects an ad-hoc profile that is not in the config.
+	var adHoc *Profile
+	if IsProfileURL(profile) {
+		p, xe := ParseProfileURL(profile)
+		if xe != nil {
+			return Resolved{}, xe
+		}
+		p.URL = profile
+		adHoc = &p
+		profile = RedactURL(profile)
+	} else {
+		profile, xe = SelectProfile(cfg.Profiles, profile)
+		if xe != nil {
+			return Resolved{}, xe
+		}
+	}
+
 	// Resolve all profiles in cfg.Profiles
 	resolvedProfiles := make(map[string]Profile, len(cfg.Profiles))
 	for name, p := range cfg.Profiles {
 		pCopy := p
 		if pCopy.SSHProxy != "" {
-			if proxy, ok := cfg.SSHProxies[pCopy.SSHProxy]; ok {
-				pCopy.SSHConfig = &proxy
-			}
-		}
-		if pCopy.Port == 0 {
-			switch pCopy.DB {
-			case "mysql":
-				pCopy.Port = 3306
-			case "pg":
-				pCopy.Port = 5432
+			if proxy, xe := ResolveSSHProxy(cfg.SSHProxies, pCopy.SSHProxy); xe == nil {
+				pCopy.SSHConfig = proxy
 			}
 		}
+		pCopy.Port = defaultPort(pCopy.DB, pCopy.Port)
 		resolvedProfiles[name] = pCopy
 	}
 
 	// 3) Retrieve full profile
 	var selectedProfile Profile
-	if profile != "" {
+	if adHoc != nil {
+		selectedProfile = *adHoc
+		selectedProfile.Port = defaultPort(selectedProfile.DB, selectedProfile.Port)
+	} else if profile != "" {
 		p, ok := resolvedProfiles[profile]
 		if !ok {
 			return Resolved{}, errors.New(errors.CodeCfgInvalid, "profile not found",
 				map[string]any{"profile": profile})
 		}
 		if p.SSHProxy != "" && p.SSHConfig == nil {
-			return Resolved{}, errors.New(errors.CodeCfgInvalid, "ssh_proxy not found",
-				map[string]any{"profile": profile, "ssh_proxy": p.SSHProxy})
+			_, xe := ResolveSSHProxy(cfg.SSHProxies, p.SSHProxy)
+			return Resolved{}, withDetail(xe, "profile", profile)
 		}
 		selectedProfile = p
 	}
@@ -152,3 +131,148 @@ func Resolve(opts Options) (Resolved, *errors.XError) {
 		AI:          aiConfig,
 	}, nil
 }
+
+// defaultPort returns port, or the driver's default port when it is unset.
+func defaultPort(db string, port int) int {
+	if port != 0 {
+		return port
+	}
+	switch db {
+	case "mysql":
+		return 3306
+	case "pg":
+		return 5432
+	}
+	return port
+}
+
+// ResolveSSHProxy returns the ssh_proxies entry name with Name set and its jump
+// chain resolved into JumpHosts, first hop first. Jump hosts with their own
+// jump lists are expanded in place, as with nested ProxyJump. Entries with
+// ssh_config_host are completed from ~/.ssh/config, whose ProxyJump is used
+// when the entry has no jump list of its own.
+func ResolveSSHProxy(proxies map[string]SSHProxy, name string) (*SSHProxy, *errors.XError) {
+	proxy, ok := proxies[name]
+	if !ok {
+		return nil, errors.New(errors.CodeCfgInvalid, "ssh_proxy not found", map[string]any{"ssh_proxy": name})
+	}
+	proxy.Name = name
+	r := &proxyResolver{proxies: proxies, root: name}
+	hops, xe := r.jumpChain(&proxy, []string{name})
+	if xe != nil {
+		return nil, xe
+	}
+	proxy.JumpHosts = hops
+	return &proxy, nil
+}
+
+type proxyResolver struct {
+	proxies   map[string]SSHProxy
+	root      string
+	sshConfig *sshconfig.Config // loaded on first use
+}
+
+// jumpChain completes proxy from the ssh config when it names a Host there and
+// returns its jump hosts. chain lists the hops already on the path, for cycle
+// detection; hosts taken from ssh config ProxyJump appear as "ssh_config:ALIAS".
+func (r *proxyResolver) jumpChain(proxy *SSHProxy, chain []string) ([]SSHProxy, *errors.XError) {
+	type jump struct {
+		key string
+		hop SSHProxy
+	}
+	var jumps []jump
+	if proxy.SSHConfigHost != "" {
+		proxyJump, xe := r.applySSHConfig(proxy)
+		if xe != nil {
+			return nil, xe
+		}
+		if len(proxy.Jump) == 0 {
+			specs, err := sshconfig.ParseProxyJump(proxyJump)
+			if err != nil {
+				return nil, errors.Wrap(errors.CodeCfgInvalid, "invalid ProxyJump in ssh config", map[string]any{
+					"ssh_proxy":       r.root,
+					"ssh_config_host": proxy.SSHConfigHost,
+					"ssh_config":      r.sshConfig.Path,
+				}, err)
+			}
+			for _, j := range specs {
+				hop := SSHProxy{Name: j.Host, SSHConfigHost: j.Host, User: j.User, Port: j.Port}
+				jumps = append(jumps, jump{key: "ssh_config:" + j.Host, hop: hop})
+			}
+		}
+	}
+	for _, name := range proxy.Jump {
+		hop, ok := r.proxies[name]
+		if !ok {
+			return nil, errors.New(errors.CodeCfgInvalid, "jump host not found in ssh_proxies", map[string]any{
+				"ssh_proxy": proxy.Name,
+				"jump":      name,
+			})
+		}
+		hop.Name = name
+		jumps = append(jumps, jump{key: name, hop: hop})
+	}
+
+	var hops []SSHProxy
+	for _, j := range jumps {
+		for _, c := range chain {
+			if c == j.key {
+				return nil, errors.New(errors.CodeCfgInvalid, "ssh_proxy jump cycle", map[string]any{
+					"ssh_proxy": chain[0],
+					"chain":     strings.Join(append(chain, j.key), " -> "),
+				})
+			}
+		}
+		hop := j.hop
+		nested, xe := r.jumpChain(&hop, append(chain, j.key))
+		if xe != nil {
+			return nil, xe
+		}
+		hop.Jump = nil
+		hops = append(append(hops, nested...), hop)
+	}
+	return hops, nil
+}
+
+// applySSHConfig fills the fields of p that are not set from its ssh config
+// Host and returns that host's ProxyJump.
+func (r *proxyResolver) applySSHConfig(p *SSHProxy) (string, *errors.XError) {
+	if r.sshConfig == nil {
+		path := sshconfig.DefaultPath()
+		c, err := sshconfig.Load(path)
+		if err != nil {
+			return "", errors.Wrap(errors.CodeCfgInvalid, "failed to read ssh config", map[string]any{
+				"ssh_proxy":  r.root,
+				"ssh_config": path,
+			}, err)
+		}
+		r.sshConfig = c
+	}
+	h, err := r.sshConfig.Lookup(p.SSHConfigHost)
+	if err != nil {
+		return "", errors.Wrap(errors.CodeCfgInvalid, "invalid ssh config", map[string]any{
+			"ssh_proxy":       r.root,
+			"ssh_config_host": p.SSHConfigHost,
+			"ssh_config":      r.sshConfig.Path,
+		}, err)
+	}
+	if p.Host == "" {
+		p.Host = h.HostName
+	}
+	if p.Port == 0 {
+		p.Port = h.Port
+	}
+	if p.User == "" {
+		p.User = h.User
+	}
+	if p.IdentityFile == "" {
+		p.IdentityFile = h.IdentityFile
+	}
+	if p.IdentityAgent == "" {
+		p.IdentityAgent = h.IdentityAgent
+	}
+	if p.KnownHostsFile == "" {
+		p.KnownHostsFile = h.UserKnownHostsFile
+	}
+	return h.ProxyJump, nil
+}
@@ -3,12 +3,13 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func TestResolve_DefaultPaths_NoConfig(t *testing.T) {
 	tmp := t.TempDir()
-	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp})
+	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected err: %v", xe)
 	}
@@ -40,7 +41,7 @@ func TestResolve_ProfileAndFormatPrecedence(t *testing.T) {
 	}
 
 	// No CLI/ENV profile -> profiles.default selected
-	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp})
+	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatal(xe)
 	}
@@ -49,7 +50,7 @@ func TestResolve_ProfileAndFormatPrecedence(t *testing.T) {
 	}
 
 	// ENV overrides config
-	got, xe = Resolve(Options{WorkDir: tmp, HomeDir: tmp, EnvFormat: "json"})
+	got, xe = Resolve(Options{WorkDir: tmp, HomeDir: tmp, EnvFormat: "json", SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatal(xe)
 	}
@@ -58,7 +59,7 @@ func TestResolve_ProfileAndFormatPrecedence(t *testing.T) {
 	}
 
 	// CLI overrides ENV
-	got, xe = Resolve(Options{WorkDir: tmp, HomeDir: tmp, EnvFormat: "yaml", CLIFormat: "table", CLIFormatSet: true})
+	got, xe = Resolve(Options{WorkDir: tmp, HomeDir: tmp, EnvFormat: "yaml", CLIFormat: "table", CLIFormatSet: true, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatal(xe)
 	}
@@ -67,7 +68,7 @@ func TestResolve_ProfileAndFormatPrecedence(t *testing.T) {
 	}
 
 	// CLI profile overrides default
-	got, xe = Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "dev", CLIProfileSet: true})
+	got, xe = Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "dev", CLIProfileSet: true, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatal(xe)
 	}
@@ -84,7 +85,7 @@ func TestResolve_ProfileNotFound(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "missing", CLIProfileSet: true})
+	_, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "missing", CLIProfileSet: true, SystemDir: t.TempDir()})
 	if xe == nil {
 		t.Fatal("expected error for missing profile")
 	}
@@ -112,7 +113,7 @@ profiles:
 		t.Fatal(err)
 	}
 
-	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "prod", CLIProfileSet: true})
+	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "prod", CLIProfileSet: true, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -141,7 +142,7 @@ func TestResolve_SSHProxyNotFound(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "prod", CLIProfileSet: true})
+	_, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "prod", CLIProfileSet: true, SystemDir: t.TempDir()})
 	if xe == nil {
 		t.Fatal("expected error for missing ssh_proxy")
 	}
@@ -150,6 +151,149 @@ func TestResolve_SSHProxyNotFound(t *testing.T) {
 	}
 }
 
+func TestResolve_SSHProxyJumpChain(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := []byte(`ssh_proxies:
+  edge:
+    host: edge.example.com
+  bastion-a:
+    host: a.example.com
+    jump: [edge]
+  bastion-b:
+    host: b.example.com
+  db-gw:
+    host: gw.internal
+    jump: [bastion-a, bastion-b]
+  loop-a:
+    host: la
+    jump: [loop-b]
+  loop-b:
+    host: lb
+    jump: [loop-a]
+  broken:
+    host: x
+    jump: [missing]
+profiles:
+  prod:
+    db: pg
+    host: db.internal
+    ssh_proxy: db-gw
+  looped:
+    db: pg
+    host: db.internal
+    ssh_proxy: loop-a
+`)
+	path := filepath.Join(tmp, "xsql.yaml")
+	if err := os.WriteFile(path, cfg, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "prod", CLIProfileSet: true, SystemDir: t.TempDir()})
+	if xe != nil {
+		t.Fatalf("unexpected error: %v", xe)
+	}
+	sc := got.Profile.SSHConfig
+	if sc == nil || sc.Name != "db-gw" {
+		t.Fatalf("SSHConfig = %+v", sc)
+	}
+	var hops []string
+	for _, hop := range sc.JumpHosts {
+		hops = append(hops, hop.Name+"="+hop.Host)
+	}
+	// Nested jumps are expanded before the hop that declares them.
+	if want := "edge=edge.example.com,bastion-a=a.example.com,bastion-b=b.example.com"; strings.Join(hops, ",") != want {
+		t.Errorf("jump chain = %v, want %s", hops, want)
+	}
+
+	_, xe = Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "looped", CLIProfileSet: true, SystemDir: t.TempDir()})
+	if xe == nil || xe.Details["chain"] != "loop-a -> loop-b -> loop-a" || xe.Details["profile"] != "looped" {
+		t.Errorf("expected jump cycle error, got %v", xe)
+	}
+
+	f, _, _ := LoadConfig(Options{ConfigPath: path})
+	if _, xe := ResolveSSHProxy(f.SSHProxies, "broken"); xe == nil || xe.Details["jump"] != "missing" {
+		t.Errorf("expected unknown jump host error, got %v", xe)
+	}
+	issues := Validate(f, ValidateOptions{})
+	if findIssue(issues, "jump_cycle", "ssh_proxies.loop-a.jump") == nil || findIssue(issues, "unknown_jump_host", "ssh_proxies.broken.jump") == nil {
+		t.Errorf("missing jump issues: %+v", issues)
+	}
+}
+
+func TestResolve_SSHProxyFromSSHConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	sshDir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(sshDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	sshCfg := `Host prod-db
+  HostName db-gw.internal
+  User deploy
+  IdentityFile ~/.ssh/id_prod
+  ProxyJump admin@bastion:2222
+
+Host bastion
+  HostName bastion.example.com
+  Port 22
+  UserKnownHostsFile ~/.ssh/known_hosts_bastion
+
+Host loop
+  ProxyJump loop
+`
+	if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte(sshCfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	proxies := map[string]SSHProxy{
+		"prod":   {SSHConfigHost: "prod-db", Port: 2200},
+		"manual": {SSHConfigHost: "prod-db", Jump: []string{"edge"}},
+		"edge":   {Host: "edge.example.com"},
+		"loop":   {SSHConfigHost: "loop"},
+	}
+
+	got, xe := ResolveSSHProxy(proxies, "prod")
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	// Fields set in xsql.yaml take precedence over ssh config.
+	if got.Host != "db-gw.internal" || got.User != "deploy" || got.Port != 2200 || got.IdentityFile != "~/.ssh/id_prod" {
+		t.Errorf("proxy = %+v", got)
+	}
+	if len(got.JumpHosts) != 1 {
+		t.Fatalf("jump hosts = %+v", got.JumpHosts)
+	}
+	hop := got.JumpHosts[0]
+	if hop.Name != "bastion" || hop.Host != "bastion.example.com" || hop.User != "admin" || hop.Port != 2222 ||
+		hop.KnownHostsFile != "~/.ssh/known_hosts_bastion" {
+		t.Errorf("jump host = %+v", hop)
+	}
+
+	// An explicit jump list replaces ProxyJump.
+	got, xe = ResolveSSHProxy(proxies, "manual")
+	if xe != nil || len(got.JumpHosts) != 1 || got.JumpHosts[0].Name != "edge" {
+		t.Errorf("manual jump = %+v, %v", got, xe)
+	}
+
+	if _, xe := ResolveSSHProxy(proxies, "loop"); xe == nil || xe.Details["chain"] != "loop -> ssh_config:loop -> ssh_config:loop" {
+		t.Errorf("expected ProxyJump cycle, got %v", xe)
+	}
+
+	if err := os.Remove(filepath.Join(sshDir, "config")); err != nil {
+		t.Fatal(err)
+	}
+	if _, xe := ResolveSSHProxy(proxies, "prod"); xe == nil || xe.Details["ssh_config"] == nil {
+		t.Errorf("expected missing ssh config error, got %v", xe)
+	}
+	issues := Validate(File{SSHProxies: proxies}, ValidateOptions{})
+	if findIssue(issues, "ssh_config_unresolved", "ssh_proxies.prod.ssh_config_host") == nil {
+		t.Errorf("missing ssh_config_unresolved issue: %+v", issues)
+	}
+	if findIssue(issues, "missing_host", "ssh_proxies.prod.host") != nil {
+		t.Errorf("ssh_config_host should satisfy host: %+v", issues)
+	}
+}
+
 func TestResolve_DefaultPort_MySQL(t *testing.T) {
 	tmp := t.TempDir()
 	cfg := []byte(`profiles:
@@ -162,7 +306,7 @@ func TestResolve_DefaultPort_MySQL(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "mysql_db", CLIProfileSet: true})
+	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "mysql_db", CLIProfileSet: true, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -184,7 +328,7 @@ func TestResolve_DefaultPort_PostgreSQL(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "pg_db", CLIProfileSet: true})
+	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "pg_db", CLIProfileSet: true, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -207,7 +351,7 @@ func TestResolve_Port_PreservedWhenSpecified(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "custom_port", CLIProfileSet: true})
+	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, CLIProfile: "custom_port", CLIProfileSet: true, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -231,7 +375,7 @@ func TestResolve_AIConfigPrecedence(t *testing.T) {
 	}
 
 	// 1. Config values
-	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp})
+	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatal(xe)
 	}
@@ -243,6 +387,7 @@ func TestResolve_AIConfigPrecedence(t *testing.T) {
 	got, xe = Resolve(Options{
 		WorkDir:      tmp,
 		HomeDir:      tmp,
+		SystemDir:    t.TempDir(),
 		EnvAIBaseURL: "https://env.api.com",
 		EnvAIModel:   "env-model",
 		EnvAIAPIKey:  "env-key",
@@ -258,6 +403,7 @@ func TestResolve_AIConfigPrecedence(t *testing.T) {
 	got, xe = Resolve(Options{
 		WorkDir:         tmp,
 		HomeDir:         tmp,
+		SystemDir:       t.TempDir(),
 		EnvAIBaseURL:    "https://env.api.com",
 		EnvAIModel:      "env-model",
 		EnvAIAPIKey:     "env-key",
@@ -279,7 +425,7 @@ func TestResolve_AIConfigPrecedence(t *testing.T) {
 func TestResolve_AIAllowPlaintextDefaultsFalse(t *testing.T) {
 	tmp := t.TempDir()
 
-	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp})
+	got, xe := Resolve(Options{WorkDir: tmp, HomeDir: tmp, SystemDir: t.TempDir()})
 	if xe != nil {
 		t.Fatal(xe)
 	}
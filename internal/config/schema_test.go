@@ -0,0 +1,77 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zx06/xsql/internal/stats"
+)
+
+func TestJSONSchema(t *testing.T) {
+	schema := JSONSchema(SchemaOptions{DBTypes: []string{"pg", "mysql"}})
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("schema should be JSON-encodable: %v", err)
+	}
+	if schema["$schema"] == nil || schema["additionalProperties"] != false {
+		t.Fatalf("unexpected root: %v", schema)
+	}
+
+	rootProps := schema["properties"].(map[string]any)
+	for _, key := range []string{"profiles", "ssh_proxies", "mcp", "web", "stats", "ai", "expand_env"} {
+		if _, ok := rootProps[key]; !ok {
+			t.Errorf("missing root property %q", key)
+		}
+	}
+	for _, key := range []string{"ProfileSources", "Files", "ProfileFiles"} {
+		if _, ok := rootProps[key]; ok {
+			t.Errorf("internal field %q should not be in the schema", key)
+		}
+	}
+
+	defs := schema["$defs"].(map[string]any)
+	profile, ok := defs["Profile"].(map[string]any)
+	if !ok {
+		t.Fatalf("missing Profile definition: %v", defs)
+	}
+	props := profile["properties"].(map[string]any)
+	for key := range yamlFields(reflect.TypeOf(Profile{})) {
+		if _, ok := props[key]; !ok {
+			t.Errorf("Profile property %q missing", key)
+		}
+	}
+	dbEnum := props["db"].(map[string]any)["anyOf"].([]any)[0].(map[string]any)["enum"]
+	if !reflect.DeepEqual(dbEnum, []string{"mysql", "pg"}) {
+		t.Errorf("db enum = %v", dbEnum)
+	}
+	port := props["port"].(map[string]any)["anyOf"].([]any)
+	if port[0].(map[string]any)["type"] != "integer" {
+		t.Errorf("port should accept integers: %v", port)
+	}
+}
+
+func TestSchemaDescriptionsMatchFields(t *testing.T) {
+	types := map[string]reflect.Type{}
+	for _, v := range []any{File{}, Profile{}, TLSConfig{}, AuthConfig{}, SSHProxy{}, MCPConfig{}, MCPHTTPConfig{}, WebConfig{}, WebHTTPConfig{}, AIConfig{}, stats.StatsConfig{}} {
+		typ := reflect.TypeOf(v)
+		types[typ.Name()] = typ
+	}
+	check := func(id string) {
+		typeName, key, _ := strings.Cut(id, ".")
+		typ, ok := types[typeName]
+		if !ok {
+			t.Errorf("%s: unknown type", id)
+			return
+		}
+		if _, ok := yamlFields(typ)[key]; !ok {
+			t.Errorf("%s: no such yaml key", id)
+		}
+	}
+	for id := range schemaDescriptions {
+		check(id)
+	}
+	for id := range schemaEnums {
+		check(id)
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"sort"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// SecretField is a config value that holds a secret or a secret reference.
+type SecretField struct {
+	Path  string // e.g. "profiles.prod.password"
+	Value string
+}
+
+// SecretFields lists the non-empty secret values of f, sorted by path.
+func SecretFields(f *File) []SecretField {
+	var out []SecretField
+	add := func(path, value string) {
+		if value != "" {
+			out = append(out, SecretField{Path: path, Value: value})
+		}
+	}
+	for name, p := range f.Profiles {
+		add("profiles."+name+".password", p.Password)
+	}
+	for name, sp := range f.SSHProxies {
+		add("ssh_proxies."+name+".passphrase", sp.Passphrase)
+		add("ssh_proxies."+name+".password", sp.Password)
+	}
+	add("mcp.http.auth_token", f.MCP.HTTP.AuthToken)
+	add("web.http.auth_token", f.Web.HTTP.AuthToken)
+	add("ai.api_key", f.AI.APIKey)
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// isSecretPath reports whether the config key path names one of the fields
+// listed by SecretFields.
+func isSecretPath(path []string) bool {
+	switch len(path) {
+	case 2:
+		return path[0] == "ai" && path[1] == "api_key"
+	case 3:
+		return (path[0] == "profiles" && path[2] == "password") ||
+			(path[0] == "ssh_proxies" && (path[2] == "passphrase" || path[2] == "password")) ||
+			((path[0] == "mcp" || path[0] == "web") && path[1] == "http" && path[2] == "auth_token")
+	}
+	return false
+}
+
+// FileProfile returns profile name as written in the config file at path,
+// without merging other layers, resolving extends or expanding env variables.
+// ok is false when the file does not define the profile.
+func FileProfile(path, name string) (p Profile, ok bool, xe *errors.XError) {
+	cfg, xe := readRawFile(path)
+	if xe != nil {
+		if xe.Code == errors.CodeCfgNotFound {
+			return Profile{}, false, nil
+		}
+		return Profile{}, false, xe
+	}
+	p, ok = cfg.Profiles[name]
+	return p, ok, nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// TagSelectorPrefix marks a profile selector that matches by tag instead of name,
+// e.g. "tag:prod" or "tag:prod,eu" (all tags must match).
+const TagSelectorPrefix = "tag:"
+
+// IsTagSelector reports whether s selects profiles by tag.
+func IsTagSelector(s string) bool {
+	return strings.HasPrefix(s, TagSelectorPrefix)
+}
+
+// ParseTags splits a comma-separated tag list, dropping empty entries. A leading
+// "tag:" is accepted so selectors and plain lists parse the same way.
+func ParseTags(s string) []string {
+	s = strings.TrimPrefix(s, TagSelectorPrefix)
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// HasTags reports whether the profile carries every one of tags.
+func (p Profile) HasTags(tags []string) bool {
+	for _, want := range tags {
+		found := false
+		for _, have := range p.Tags {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchProfiles returns the sorted names of profiles carrying every one of tags.
+func MatchProfiles(profiles map[string]Profile, tags []string) []string {
+	var names []string
+	for name, p := range profiles {
+		if p.HasTags(tags) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectProfile turns a profile selector into a profile name. Plain names are
+// returned unchanged; a tag selector must match exactly one profile.
+func SelectProfile(profiles map[string]Profile, selector string) (string, *errors.XError) {
+	if !IsTagSelector(selector) {
+		return selector, nil
+	}
+	tags := ParseTags(selector)
+	if len(tags) == 0 {
+		return "", errors.New(errors.CodeCfgInvalid, "empty tag selector", map[string]any{"selector": selector})
+	}
+	matches := MatchProfiles(profiles, tags)
+	switch len(matches) {
+	case 0:
+		return "", errors.New(errors.CodeCfgInvalid, "no profile matches selector", map[string]any{"selector": selector})
+	case 1:
+		return matches[0], nil
+	default:
+		return "", errors.New(errors.CodeCfgInvalid, "profile selector matches multiple profiles", map[string]any{
+			"selector": selector,
+			"matches":  matches,
+		})
+	}
+}
+
+// AllTags returns the sorted set of tags used by any profile.
+func AllTags(profiles map[string]Profile) []string {
+	seen := map[string]bool{}
+	for _, p := range profiles {
+		for _, t := range p.Tags {
+			seen[t] = true
+		}
+	}
+	tags := make([]string, 0, len(seen))
+	for t := range seen {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"tag:prod", []string{"prod"}},
+		{"tag:prod, eu", []string{"prod", "eu"}},
+		{"prod,,eu", []string{"prod", "eu"}},
+		{"tag:", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseTags(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTags(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSelectProfile(t *testing.T) {
+	profiles := map[string]Profile{
+		"orders-eu": {DB: "pg", Tags: []string{"prod", "eu"}},
+		"orders-us": {DB: "pg", Tags: []string{"prod", "us"}},
+		"dev":       {DB: "mysql", Tags: []string{"dev"}},
+	}
+
+	if got, xe := SelectProfile(profiles, "dev"); xe != nil || got != "dev" {
+		t.Errorf("plain name: got %q, %v", got, xe)
+	}
+	if got, xe := SelectProfile(profiles, "unknown"); xe != nil || got != "unknown" {
+		t.Errorf("plain names are returned unchanged: got %q, %v", got, xe)
+	}
+	if got, xe := SelectProfile(profiles, "tag:prod,eu"); xe != nil || got != "orders-eu" {
+		t.Errorf("tag:prod,eu: got %q, %v", got, xe)
+	}
+
+	_, xe := SelectProfile(profiles, "tag:prod")
+	if xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Fatalf("expected ambiguity error, got %v", xe)
+	}
+	if got := xe.Details["matches"]; !reflect.DeepEqual(got, []string{"orders-eu", "orders-us"}) {
+		t.Errorf("matches = %v", got)
+	}
+	if _, xe := SelectProfile(profiles, "tag:replica"); xe == nil {
+		t.Error("expected error when no profile matches")
+	}
+	if _, xe := SelectProfile(profiles, "tag:"); xe == nil {
+		t.Error("expected error for empty selector")
+	}
+
+	if got := AllTags(profiles); !reflect.DeepEqual(got, []string{"dev", "eu", "prod", "us"}) {
+		t.Errorf("AllTags = %v", got)
+	}
+}
+
+func TestResolve_TagSelector(t *testing.T) {
+	path := writeConfig(t, `profiles:
+  orders:
+    db: pg
+    tags: [prod, eu]
+  dev:
+    db: mysql
+    tags: [dev]
+`)
+	r, xe := Resolve(Options{ConfigPath: path, CLIProfile: "tag:eu", CLIProfileSet: true})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if r.ProfileName != "orders" || r.Profile.DB != "pg" {
+		t.Errorf("unexpected resolution: %q %+v", r.ProfileName, r.Profile)
+	}
+}
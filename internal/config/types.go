@@ -5,19 +5,36 @@ import "github.com/zx06/xsql/internal/stats"
 // File represents the xsql.yaml configuration structure.
 // Constraint: config priority is CLI > ENV > Config.
 type File struct {
+	// Version is the config layout version (see CurrentVersion); 0 when absent.
+	Version int `yaml:"version,omitempty" json:"version,omitempty"`
+
 	SSHProxies map[string]SSHProxy `yaml:"ssh_proxies" json:"ssh_proxies"`
 	Profiles   map[string]Profile  `yaml:"profiles" json:"profiles"`
 	MCP        MCPConfig           `yaml:"mcp" json:"mcp"`
 	Web        WebConfig           `yaml:"web" json:"web"`
 	Stats      stats.StatsConfig   `yaml:"stats" json:"stats"`
 	AI         AIConfig            `yaml:"ai" json:"ai"`
+
+	// ExpandEnv controls ${VAR} expansion in config values (default true).
+	ExpandEnv *bool `yaml:"expand_env,omitempty" json:"expand_env,omitempty"`
+
+	// ProfileSources records, for profiles that use extends, which profile each
+	// key was taken from (populated by LoadConfig, not read from YAML).
+	ProfileSources map[string]map[string]string `yaml:"-" json:"-"`
+
+	// Files lists the config files that were loaded, lowest precedence first, and
+	// ProfileFiles and SSHProxyFiles the files that define each profile and ssh
+	// proxy in the same order (populated by LoadConfig, not read from YAML).
+	Files         []string            `yaml:"-" json:"-"`
+	ProfileFiles  map[string][]string `yaml:"-" json:"-"`
+	SSHProxyFiles map[string][]string `yaml:"-" json:"-"`
 }
 
 // AIConfig defines the AI LLM service configuration.
 type AIConfig struct {
 	Provider       string `yaml:"provider" json:"provider"`               // default "openai"
 	BaseURL        string `yaml:"base_url" json:"base_url"`               // default "https://api.openai.com/v1"
-	APIKey         string `yaml:"api_key" json:"api_key"`                 // supports keyring:xxx reference
+	APIKey         string `yaml:"api_key" json:"api_key"`                 // supports keyring:/env:/file:/exec:/vault: references
 	AllowPlaintext bool   `yaml:"allow_plaintext" json:"allow_plaintext"` // allow plaintext API key
 	Model          string `yaml:"model" json:"model"`                     // default "gpt-4o"
 	MaxTokens      int    `yaml:"max_tokens" json:"max_tokens"`           // default 2048
@@ -29,24 +46,70 @@ type SSHProxy struct {
 	Port           int    `yaml:"port" json:"port"`
 	User           string `yaml:"user" json:"user"`
 	IdentityFile   string `yaml:"identity_file" json:"identity_file"`
-	Passphrase     string `yaml:"passphrase" json:"passphrase"` // supports keyring:xxx reference
+	Passphrase     string `yaml:"passphrase" json:"passphrase"` // supports keyring:/env:/file:/exec:/vault: references
 	KnownHostsFile string `yaml:"known_hosts_file" json:"known_hosts_file"`
 	SkipHostKey    bool   `yaml:"skip_host_key" json:"skip_host_key"` // strongly discouraged
+
+	// StrictHostKeyChecking is "yes" (default: unknown hosts are rejected) or
+	// "accept-new" (unknown host keys are recorded in known_hosts)
+	StrictHostKeyChecking string `yaml:"strict_host_key_checking,omitempty" json:"strict_host_key_checking,omitempty"`
+
+	// CertificateFile is an OpenSSH user certificate (default <identity_file>-cert.pub when present)
+	CertificateFile string `yaml:"certificate_file,omitempty" json:"certificate_file,omitempty"`
+	// Password enables password auth; supports keyring:/env:/file:/exec:/vault: references
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+	// KeyboardInteractive answers keyboard-interactive prompts (e.g. OTP) on the terminal
+	KeyboardInteractive bool `yaml:"keyboard_interactive,omitempty" json:"keyboard_interactive,omitempty"`
+
+	// IdentityAgent is the ssh-agent socket (default $SSH_AUTH_SOCK); "none" disables the agent
+	IdentityAgent string `yaml:"identity_agent,omitempty" json:"identity_agent,omitempty"`
+
+	// SSHConfigHost fills the fields not set here from that Host in ~/.ssh/config
+	// (HostName, User, Port, IdentityFile, IdentityAgent, ProxyJump, UserKnownHostsFile)
+	SSHConfigHost string `yaml:"ssh_config_host,omitempty" json:"ssh_config_host,omitempty"`
+
+	// Jump names ssh_proxies entries to connect through first, in order (like ProxyJump)
+	Jump []string `yaml:"jump,omitempty" json:"jump,omitempty"`
+
+	// Name is the ssh_proxies key and JumpHosts the resolved jump chain, first
+	// hop first (populated by ResolveSSHProxy, not read from YAML)
+	Name      string     `yaml:"-" json:"-"`
+	JumpHosts []SSHProxy `yaml:"-" json:"-"`
 }
 
 type Profile struct {
 	Description string `yaml:"description" json:"description"` // description to distinguish databases
 	Format      string `yaml:"format" json:"format"`
 
+	// Extends names a base profile whose settings are inherited and may be overridden here.
+	Extends string `yaml:"extends,omitempty" json:"extends,omitempty"`
+
+	// Tags group profiles (e.g. prod, eu, replica) for selectors like "tag:prod".
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+
 	// DB connection
-	DB       string `yaml:"db" json:"db"`   // mysql | pg
-	DSN      string `yaml:"dsn" json:"dsn"` // raw DSN (takes precedence)
+	DB  string `yaml:"db" json:"db"`   // mysql | pg
+	DSN string `yaml:"dsn" json:"dsn"` // raw DSN (takes precedence)
+	// URL such as postgres://user@host:5432/db?sslmode=require; fills the fields below
+	// that are not set explicitly.
+	URL      string `yaml:"url,omitempty" json:"url,omitempty"`
 	Host     string `yaml:"host" json:"host"`
 	Port     int    `yaml:"port" json:"port"`
 	User     string `yaml:"user" json:"user"`
-	Password string `yaml:"password" json:"password"` // supports keyring:xxx reference
+	Password string `yaml:"password" json:"password"` // supports keyring:/env:/file:/exec:/vault: references
 	Database string `yaml:"database" json:"database"`
 
+	// Driver connection parameters, e.g. application_name, search_path (pg) or
+	// charset, time_zone (mysql); they override parameters from url.
+	Params map[string]string `yaml:"params,omitempty" json:"params,omitempty"`
+
+	// TLS settings for the database connection (empty mode keeps the driver default)
+	TLS TLSConfig `yaml:"tls,omitempty" json:"tls,omitempty"`
+
+	// Token authentication: the password is a short-lived token obtained at
+	// connect time (empty provider uses password)
+	Auth AuthConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
+
 	// Security options
 	AllowPlaintext   bool `yaml:"allow_plaintext" json:"allow_plaintext"`       // allow plaintext password
 	UnsafeAllowWrite bool `yaml:"unsafe_allow_write" json:"unsafe_allow_write"` // permit write-capable entrypoints; CLI also requires its runtime flag
@@ -63,6 +126,27 @@ type Profile struct {
 
 	// Resolved SSH config (populated by Resolve, not read from YAML)
 	SSHConfig *SSHProxy `yaml:"-" json:"-"`
+
+	// Driver parameters from the URL query string (populated on load, not read from YAML)
+	URLParams map[string]string `yaml:"-" json:"-"`
+}
+
+// TLSConfig defines TLS settings for a database connection.
+type TLSConfig struct {
+	Mode       string `yaml:"mode,omitempty" json:"mode,omitempty"`               // disable | require | verify-ca | verify-full
+	CAFile     string `yaml:"ca_file,omitempty" json:"ca_file,omitempty"`         // PEM CA bundle (default: system roots)
+	CertFile   string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`     // client certificate
+	KeyFile    string `yaml:"key_file,omitempty" json:"key_file,omitempty"`       // client private key
+	ServerName string `yaml:"server_name,omitempty" json:"server_name,omitempty"` // expected certificate name (default: host)
+}
+
+// AuthConfig defines token authentication for a database connection, e.g. AWS
+// RDS or GCP Cloud SQL IAM auth.
+type AuthConfig struct {
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"` // command | aws-rds | gcp-cloudsql | stub
+	Command  string `yaml:"command,omitempty" json:"command,omitempty"`   // token command for provider command
+	Region   string `yaml:"region,omitempty" json:"region,omitempty"`     // AWS region for aws-rds (default: AWS CLI config)
+	TTL      int    `yaml:"ttl,omitempty" json:"ttl,omitempty"`           // token lifetime in seconds; overrides the provider's
 }
 
 // MCPConfig defines the MCP server configuration.
@@ -74,7 +158,7 @@ type MCPConfig struct {
 // MCPHTTPConfig defines the MCP Streamable HTTP transport configuration.
 type MCPHTTPConfig struct {
 	Addr                string `yaml:"addr" json:"addr"`
-	AuthToken           string `yaml:"auth_token" json:"auth_token"`                       // supports keyring:xxx reference
+	AuthToken           string `yaml:"auth_token" json:"auth_token"`                       // supports keyring:/env:/file:/exec:/vault: references
 	AllowPlaintextToken bool   `yaml:"allow_plaintext_token" json:"allow_plaintext_token"` // allow plaintext token
 }
 
@@ -86,7 +170,7 @@ type WebConfig struct {
 // WebHTTPConfig defines the web HTTP transport configuration.
 type WebHTTPConfig struct {
 	Addr                string `yaml:"addr" json:"addr"`
-	AuthToken           string `yaml:"auth_token" json:"auth_token"`                       // supports keyring:xxx reference
+	AuthToken           string `yaml:"auth_token" json:"auth_token"`                       // supports keyring:/env:/file:/exec:/vault: references
 	AllowPlaintextToken bool   `yaml:"allow_plaintext_token" json:"allow_plaintext_token"` // allow plaintext token
 }
 
@@ -129,13 +213,18 @@ type Options struct {
 
 	// WorkDir is used for default paths (falls back to process cwd if empty).
 	WorkDir string
+
+	// SystemDir holds the system-wide xsql.yaml (defaults to /etc/xsql, or
+	// %ProgramData%\xsql on Windows).
+	SystemDir string
 }
 
 type ProfileInfo struct {
-	Name        string `json:"name"`
-	Description string `json:"description,omitempty"`
-	DB          string `json:"db"`
-	Mode        string `json:"mode"` // "read-only" or "read-write"
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	DB          string   `json:"db"`
+	Mode        string   `json:"mode"` // "read-only" or "read-write"
+	Tags        []string `json:"tags,omitempty"`
 }
 
 func ProfileToInfo(name string, p Profile) ProfileInfo {
@@ -148,5 +237,6 @@ func ProfileToInfo(name string, p Profile) ProfileInfo {
 		Description: p.Description,
 		DB:          p.DB,
 		Mode:        mode,
+		Tags:        p.Tags,
 	}
 }
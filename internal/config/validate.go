@@ -0,0 +1,530 @@
+package config
+
+import (
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/secret"
+)
+
+// Issue severities reported by Validate.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is a single problem found by Validate.
+type Issue struct {
+	Severity string `json:"severity" yaml:"severity"`
+	Code     string `json:"code" yaml:"code"`
+	Path     string `json:"path" yaml:"path"` // dotted key path, e.g. profiles.dev.port
+	File     string `json:"file,omitempty" yaml:"file,omitempty"`
+	Line     int    `json:"line,omitempty" yaml:"line,omitempty"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+// ValidateOptions controls Validate.
+type ValidateOptions struct {
+	// DBTypes lists the accepted values for profile `db` (usually db.RegisteredNames()).
+	DBTypes []string
+	// CheckParams, if set, validates profile params for a db type (usually
+	// db.ValidateParams); a returned error's details.param names the bad key.
+	CheckParams func(dbType string, params map[string]string) *errors.XError
+}
+
+// LoadConfigIssues loads the configuration like LoadConfig, but problems that
+// would stop loading (an unreadable file, an invalid ${VAR} reference, a bad
+// extends or url) are returned as issues and the part they affect is skipped, so
+// Validate can report everything else in the same run.
+func LoadConfigIssues(opts Options) (File, string, []Issue) {
+	var issues []Issue
+	report := func(code, file, path string, xe *errors.XError) {
+		issues = append(issues, loadIssues(code, file, path, xe)...)
+	}
+	f, primary, xe := loadLayers(configPaths(opts), report)
+	if xe != nil {
+		report("invalid_file", primary, "", xe)
+	}
+	return f, primary, issues
+}
+
+// loadIssues converts a problem skipped by a tolerant load into issues; a type
+// mismatch yields one issue per value.
+func loadIssues(code, file, path string, xe *errors.XError) []Issue {
+	var te *yaml.TypeError
+	if stderrors.As(xe.Unwrap(), &te) {
+		out := make([]Issue, 0, len(te.Errors))
+		for _, msg := range te.Errors {
+			is := Issue{Severity: SeverityError, Code: code, Path: path, File: file, Message: msg}
+			if _, err := fmt.Sscanf(msg, "line %d:", &is.Line); err == nil {
+				is.Message = strings.TrimSpace(msg[strings.Index(msg, ":")+1:])
+			}
+			out = append(out, is)
+		}
+		return out
+	}
+	msg := xe.Message
+	if base, ok := xe.Details["extends"]; ok {
+		msg += fmt.Sprintf(": %v", base)
+	}
+	if chain, ok := xe.Details["chain"]; ok {
+		msg += fmt.Sprintf(": %v", chain)
+	}
+	if reason, ok := xe.Details["reason"]; ok {
+		msg += fmt.Sprintf(": %v", reason)
+	}
+	if cause := xe.Unwrap(); cause != nil {
+		msg += ": " + cause.Error()
+	}
+	line, _ := xe.Details["line"].(int)
+	return []Issue{{Severity: SeverityError, Code: code, Path: path, File: file, Line: line, Message: msg}}
+}
+
+// Validate checks a loaded config and returns every problem found, sorted by
+// file and path. Unknown keys are read from the raw files listed in cfg.Files.
+func Validate(cfg File, opts ValidateOptions) []Issue {
+	v := &validator{cfg: cfg, dbTypes: opts.DBTypes, checkParams: opts.CheckParams, raw: map[string]*yaml.Node{}}
+	for _, path := range cfg.Files {
+		v.checkRawFile(path)
+	}
+	v.checkProfiles()
+	v.checkSSHProxies()
+	v.checkServices()
+
+	SortIssues(v.issues)
+	return v.issues
+}
+
+// SortIssues sorts issues by file and path, as Validate returns them.
+func SortIssues(issues []Issue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Path < b.Path
+	})
+}
+
+type validator struct {
+	cfg         File
+	dbTypes     []string
+	checkParams func(string, map[string]string) *errors.XError
+	issues      []Issue
+	raw         map[string]*yaml.Node // top-level mapping of each file, as written
+}
+
+func (v *validator) add(severity, code, path, file, msg string) {
+	v.issues = append(v.issues, Issue{Severity: severity, Code: code, Path: path, File: file, Message: msg})
+}
+
+// profileFile returns the highest-precedence file defining a profile.
+func (v *validator) profileFile(name string) string {
+	if files := v.cfg.ProfileFiles[name]; len(files) > 0 {
+		return files[len(files)-1]
+	}
+	return v.primaryFile()
+}
+
+// sshProxyFile returns the highest-precedence file defining an ssh proxy.
+func (v *validator) sshProxyFile(name string) string {
+	if files := v.cfg.SSHProxyFiles[name]; len(files) > 0 {
+		return files[len(files)-1]
+	}
+	return v.primaryFile()
+}
+
+// keyFile returns the highest-precedence file that sets the dotted key path.
+func (v *validator) keyFile(path string) string {
+	for i := len(v.cfg.Files) - 1; i >= 0; i-- {
+		n := v.raw[v.cfg.Files[i]]
+		for _, key := range strings.Split(path, ".") {
+			n = mappingValue(n, key)
+		}
+		if n != nil {
+			return v.cfg.Files[i]
+		}
+	}
+	return v.primaryFile()
+}
+
+func (v *validator) primaryFile() string {
+	if len(v.cfg.Files) > 0 {
+		return v.cfg.Files[len(v.cfg.Files)-1]
+	}
+	return ""
+}
+
+// checkRawFile reports unknown keys and outdated layouts in a file as written.
+func (v *validator) checkRawFile(path string) {
+	b, _, xe := readConfigBytes(path)
+	if xe != nil {
+		return
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return
+	}
+	if root := documentRoot(&doc); root != nil && root.Kind == yaml.MappingNode {
+		v.raw[path] = root
+		if version, xe := documentVersion(root); xe == nil && version < CurrentVersion {
+			v.add(SeverityWarning, "outdated_version", "version", path,
+				fmt.Sprintf("config layout version %d is older than %d; run 'xsql config migrate'", version, CurrentVersion))
+		}
+	}
+	walkKnownKeys(documentRoot(&doc), reflect.TypeOf(File{}), "", func(keyPath string, line int) {
+		v.issues = append(v.issues, Issue{
+			Severity: SeverityWarning,
+			Code:     "unknown_key",
+			Path:     keyPath,
+			File:     path,
+			Line:     line,
+			Message:  "unknown config key",
+		})
+	})
+}
+
+// walkKnownKeys reports mapping keys in n that have no matching yaml tag in t.
+func walkKnownKeys(n *yaml.Node, t reflect.Type, prefix string, report func(path string, line int)) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if n == nil || n.Kind != yaml.MappingNode {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			walkKnownKeys(n.Content[i+1], t.Elem(), joinKeyPath(prefix, n.Content[i].Value), report)
+		}
+	case reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			ft, ok := fields[key.Value]
+			if !ok {
+				report(joinKeyPath(prefix, key.Value), key.Line)
+				continue
+			}
+			walkKnownKeys(n.Content[i+1], ft, joinKeyPath(prefix, key.Value), report)
+		}
+	}
+}
+
+// yamlFields maps yaml key names to field types for a struct.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func joinKeyPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func (v *validator) checkProfiles() {
+	names := sortedKeys(v.cfg.Profiles)
+	localPorts := map[int][]string{}
+	for _, name := range names {
+		p := v.cfg.Profiles[name]
+		file := v.profileFile(name)
+		path := "profiles." + name
+
+		switch {
+		case p.DB == "":
+			v.add(SeverityError, "missing_db", path+".db", file, "db is required")
+		case len(v.dbTypes) > 0 && !containsString(v.dbTypes, p.DB):
+			v.add(SeverityError, "unknown_db", path+".db", file,
+				fmt.Sprintf("unsupported db type %q (supported: %s)", p.DB, strings.Join(sortedStrings(v.dbTypes), ", ")))
+		}
+		if p.DSN == "" && p.Host == "" {
+			v.add(SeverityWarning, "missing_host", path+".host", file, "neither host nor dsn is set")
+		}
+		v.checkPort(path+".port", file, p.Port)
+		v.checkPort(path+".local_port", file, p.LocalPort)
+		if p.LocalPort > 0 {
+			localPorts[p.LocalPort] = append(localPorts[p.LocalPort], name)
+		}
+		if p.QueryTimeout < 0 {
+			v.add(SeverityError, "invalid_timeout", path+".query_timeout", file, "timeout must not be negative")
+		}
+		if p.SchemaTimeout < 0 {
+			v.add(SeverityError, "invalid_timeout", path+".schema_timeout", file, "timeout must not be negative")
+		}
+		v.checkTLS(path+".tls", file, p.TLS)
+		v.checkParamsOf(path, file, p)
+		v.checkAuth(path, file, p)
+		if isPlaintext(p.Password) && !p.AllowPlaintext {
+			v.add(SeverityError, "plaintext_secret", path+".password", file,
+				"plaintext password requires allow_plaintext: true (or use a keyring:, env:, file:, exec: or vault: reference)")
+		}
+
+		if p.SSHProxy != "" {
+			if _, ok := v.cfg.SSHProxies[p.SSHProxy]; !ok {
+				v.add(SeverityError, "unknown_ssh_proxy", path+".ssh_proxy", file,
+					fmt.Sprintf("ssh_proxy %q is not defined in ssh_proxies", p.SSHProxy))
+			} else if !p.AllowPlaintext {
+				// The jump hosts' passphrases are used with the profile's setting too.
+				names := []string{p.SSHProxy}
+				if resolved, xe := ResolveSSHProxy(v.cfg.SSHProxies, p.SSHProxy); xe == nil {
+					for _, hop := range resolved.JumpHosts {
+						names = append(names, hop.Name)
+					}
+				}
+				for _, n := range names {
+					sp := v.cfg.SSHProxies[n]
+					for _, f := range [][2]string{{"passphrase", sp.Passphrase}, {"password", sp.Password}} {
+						if field, value := f[0], f[1]; isPlaintext(value) {
+							v.add(SeverityError, "plaintext_secret", path+".ssh_proxy", file,
+								fmt.Sprintf("ssh_proxies.%s.%s is plaintext; profile needs allow_plaintext: true", n, field))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	ports := make([]int, 0, len(localPorts))
+	for port := range localPorts {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	for _, port := range ports {
+		users := localPorts[port]
+		if len(users) < 2 {
+			continue
+		}
+		for _, name := range users {
+			v.add(SeverityError, "duplicate_local_port", "profiles."+name+".local_port", v.profileFile(name),
+				fmt.Sprintf("local_port %d is also used by %s", port, strings.Join(without(users, name), ", ")))
+		}
+	}
+}
+
+func (v *validator) checkParamsOf(path, file string, p Profile) {
+	if len(p.Params) == 0 {
+		return
+	}
+	if p.DSN != "" {
+		v.add(SeverityWarning, "params_ignored", path+".params", file, "params are not applied when dsn is set; put them in the dsn")
+	}
+	if v.checkParams == nil || p.DB == "" {
+		return
+	}
+	if xe := v.checkParams(p.DB, p.ConnParams()); xe != nil {
+		keyPath := path + ".params"
+		if param, ok := xe.Details["param"].(string); ok {
+			keyPath += "." + param
+		}
+		v.add(SeverityError, "invalid_param", keyPath, file, xe.Message)
+	}
+}
+
+// tlsModes mirrors db.TLSModes; config does not import the db package.
+var tlsModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+func (v *validator) checkTLS(path, file string, t TLSConfig) {
+	if t.Mode != "" && !containsString(tlsModes, t.Mode) {
+		v.add(SeverityError, "invalid_tls_mode", path+".mode", file,
+			fmt.Sprintf("unsupported tls mode %q (supported: %s)", t.Mode, strings.Join(tlsModes, ", ")))
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		v.add(SeverityError, "incomplete_tls_cert", path, file, "tls cert_file and key_file must be set together")
+	}
+	if t.Mode == "" && t != (TLSConfig{}) {
+		v.add(SeverityWarning, "tls_mode_unset", path+".mode", file, "tls files are set but mode is empty, so the driver default applies")
+	}
+	for key, p := range map[string]string{"ca_file": t.CAFile, "cert_file": t.CertFile, "key_file": t.KeyFile} {
+		if p == "" {
+			continue
+		}
+		if _, err := os.Stat(expandHome(p)); err != nil {
+			v.add(SeverityWarning, "tls_file_unreadable", path+"."+key, file,
+				fmt.Sprintf("tls file is not accessible: %v", err))
+		}
+	}
+}
+
+// authProviders mirrors the providers registered by the dbauth package.
+var authProviders = []string{"aws-rds", "command", "gcp-cloudsql", "stub"}
+
+func (v *validator) checkAuth(path, file string, p Profile) {
+	a := p.Auth
+	if a == (AuthConfig{}) {
+		return
+	}
+	profilePath := path
+	path += ".auth"
+	switch {
+	case a.Provider == "":
+		v.add(SeverityError, "missing_auth_provider", path+".provider", file, "auth.provider is required when auth is set")
+		return
+	case !containsString(authProviders, a.Provider):
+		v.add(SeverityError, "invalid_auth_provider", path+".provider", file,
+			fmt.Sprintf("unsupported auth provider %q (supported: %s)", a.Provider, strings.Join(authProviders, ", ")))
+		return
+	}
+	if a.Provider == "command" && strings.TrimSpace(a.Command) == "" {
+		v.add(SeverityError, "missing_auth_command", path+".command", file, "auth provider command requires auth.command")
+	}
+	if a.Provider != "command" && a.Command != "" {
+		v.add(SeverityWarning, "auth_command_ignored", path+".command", file, "auth.command is only used by provider command")
+	}
+	if a.TTL < 0 {
+		v.add(SeverityError, "invalid_timeout", path+".ttl", file, "ttl must not be negative")
+	}
+	if p.Password != "" {
+		v.add(SeverityError, "auth_password_conflict", path, file, "password must not be set together with auth; the token is the password")
+	}
+	if p.User == "" && p.DSN == "" {
+		v.add(SeverityWarning, "missing_user", path, file, "token authentication usually needs user")
+	}
+	if p.TLS.Mode == "" || p.TLS.Mode == "disable" {
+		v.add(SeverityWarning, "auth_without_tls", profilePath+".tls.mode", file, "tokens are sent as the password; set tls.mode to verify-full (required by RDS and Cloud SQL IAM auth)")
+	}
+}
+
+func (v *validator) checkSSHProxies() {
+	for _, name := range sortedKeys(v.cfg.SSHProxies) {
+		sp := v.cfg.SSHProxies[name]
+		file := v.sshProxyFile(name)
+		path := "ssh_proxies." + name
+		if sp.Host == "" && sp.SSHConfigHost == "" {
+			v.add(SeverityError, "missing_host", path+".host", file, "host is required (or set ssh_config_host)")
+		}
+		v.checkPort(path+".port", file, sp.Port)
+		if sp.IdentityFile != "" {
+			if _, err := os.Stat(expandHome(sp.IdentityFile)); err != nil {
+				v.add(SeverityWarning, "identity_file_unreadable", path+".identity_file", file,
+					fmt.Sprintf("identity file is not accessible: %v", err))
+			}
+		}
+		if sp.CertificateFile != "" {
+			if _, err := os.Stat(expandHome(sp.CertificateFile)); err != nil {
+				v.add(SeverityWarning, "certificate_file_unreadable", path+".certificate_file", file,
+					fmt.Sprintf("certificate file is not accessible: %v", err))
+			}
+		}
+		if sp.KnownHostsFile != "" {
+			if _, err := os.Stat(expandHome(sp.KnownHostsFile)); err != nil {
+				v.add(SeverityWarning, "known_hosts_unreadable", path+".known_hosts_file", file,
+					fmt.Sprintf("known_hosts file is not accessible: %v", err))
+			}
+		}
+		if sp.SkipHostKey {
+			v.add(SeverityWarning, "skip_host_key", path+".skip_host_key", file, "host key verification is disabled")
+		}
+		switch sp.StrictHostKeyChecking {
+		case "", "yes", "accept-new":
+		default:
+			v.add(SeverityError, "invalid_strict_host_key_checking", path+".strict_host_key_checking", file,
+				fmt.Sprintf("strict_host_key_checking must be yes or accept-new, got %q", sp.StrictHostKeyChecking))
+		}
+		if len(sp.Jump) > 0 || sp.SSHConfigHost != "" {
+			if _, xe := ResolveSSHProxy(v.cfg.SSHProxies, name); xe != nil {
+				if chain, ok := xe.Details["chain"]; ok {
+					v.add(SeverityError, "jump_cycle", path+".jump", file, fmt.Sprintf("jump chain loops: %v", chain))
+				} else if _, ok := xe.Details["ssh_config"]; ok {
+					// ~/.ssh/config is machine-local, so this is not a config error.
+					v.add(SeverityWarning, "ssh_config_unresolved", path+".ssh_config_host", file,
+						fmt.Sprintf("%s: %v", xe.Message, xe.Unwrap()))
+				} else {
+					v.add(SeverityError, "unknown_jump_host", path+".jump", file,
+						fmt.Sprintf("jump host %q (used by ssh_proxies.%v) is not defined in ssh_proxies", xe.Details["jump"], xe.Details["ssh_proxy"]))
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) checkServices() {
+	if isPlaintext(v.cfg.MCP.HTTP.AuthToken) && !v.cfg.MCP.HTTP.AllowPlaintextToken {
+		v.add(SeverityError, "plaintext_secret", "mcp.http.auth_token", v.keyFile("mcp.http.auth_token"),
+			"plaintext token requires allow_plaintext_token: true (or use a keyring:, env:, file:, exec: or vault: reference)")
+	}
+	if isPlaintext(v.cfg.Web.HTTP.AuthToken) && !v.cfg.Web.HTTP.AllowPlaintextToken {
+		v.add(SeverityError, "plaintext_secret", "web.http.auth_token", v.keyFile("web.http.auth_token"),
+			"plaintext token requires allow_plaintext_token: true (or use a keyring:, env:, file:, exec: or vault: reference)")
+	}
+	if isPlaintext(v.cfg.AI.APIKey) && !v.cfg.AI.AllowPlaintext {
+		v.add(SeverityError, "plaintext_secret", "ai.api_key", v.keyFile("ai.api_key"),
+			"plaintext API key requires allow_plaintext: true (or use a keyring:, env:, file:, exec: or vault: reference)")
+	}
+}
+
+func (v *validator) checkPort(path, file string, port int) {
+	if port < 0 || port > 65535 {
+		v.add(SeverityError, "invalid_port", path, file, fmt.Sprintf("port %d is out of range 0-65535", port))
+	}
+}
+
+func isPlaintext(s string) bool {
+	return s != "" && !secret.IsRef(s)
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return p
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedStrings(s []string) []string {
+	out := append([]string(nil), s...)
+	sort.Strings(out)
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func without(list []string, s string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
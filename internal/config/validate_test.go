@@ -0,0 +1,303 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func findIssue(issues []Issue, code, path string) *Issue {
+	for i := range issues {
+		if issues[i].Code == code && issues[i].Path == path {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	missingKey := filepath.Join(t.TempDir(), "missing_id_ed25519")
+	path := writeConfig(t, `ssh_proxies:
+  bastion:
+    host: bastion.example.com
+    port: 70000
+    identity_file: `+missingKey+`
+    certificate_file: `+missingKey+`-cert.pub
+    passphrase: plain
+    password: plain
+    strict_host_key_checking: ask
+profiles:
+  dev:
+    db: mysql
+    host: localhost
+    hostt: typo
+    password: secret
+    local_port: 13306
+  prod:
+    db: oracle
+    host: prod.example.com
+    ssh_proxy: bastion
+    local_port: 13306
+  stage:
+    db: pg
+    host: stage.example.com
+    ssh_proxy: missing
+    password: "keyring:stage/pg"
+  ok:
+    db: pg
+    host: ok.example.com
+    password: secret
+    allow_plaintext: true
+    tls:
+      mode: prefer
+      cert_file: `+missingKey+`
+mcp:
+  http:
+    auth_token: token
+`)
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	issues := Validate(cfg, ValidateOptions{DBTypes: []string{"mysql", "pg"}})
+
+	want := []struct {
+		code, path, severity string
+	}{
+		{"unknown_key", "profiles.dev.hostt", SeverityWarning},
+		{"unknown_db", "profiles.prod.db", SeverityError},
+		{"unknown_ssh_proxy", "profiles.stage.ssh_proxy", SeverityError},
+		{"plaintext_secret", "profiles.dev.password", SeverityError},
+		{"plaintext_secret", "profiles.prod.ssh_proxy", SeverityError},
+		{"plaintext_secret", "mcp.http.auth_token", SeverityError},
+		{"invalid_port", "ssh_proxies.bastion.port", SeverityError},
+		{"identity_file_unreadable", "ssh_proxies.bastion.identity_file", SeverityWarning},
+		{"certificate_file_unreadable", "ssh_proxies.bastion.certificate_file", SeverityWarning},
+		{"invalid_strict_host_key_checking", "ssh_proxies.bastion.strict_host_key_checking", SeverityError},
+		{"duplicate_local_port", "profiles.dev.local_port", SeverityError},
+		{"duplicate_local_port", "profiles.prod.local_port", SeverityError},
+		{"invalid_tls_mode", "profiles.ok.tls.mode", SeverityError},
+		{"outdated_version", "version", SeverityWarning},
+		{"incomplete_tls_cert", "profiles.ok.tls", SeverityError},
+		{"tls_file_unreadable", "profiles.ok.tls.cert_file", SeverityWarning},
+	}
+	for _, w := range want {
+		is := findIssue(issues, w.code, w.path)
+		if is == nil {
+			t.Errorf("missing issue %s at %s; got %+v", w.code, w.path, issues)
+			continue
+		}
+		if is.Severity != w.severity {
+			t.Errorf("%s at %s: severity = %s, want %s", w.code, w.path, is.Severity, w.severity)
+		}
+		if is.File != path {
+			t.Errorf("%s at %s: file = %q, want %q", w.code, w.path, is.File, path)
+		}
+	}
+	if is := findIssue(issues, "unknown_key", "profiles.dev.hostt"); is != nil && is.Line != 14 {
+		t.Errorf("unknown key line = %d, want 14", is.Line)
+	}
+	var plainPassword bool
+	for _, is := range issues {
+		plainPassword = plainPassword || (is.Path == "profiles.prod.ssh_proxy" && strings.Contains(is.Message, "ssh_proxies.bastion.password"))
+	}
+	if !plainPassword {
+		t.Errorf("missing plaintext ssh password issue: %+v", issues)
+	}
+	for _, is := range issues {
+		if is.Path == "profiles.ok.password" || is.Path == "profiles.stage.password" {
+			t.Errorf("unexpected issue: %+v", is)
+		}
+	}
+}
+
+func TestLoadConfigIssues_Layers(t *testing.T) {
+	home, work, system := t.TempDir(), t.TempDir(), t.TempDir()
+	write := func(path, content string) string {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	systemFile := write(filepath.Join(system, "xsql.yaml"), "profiles: [unclosed\n")
+	userFile := write(filepath.Join(home, ".config", "xsql", "xsql.yaml"), `ssh_proxies:
+  bastion:
+    port: 22
+profiles:
+  a:
+    extends: b
+  b:
+    extends: a
+  report:
+    url: "mysql://db.internal:notaport/reports"
+mcp:
+  http:
+    auth_token: token
+`)
+	projectFile := write(filepath.Join(work, "xsql.yaml"), `profiles:
+  default:
+    db: pg
+    host: "${UNTERMINATED"
+    ssh_proxy: nope
+    port: abc
+  child:
+    extends: missing
+    db: pg
+    host: child.internal
+`)
+
+	cfg, _, issues := LoadConfigIssues(Options{WorkDir: work, HomeDir: home, SystemDir: system})
+	issues = append(issues, Validate(cfg, ValidateOptions{DBTypes: []string{"mysql", "pg"}})...)
+
+	want := []struct {
+		code, path, file string
+	}{
+		{"invalid_file", "", systemFile},
+		{"extends_cycle", "profiles.a.extends", userFile},
+		{"extends_cycle", "profiles.b.extends", userFile},
+		{"invalid_url", "profiles.report.url", userFile},
+		{"missing_host", "ssh_proxies.bastion.host", userFile},
+		{"plaintext_secret", "mcp.http.auth_token", userFile},
+		{"invalid_env_reference", "", projectFile},
+		{"invalid_value", "", projectFile},
+		{"unknown_extends", "profiles.child.extends", projectFile},
+		{"unknown_ssh_proxy", "profiles.default.ssh_proxy", projectFile},
+	}
+	for _, w := range want {
+		is := findIssue(issues, w.code, w.path)
+		if is == nil {
+			t.Errorf("missing issue %s at %q; got %+v", w.code, w.path, issues)
+			continue
+		}
+		if is.File != w.file || is.Severity != SeverityError {
+			t.Errorf("%s at %q: file = %q, severity = %s; want %q", w.code, w.path, is.File, is.Severity, w.file)
+		}
+	}
+	if is := findIssue(issues, "invalid_env_reference", ""); is != nil && (is.Line != 4 || strings.Contains(is.Message, "UNTERMINATED")) {
+		t.Errorf("env issue = %+v", is)
+	}
+	if is := findIssue(issues, "invalid_value", ""); is != nil && is.Line != 6 {
+		t.Errorf("type issue = %+v", is)
+	}
+	if len(cfg.Files) != 2 {
+		t.Errorf("files = %v", cfg.Files)
+	}
+}
+
+func TestValidate_Clean(t *testing.T) {
+	path := writeConfig(t, `version: 1
+expand_env: true
+profiles:
+  dev:
+    extends: base
+    database: app
+  base:
+    db: pg
+    host: localhost
+    password: "keyring:dev/pg"
+`)
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if issues := Validate(cfg, ValidateOptions{DBTypes: []string{"mysql", "pg"}}); len(issues) != 0 {
+		t.Errorf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestValidate_Params(t *testing.T) {
+	path := writeConfig(t, `profiles:
+  app:
+    db: pg
+    host: localhost
+    params:
+      application_name: xsql
+      user: admin
+  raw:
+    db: pg
+    dsn: "host=localhost"
+    params:
+      application_name: xsql
+`)
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	checked := 0
+	issues := Validate(cfg, ValidateOptions{
+		DBTypes: []string{"pg"},
+		CheckParams: func(dbType string, params map[string]string) *errors.XError {
+			checked++
+			if _, ok := params["user"]; ok {
+				return errors.New(errors.CodeCfgInvalid, "reserved", map[string]any{"param": "user"})
+			}
+			return nil
+		},
+	})
+	if checked != 2 {
+		t.Errorf("CheckParams called %d times, want 2", checked)
+	}
+	if is := findIssue(issues, "invalid_param", "profiles.app.params.user"); is == nil || is.Severity != SeverityError {
+		t.Errorf("missing invalid_param issue: %+v", issues)
+	}
+	if is := findIssue(issues, "params_ignored", "profiles.raw.params"); is == nil || is.Severity != SeverityWarning {
+		t.Errorf("missing params_ignored issue: %+v", issues)
+	}
+}
+
+func TestValidate_Auth(t *testing.T) {
+	path := writeConfig(t, `profiles:
+  rds:
+    db: mysql
+    host: db.example.com
+    user: iam_user
+    auth:
+      provider: aws-rds
+      region: eu-west-1
+    tls:
+      mode: verify-full
+    params:
+      allowCleartextPasswords: "true"
+  cmd:
+    db: pg
+    host: db.example.com
+    user: app
+    password: "keyring:app/pg"
+    auth:
+      provider: command
+  bad:
+    db: pg
+    host: db.example.com
+    user: app
+    auth:
+      provider: kerberos
+      ttl: -1
+`)
+	cfg, _, xe := LoadConfig(Options{ConfigPath: path})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	issues := Validate(cfg, ValidateOptions{DBTypes: []string{"mysql", "pg"}})
+	for _, w := range []struct{ code, path string }{
+		{"missing_auth_command", "profiles.cmd.auth.command"},
+		{"auth_password_conflict", "profiles.cmd.auth"},
+		{"auth_without_tls", "profiles.cmd.tls.mode"},
+		{"invalid_auth_provider", "profiles.bad.auth.provider"},
+	} {
+		if findIssue(issues, w.code, w.path) == nil {
+			t.Errorf("missing issue %s at %s; got %+v", w.code, w.path, issues)
+		}
+	}
+	for _, is := range issues {
+		if is.Path == "profiles.rds" || len(is.Path) > 13 && is.Path[:13] == "profiles.rds." {
+			t.Errorf("unexpected issue: %+v", is)
+		}
+	}
+}
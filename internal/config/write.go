@@ -1,9 +1,11 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -35,6 +37,8 @@ func InitConfig(path string) (string, *errors.XError) {
 	template := `# xsql configuration file
 # Documentation: https://github.com/zx06/xsql/blob/main/docs/config.md
 
+version: ` + strconv.Itoa(CurrentVersion) + `
+
 ssh_proxies: {}
   # example:
   #   host: bastion.example.com
@@ -68,7 +72,7 @@ func SetConfigValue(configPath, key, value string) *errors.XError {
 		return errors.New(errors.CodeCfgNotFound, "no config file found; run 'xsql config init' first", nil)
 	}
 
-	cfg, xe := readFile(configPath)
+	cfg, xe := readRawFile(configPath)
 	if xe != nil {
 		if xe.Code == errors.CodeCfgNotFound {
 			// Create new empty config
@@ -134,17 +138,56 @@ func setProfileField(cfg *File, name, field, value string) *errors.XError {
 		p.Database = value
 	case "dsn":
 		p.DSN = value
+	case "url":
+		p.URL = value
 	case "description":
 		p.Description = value
 	case "format":
 		p.Format = value
 	case "ssh_proxy":
 		p.SSHProxy = value
+	case "extends":
+		p.Extends = value
+	case "tags":
+		p.Tags = ParseTags(value)
+	case "tls.mode":
+		p.TLS.Mode = value
+	case "tls.ca_file":
+		p.TLS.CAFile = value
+	case "tls.cert_file":
+		p.TLS.CertFile = value
+	case "tls.key_file":
+		p.TLS.KeyFile = value
+	case "tls.server_name":
+		p.TLS.ServerName = value
+	case "auth.provider":
+		p.Auth.Provider = value
+	case "auth.command":
+		p.Auth.Command = value
+	case "auth.region":
+		p.Auth.Region = value
+	case "auth.ttl":
+		ttl, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New(errors.CodeCfgInvalid, "auth.ttl must be a number", map[string]any{"value": value})
+		}
+		p.Auth.TTL = ttl
 	case "unsafe_allow_write":
 		p.UnsafeAllowWrite = parseBool(value)
 	case "allow_plaintext":
 		p.AllowPlaintext = parseBool(value)
 	default:
+		if key, ok := strings.CutPrefix(field, "params."); ok && key != "" {
+			if value == "" {
+				delete(p.Params, key)
+				break
+			}
+			if p.Params == nil {
+				p.Params = map[string]string{}
+			}
+			p.Params[key] = value
+			break
+		}
 		return errors.New(errors.CodeCfgInvalid, fmt.Sprintf("unknown profile field: %s", field),
 			map[string]any{"field": field})
 	}
@@ -175,6 +218,26 @@ func setSSHProxyField(cfg *File, name, field, value string) *errors.XError {
 		sp.KnownHostsFile = value
 	case "skip_host_key":
 		sp.SkipHostKey = parseBool(value)
+	case "strict_host_key_checking":
+		sp.StrictHostKeyChecking = value
+	case "certificate_file":
+		sp.CertificateFile = value
+	case "password":
+		sp.Password = value
+	case "keyboard_interactive":
+		sp.KeyboardInteractive = parseBool(value)
+	case "identity_agent":
+		sp.IdentityAgent = value
+	case "ssh_config_host":
+		sp.SSHConfigHost = value
+	case "jump":
+		// Comma-separated ssh_proxies names, first hop first; empty clears it.
+		sp.Jump = nil
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				sp.Jump = append(sp.Jump, hop)
+			}
+		}
 	default:
 		return errors.New(errors.CodeCfgInvalid, fmt.Sprintf("unknown ssh_proxy field: %s", field),
 			map[string]any{"field": field})
@@ -184,22 +247,214 @@ func setSSHProxyField(cfg *File, name, field, value string) *errors.XError {
 	return nil
 }
 
+// writeFile saves cfg to path. An existing file is updated in place: its YAML
+// tree is merged with the new values so comments, key order, quoting and keys
+// xsql does not know about are preserved, and unset fields are not added.
 func writeFile(path string, cfg File) *errors.XError {
 	if dir := filepath.Dir(path); dir != "" {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return errors.Wrap(errors.CodeInternal, "failed to create config directory", map[string]any{"path": path}, err)
 		}
 	}
-	b, err := yaml.Marshal(cfg)
-	if err != nil {
+	cfg.Version = CurrentVersion
+	var src yaml.Node
+	if err := src.Encode(cfg); err != nil {
 		return errors.Wrap(errors.CodeInternal, "failed to marshal config", nil, err)
 	}
 
-	if err := os.WriteFile(path, b, 0600); err != nil {
-		return errors.Wrap(errors.CodeInternal, "failed to write config file", map[string]any{"path": path}, err)
+	typ := reflect.TypeOf(File{})
+	var doc yaml.Node
+	b, encrypted, xe := readConfigBytes(path)
+	if xe != nil && xe.Code != errors.CodeCfgNotFound {
+		return xe
+	}
+	if xe == nil && yaml.Unmarshal(b, &doc) == nil &&
+		documentRoot(&doc) != nil && documentRoot(&doc).Kind == yaml.MappingNode {
+		// Encrypted values are compared and merged as plaintext, then sealed again.
+		encValues, xe := decryptNodes(&doc)
+		if xe != nil {
+			return withDetail(xe, "path", path)
+		}
+		setVersion(documentRoot(&doc), CurrentVersion) // keeps the key at the top when adding it
+		mergeNode(documentRoot(&doc), &src, typ)
+		if xe := reencryptNodes(encValues); xe != nil {
+			return xe
+		}
+	} else {
+		pruneZero(&src, typ)
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&src}}
 	}
 
-	return nil
+	out, xe := encodeDocument(&doc)
+	if xe != nil {
+		return xe
+	}
+	if encrypted {
+		if out, xe = encryptFileBytes(out); xe != nil {
+			return xe
+		}
+	}
+	return writeConfigBytes(path, out)
+}
+
+func encodeDocument(doc *yaml.Node) ([]byte, *errors.XError) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, errors.Wrap(errors.CodeInternal, "failed to marshal config", nil, err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, errors.Wrap(errors.CodeInternal, "failed to marshal config", nil, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// mergeNode updates dst in place to hold the values of src, where t is the Go
+// type both describe. Untouched nodes keep their comments and style.
+func mergeNode(dst, src *yaml.Node, t reflect.Type) {
+	if isOpaqueEnvRef(dst, t) {
+		// The reference was read as unset; keep it unless a value was set.
+		pruneZero(src, t)
+		if isZeroNode(src, t) {
+			return
+		}
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || dst.Kind != src.Kind {
+		replaceNode(dst, src, t)
+		return
+	}
+	switch src.Kind {
+	case yaml.ScalarNode:
+		if dst.Value == src.Value && dst.ShortTag() == src.ShortTag() {
+			return
+		}
+		style := src.Style
+		if dst.ShortTag() == src.ShortTag() && dst.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 &&
+			!strings.Contains(src.Value, "\n") {
+			style = dst.Style
+		}
+		dst.Value, dst.Tag, dst.Style = src.Value, src.Tag, style
+	case yaml.SequenceNode:
+		dst.Content = src.Content
+	case yaml.MappingNode:
+		mergeMapping(dst, src, t)
+	default:
+		replaceNode(dst, src, t)
+	}
+}
+
+func mergeMapping(dst, src *yaml.Node, t reflect.Type) {
+	var fields map[string]reflect.Type
+	if t.Kind() == reflect.Struct {
+		fields = yamlFields(t)
+	}
+	childType := func(key string) reflect.Type {
+		switch t.Kind() {
+		case reflect.Struct:
+			return fields[key]
+		case reflect.Map:
+			return t.Elem()
+		}
+		return nil
+	}
+
+	inSrc := make(map[string]bool, len(src.Content)/2)
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, val := src.Content[i], src.Content[i+1]
+		inSrc[key.Value] = true
+		ft := childType(key.Value)
+		if existing := mappingValue(dst, key.Value); existing != nil {
+			mergeNode(existing, val, ft)
+			continue
+		}
+		pruneZero(val, ft)
+		if fields != nil && isZeroNode(val, ft) {
+			continue
+		}
+		if len(dst.Content) == 0 {
+			dst.Style &^= yaml.FlowStyle
+		}
+		dst.Content = append(dst.Content, key, val)
+	}
+
+	// Drop entries src no longer has: removed map entries and struct fields
+	// that are now empty. Keys unknown to the struct and opaque env references,
+	// which read as unset, are left alone.
+	kept := dst.Content[:0]
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		key := dst.Content[i].Value
+		_, known := fields[key]
+		if inSrc[key] || (fields != nil && !known) || (fields != nil && isOpaqueEnvRef(dst.Content[i+1], fields[key])) {
+			kept = append(kept, dst.Content[i], dst.Content[i+1])
+		}
+	}
+	dst.Content = kept
+}
+
+// replaceNode overwrites dst with src, keeping the comments attached to dst.
+func replaceNode(dst, src *yaml.Node, t reflect.Type) {
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	pruneZero(src, t)
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+}
+
+// pruneZero removes struct fields holding zero values from a freshly encoded
+// node, so new entries only contain what was set.
+func pruneZero(n *yaml.Node, t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || n.Kind != yaml.MappingNode {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		for i := 1; i < len(n.Content); i += 2 {
+			pruneZero(n.Content[i], t.Elem())
+		}
+	case reflect.Struct:
+		fields := yamlFields(t)
+		kept := n.Content[:0]
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			ft := fields[n.Content[i].Value]
+			pruneZero(n.Content[i+1], ft)
+			if ft != nil && isZeroNode(n.Content[i+1], ft) {
+				continue
+			}
+			kept = append(kept, n.Content[i], n.Content[i+1])
+		}
+		n.Content = kept
+	}
+}
+
+// isZeroNode reports whether n encodes the zero value of t. Pointer fields are
+// only zero when null, since an explicit false or 0 differs from unset.
+func isZeroNode(n *yaml.Node, t reflect.Type) bool {
+	if n.ShortTag() == "!!null" {
+		return true
+	}
+	if t != nil && t.Kind() == reflect.Pointer {
+		return false
+	}
+	switch n.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(n.Content) == 0
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!str":
+			return n.Value == ""
+		case "!!int", "!!float":
+			return n.Value == "0"
+		case "!!bool":
+			return n.Value == "false"
+		}
+	}
+	return false
 }
 
 func parseBool(s string) bool {
@@ -227,7 +482,7 @@ func modifyConfig(configPath, name, emptyErrMsg string, allowNotFound bool, fn f
 			configPath = FindConfigPath(Options{})
 		}
 	}
-	cfg, xe := readFile(configPath)
+	cfg, xe := readRawFile(configPath)
 	if xe != nil {
 		if allowNotFound && xe.Code == errors.CodeCfgNotFound {
 			cfg = File{SSHProxies: map[string]SSHProxy{}, Profiles: map[string]Profile{}}
@@ -314,3 +569,62 @@ func FindConfigPath(opts Options) string {
 	}
 	return ""
 }
+
+// FindEntryConfigPath returns the config file to modify for entry name of a
+// top-level section ("profiles" or "ssh_proxies"). With layered configs this is
+// the highest-precedence file that defines the entry, so edits land where it
+// lives; entries not defined anywhere go to FindConfigPath.
+func FindEntryConfigPath(opts Options, section, name string) (string, *errors.XError) {
+	if opts.ConfigPath != "" {
+		return opts.ConfigPath, nil
+	}
+	workDir := opts.WorkDir
+	if workDir == "" {
+		wd, _ := os.Getwd()
+		workDir = wd
+	}
+	homeDir := opts.HomeDir
+	if homeDir == "" {
+		if hd, err := os.UserHomeDir(); err == nil {
+			homeDir = hd
+		}
+	}
+	systemDir := opts.SystemDir
+	if systemDir == "" {
+		systemDir = defaultSystemConfigDir()
+	}
+
+	layers := configLayers(workDir, homeDir, systemDir)
+	for i := len(layers) - 1; i >= 0; i-- {
+		b, _, xe := readConfigBytes(layers[i])
+		if xe != nil {
+			if xe.Code == errors.CodeCfgNotFound {
+				continue
+			}
+			return "", xe
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return "", errors.Wrap(errors.CodeCfgInvalid, "invalid config file", map[string]any{"path": layers[i]}, err)
+		}
+		if mappingValue(mappingValue(documentRoot(&doc), section), name) != nil {
+			return layers[i], nil
+		}
+	}
+	return FindConfigPath(Options{WorkDir: workDir, HomeDir: homeDir}), nil
+}
+
+// FindKeyConfigPath returns the config file SetConfigValue should modify for
+// key (see FindEntryConfigPath).
+func FindKeyConfigPath(opts Options, key string) (string, *errors.XError) {
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) == 3 {
+		switch parts[0] {
+		case "profile":
+			return FindEntryConfigPath(opts, "profiles", parts[1])
+		case "ssh_proxy":
+			return FindEntryConfigPath(opts, "ssh_proxies", parts[1])
+		}
+	}
+	return FindConfigPath(opts), nil
+}
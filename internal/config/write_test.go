@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -115,6 +116,68 @@ func TestSetConfigValue(t *testing.T) {
 		}
 	})
 
+	t.Run("set profile tls", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "xsql.yaml")
+		if err := os.WriteFile(path, []byte("profiles: {}\nssh_proxies: {}\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		for key, value := range map[string]string{
+			"profile.prod.tls.mode":    "verify-full",
+			"profile.prod.tls.ca_file": "/etc/ssl/db-ca.pem",
+		} {
+			if xe := SetConfigValue(path, key, value); xe != nil {
+				t.Fatalf("%s: unexpected error: %v", key, xe)
+			}
+		}
+
+		f, _ := readFile(path)
+		if tlsCfg := f.Profiles["prod"].TLS; tlsCfg.Mode != "verify-full" || tlsCfg.CAFile != "/etc/ssl/db-ca.pem" {
+			t.Errorf("unexpected tls config: %+v", tlsCfg)
+		}
+	})
+
+	t.Run("set and unset profile params", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "xsql.yaml")
+		if err := os.WriteFile(path, []byte("profiles: {}\nssh_proxies: {}\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, kv := range [][2]string{
+			{"profile.prod.params.application_name", "xsql"},
+			{"profile.prod.params.search_path", "app, public"},
+			{"profile.prod.params.search_path", ""},
+		} {
+			if xe := SetConfigValue(path, kv[0], kv[1]); xe != nil {
+				t.Fatalf("%s: unexpected error: %v", kv[0], xe)
+			}
+		}
+
+		f, _ := readFile(path)
+		if params := f.Profiles["prod"].Params; len(params) != 1 || params["application_name"] != "xsql" {
+			t.Errorf("unexpected params: %v", params)
+		}
+	})
+
+	t.Run("set profile tags", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "xsql.yaml")
+		if err := os.WriteFile(path, []byte("profiles: {}\nssh_proxies: {}\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if xe := SetConfigValue(path, "profile.prod.tags", "prod, eu"); xe != nil {
+			t.Fatalf("unexpected error: %v", xe)
+		}
+
+		f, _ := readFile(path)
+		if tags := f.Profiles["prod"].Tags; len(tags) != 2 || tags[0] != "prod" || tags[1] != "eu" {
+			t.Errorf("expected tags=[prod eu], got %v", tags)
+		}
+	})
+
 	t.Run("set profile local_port", func(t *testing.T) {
 		dir := t.TempDir()
 		path := filepath.Join(dir, "xsql.yaml")
@@ -383,6 +446,66 @@ func TestFindConfigPath(t *testing.T) {
 	})
 }
 
+func TestFindEntryConfigPath(t *testing.T) {
+	systemDir, homeDir, workDir := t.TempDir(), t.TempDir(), t.TempDir()
+	write := func(path, content string) string {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	sysFile := write(filepath.Join(systemDir, "xsql.yaml"), "profiles:\n  shared: {db: pg}\n  sys: {db: pg}\n")
+	userFile := write(filepath.Join(homeDir, ".config", "xsql", "xsql.yaml"), "ssh_proxies:\n  bastion: {host: b}\nprofiles:\n  mine: {db: pg}\n")
+	dropIn := write(filepath.Join(homeDir, ".config", "xsql", "conf.d", "team.yaml"), "profiles:\n  shared: {db: mysql}\n  team: {db: mysql}\n")
+	projFile := write(filepath.Join(workDir, "xsql.yaml"), "profiles:\n  app: {db: pg}\n")
+	opts := Options{WorkDir: workDir, HomeDir: homeDir, SystemDir: systemDir}
+
+	tests := []struct {
+		section, name, want string
+	}{
+		{"profiles", "sys", sysFile},
+		{"profiles", "mine", userFile},
+		{"profiles", "team", dropIn},
+		{"profiles", "shared", dropIn}, // highest-precedence definition
+		{"profiles", "app", projFile},
+		{"profiles", "new", projFile},
+		{"ssh_proxies", "bastion", userFile},
+	}
+	for _, tt := range tests {
+		got, xe := FindEntryConfigPath(opts, tt.section, tt.name)
+		if xe != nil || got != tt.want {
+			t.Errorf("FindEntryConfigPath(%s, %s) = %s, %v; want %s", tt.section, tt.name, got, xe, tt.want)
+		}
+	}
+
+	path, xe := FindKeyConfigPath(opts, "profile.team.user")
+	if xe != nil || path != dropIn {
+		t.Fatalf("FindKeyConfigPath = %s, %v", path, xe)
+	}
+	if xe := SetConfigValue(path, "profile.team.user", "bob"); xe != nil {
+		t.Fatal(xe)
+	}
+	cfg, _, xe := LoadConfig(opts)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if p := cfg.Profiles["team"]; p.User != "bob" || p.DB != "mysql" {
+		t.Errorf("team = %+v", p)
+	}
+	if b, _ := os.ReadFile(projFile); strings.Contains(string(b), "team") {
+		t.Errorf("project file was modified:\n%s", b)
+	}
+
+	// An explicit --config is always the target.
+	if got, _ := FindEntryConfigPath(Options{ConfigPath: "/explicit.yaml"}, "profiles", "team"); got != "/explicit.yaml" {
+		t.Errorf("explicit path = %s", got)
+	}
+}
+
 func TestParseBool(t *testing.T) {
 	cases := []struct {
 		input string
@@ -719,3 +842,66 @@ func TestSaveAndDeleteSSHProxy(t *testing.T) {
 		}
 	})
 }
+
+func TestWriteFile_PreservesComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xsql.yaml")
+	original := `# team config
+profiles:
+  # primary database
+  dev:
+    db: pg # postgres
+    host: "old.example.com"
+    password: "keyring:dev/pg"
+    custom_note: keep me
+  gone:
+    db: mysql
+    host: localhost
+`
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if xe := SetConfigValue(path, "profile.dev.host", "new.example.com"); xe != nil {
+		t.Fatal(xe)
+	}
+	if xe := DeleteProfile(path, "gone"); xe != nil {
+		t.Fatal(xe)
+	}
+
+	want := `# team config
+version: 1
+profiles:
+  # primary database
+  dev:
+    db: pg # postgres
+    host: "new.example.com"
+    password: "keyring:dev/pg"
+    custom_note: keep me
+`
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("unexpected file content:\n%s\nwant:\n%s", b, want)
+	}
+}
+
+func TestWriteFile_NewFileOmitsUnsetFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xsql.yaml")
+	if xe := SaveProfile(path, "dev", Profile{DB: "pg", Host: "localhost"}); xe != nil {
+		t.Fatal(xe)
+	}
+	want := `version: 1
+profiles:
+  dev:
+    db: pg
+    host: localhost
+`
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("unexpected file content:\n%s\nwant:\n%s", b, want)
+	}
+}
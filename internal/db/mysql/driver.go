@@ -3,10 +3,13 @@ package mysql
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"log"
 	"net"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -21,6 +24,8 @@ var (
 	dialers                 sync.Map
 	registerDialContextFn   = mysql.RegisterDialContext
 	deregisterDialContextFn = mysql.DeregisterDialContext
+
+	tlsCounter uint64
 )
 
 func init() {
@@ -55,12 +60,85 @@ func cleanupDialContext(dialName string) {
 	deregisterDialContextFn(dialName)
 }
 
+// registerTLSConfig registers a per-connection TLS config under a unique name,
+// since the driver only carries TLS settings through the DSN by name.
+func registerTLSConfig(cfg *tls.Config) (string, error) {
+	name := fmt.Sprintf("xsql_tls_%d", atomic.AddUint64(&tlsCounter, 1))
+	if err := mysql.RegisterTLSConfig(name, cfg); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func cleanupTLSConfig(name string) {
+	if name == "" {
+		return
+	}
+	mysql.DeregisterTLSConfig(name)
+}
+
 type Driver struct{}
 
-func (d *Driver) Open(ctx context.Context, opts db.ConnOptions) (*sql.DB, *errors.XError) {
-	cfg := mysql.NewConfig()
-	var dialName string
+// ValidateParams implements db.ParamValidator. Keys are driver options
+// (charset, collation, loc, ...), which configure the connection, or session
+// system variables such as time_zone, which are SET after login and whose string
+// values must be quoted the MySQL way (time_zone='+00:00').
+func (d *Driver) ValidateParams(params map[string]string) *errors.XError {
+	if xe := db.CheckParamKeys("mysql", params, nil); xe != nil {
+		return xe
+	}
+	// LOAD DATA LOCAL INFILE from arbitrary paths has no place in a query tool.
+	if _, ok := params["allowAllFiles"]; ok {
+		return errors.New(errors.CodeCfgInvalid, "unsafe connection parameter is not allowed", map[string]any{
+			"db":    "mysql",
+			"param": "allowAllFiles",
+		})
+	}
+	if _, err := withParams(mysql.NewConfig(), params); err != nil {
+		return errors.Wrap(errors.CodeCfgInvalid, "invalid connection parameter", map[string]any{"db": "mysql"}, err)
+	}
+	return nil
+}
+
+// paramEscaper escapes the characters that would otherwise end a DSN query
+// value, or be decoded by the driver, so every value reaches it as written.
+var paramEscaper = strings.NewReplacer("%", "%25", "&", "%26", "+", "%2B", "/", "%2F")
+
+// withParams applies params the way the driver reads DSN query parameters:
+// driver options (charset, collation, loc, allowCleartextPasswords, ...) set
+// the matching Config fields, and only the rest are left in Params, which the
+// driver sends as SET statements after login.
+func withParams(cfg *mysql.Config, params map[string]string) (*mysql.Config, error) {
+	if len(params) == 0 {
+		return cfg, nil
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	dsn := cfg.FormatDSN()
+	sep := "?"
+	if strings.Contains(dsn[strings.LastIndexByte(dsn, '/'):], "?") {
+		sep = "&"
+	}
+	var b strings.Builder
+	b.WriteString(dsn)
+	for _, k := range keys {
+		b.WriteString(sep)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(paramEscaper.Replace(params[k]))
+		sep = "&"
+	}
+	return mysql.ParseDSN(b.String())
+}
 
+// config builds the driver config for opts, without the TLS config and dialer,
+// which are registered per connection by Open.
+func (d *Driver) config(opts db.ConnOptions) (*mysql.Config, *errors.XError) {
+	cfg := mysql.NewConfig()
 	if opts.DSN != "" {
 		parsed, err := mysql.ParseDSN(opts.DSN)
 		if err != nil {
@@ -68,17 +146,58 @@ func (d *Driver) Open(ctx context.Context, opts db.ConnOptions) (*sql.DB, *error
 		}
 		cfg = parsed
 	} else {
+		if xe := d.ValidateParams(opts.Params); xe != nil {
+			return nil, xe
+		}
 		cfg.User = opts.User
 		cfg.Passwd = opts.Password
 		cfg.Net = "tcp"
 		cfg.Addr = fmt.Sprintf("%s:%d", opts.Host, opts.Port)
 		cfg.DBName = opts.Database
 		cfg.ParseTime = true
-		if cfg.Params == nil {
-			cfg.Params = make(map[string]string)
+		parsed, err := withParams(cfg, opts.Params)
+		if err != nil {
+			return nil, errors.Wrap(errors.CodeCfgInvalid, "invalid connection parameter", map[string]any{"db": "mysql"}, err)
+		}
+		cfg = parsed
+	}
+	if opts.Token != nil {
+		// IAM tokens are sent with the cleartext auth plugin.
+		cfg.AllowCleartextPasswords = true
+	}
+	return cfg, nil
+}
+
+func (d *Driver) Open(ctx context.Context, opts db.ConnOptions) (*sql.DB, *errors.XError) {
+	cfg, xe := d.config(opts)
+	if xe != nil {
+		return nil, xe
+	}
+	var dialName string
+
+	var tlsName string
+	if opts.TLS.Mode != "" {
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			host = cfg.Addr
+		}
+		tlsConfig, xe := opts.TLS.Config(host)
+		if xe != nil {
+			return nil, xe
 		}
-		for k, v := range opts.Params {
-			cfg.Params[k] = v
+		cfg.TLS = nil
+		cfg.TLSConfig = "false"
+		if tlsConfig != nil {
+			tlsName, err = registerTLSConfig(db.ObserveTLS(tlsConfig, opts.OnTLSHandshake))
+			if err != nil {
+				return nil, errors.Wrap(errors.CodeInternal, "failed to register mysql tls config", nil, err)
+			}
+			cfg.TLSConfig = tlsName
+			if opts.RegisterCloseHook != nil {
+				opts.RegisterCloseHook(func() {
+					cleanupTLSConfig(tlsName)
+				})
+			}
 		}
 	}
 
@@ -92,16 +211,36 @@ func (d *Driver) Open(ctx context.Context, opts db.ConnOptions) (*sql.DB, *error
 		}
 	}
 
-	dsn := cfg.FormatDSN()
-	conn, err := sql.Open("mysql", dsn)
+	if opts.Token != nil {
+		// Fail with the provider's error rather than a generic ping failure.
+		if _, xe := opts.Token.Token(ctx); xe != nil {
+			cleanupDialContext(dialName)
+			cleanupTLSConfig(tlsName)
+			return nil, xe
+		}
+		_ = cfg.Apply(mysql.BeforeConnect(func(ctx context.Context, c *mysql.Config) error {
+			token, xe := opts.Token.Token(ctx)
+			if xe != nil {
+				return xe
+			}
+			c.Passwd = token
+			return nil
+		}))
+	}
+
+	connector, err := mysql.NewConnector(cfg)
 	if err != nil {
+		cleanupDialContext(dialName)
+		cleanupTLSConfig(tlsName)
 		return nil, errors.Wrap(errors.CodeDBConnectFailed, "failed to open mysql connection", nil, err)
 	}
+	conn := sql.OpenDB(connector)
 	if err := conn.PingContext(ctx); err != nil {
 		if closeErr := conn.Close(); closeErr != nil {
 			log.Printf("failed to close mysql connection: %v", closeErr)
 		}
 		cleanupDialContext(dialName)
+		cleanupTLSConfig(tlsName)
 		return nil, errors.Wrap(errors.CodeDBConnectFailed, "failed to ping mysql", nil, err)
 	}
 	return conn, nil
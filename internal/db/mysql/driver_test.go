@@ -8,7 +8,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/db"
+	"github.com/zx06/xsql/internal/errors"
 )
 
 func TestDriver_Registered(t *testing.T) {
@@ -264,3 +266,124 @@ func resetSyncMap(m *sync.Map) {
 		return true
 	})
 }
+
+func TestDriver_Open_WithTLS(t *testing.T) {
+	drv, _ := db.Get("mysql")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var hooks []func()
+	before := atomic.LoadUint64(&tlsCounter)
+	_, xe := drv.Open(ctx, db.ConnOptions{
+		Host:              "127.0.0.1",
+		Port:              59999,
+		User:              "test",
+		TLS:               db.TLSOptions{Mode: db.TLSModeRequire},
+		RegisterCloseHook: func(fn func()) { hooks = append(hooks, fn) },
+	})
+	if xe == nil {
+		t.Fatal("expected connection error")
+	}
+	if atomic.LoadUint64(&tlsCounter) != before+1 {
+		t.Error("expected a tls config to be registered")
+	}
+	if len(hooks) != 1 {
+		t.Fatalf("expected one close hook for the tls config, got %d", len(hooks))
+	}
+	hooks[0]()
+
+	_, xe = drv.Open(ctx, db.ConnOptions{Host: "127.0.0.1", TLS: db.TLSOptions{Mode: "prefer"}})
+	if xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Fatalf("expected CfgInvalid for unknown tls mode, got %v", xe)
+	}
+}
+
+func TestDriver_ValidateParams(t *testing.T) {
+	drv := &Driver{}
+	if xe := drv.ValidateParams(map[string]string{"charset": "utf8mb4", "time_zone": "'+00:00'"}); xe != nil {
+		t.Fatalf("unexpected error: %v", xe)
+	}
+	for _, params := range []map[string]string{
+		{"allowAllFiles": "true"},
+		{"bad key": "x"},
+	} {
+		if xe := drv.ValidateParams(params); xe == nil || xe.Code != errors.CodeCfgInvalid {
+			t.Errorf("%v: expected CfgInvalid, got %v", params, xe)
+		}
+	}
+}
+
+func TestDriver_Config_Params(t *testing.T) {
+	drv := &Driver{}
+	cfg, xe := drv.config(db.ConnOptions{
+		Host:     "db.internal",
+		Port:     3306,
+		User:     "u",
+		Password: "p@ss/word",
+		Database: "app",
+		Params: map[string]string{
+			"charset":                 "utf8mb4",
+			"collation":               "utf8mb4_unicode_ci",
+			"loc":                     "Asia/Shanghai",
+			"allowCleartextPasswords": "true",
+			"time_zone":               "'+00:00'",
+		},
+	})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if cfg.Collation != "utf8mb4_unicode_ci" || !cfg.AllowCleartextPasswords || cfg.Loc.String() != "Asia/Shanghai" {
+		t.Errorf("driver options not applied: collation=%q cleartext=%v loc=%v", cfg.Collation, cfg.AllowCleartextPasswords, cfg.Loc)
+	}
+	// Only session variables are left to be SET after login.
+	if len(cfg.Params) != 1 || cfg.Params["time_zone"] != "'+00:00'" {
+		t.Errorf("params = %v", cfg.Params)
+	}
+	if cfg.User != "u" || cfg.Passwd != "p@ss/word" || cfg.Addr != "db.internal:3306" || cfg.DBName != "app" || !cfg.ParseTime {
+		t.Errorf("connection fields changed: %+v", cfg)
+	}
+
+	if _, xe := drv.config(db.ConnOptions{Host: "h", Params: map[string]string{"allowCleartextPasswords": "maybe"}}); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("expected CfgInvalid for a bad option value, got %v", xe)
+	}
+}
+
+func TestDriver_Config_URLParams(t *testing.T) {
+	p, xe := config.ParseProfileURL("mysql://report@db.internal/reports?charset=utf8mb4&collation=utf8mb4_unicode_ci&sql_mode=%27ANSI%27")
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	cfg, xe := (&Driver{}).config(db.ConnOptions{
+		Host:     p.Host,
+		Port:     p.Port,
+		User:     p.User,
+		Database: p.Database,
+		Params:   p.ConnParams(),
+	})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if cfg.Collation != "utf8mb4_unicode_ci" {
+		t.Errorf("collation = %q", cfg.Collation)
+	}
+	if len(cfg.Params) != 1 || cfg.Params["sql_mode"] != "'ANSI'" {
+		t.Errorf("params = %v", cfg.Params)
+	}
+}
+
+type staticToken string
+
+func (s staticToken) Token(context.Context) (string, *errors.XError) { return string(s), nil }
+
+func TestDriver_Config_TokenAllowsCleartext(t *testing.T) {
+	cfg, xe := (&Driver{}).config(db.ConnOptions{Host: "h", Port: 3306, User: "iam", Token: staticToken("t")})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if !cfg.AllowCleartextPasswords {
+		t.Error("expected a token source to enable cleartext passwords")
+	}
+	if len(cfg.Params) != 0 {
+		t.Errorf("params = %v", cfg.Params)
+	}
+}
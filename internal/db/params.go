@@ -0,0 +1,50 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// CheckParamKeys is a helper for ParamValidator implementations: every key must
+// be a plain identifier (letters, digits, '_' and '.') and must not be one of
+// reserved, which the driver derives from the connection fields.
+func CheckParamKeys(driver string, params map[string]string, reserved []string) *errors.XError {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !validParamKey(k) {
+			return errors.New(errors.CodeCfgInvalid, "invalid connection parameter name", map[string]any{
+				"db":    driver,
+				"param": k,
+			})
+		}
+		for _, r := range reserved {
+			if k == r {
+				return errors.New(errors.CodeCfgInvalid, "connection parameter is set by the profile; use the profile field instead", map[string]any{
+					"db":    driver,
+					"param": k,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func validParamKey(k string) bool {
+	if k == "" {
+		return false
+	}
+	for i, c := range k {
+		switch {
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case i > 0 && (c == '.' || (c >= '0' && c <= '9')):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestCheckParamKeys(t *testing.T) {
+	ok := map[string]string{"application_name": "xsql", "search_path": "app, public", "pool.max": "4"}
+	if xe := CheckParamKeys("pg", ok, []string{"host"}); xe != nil {
+		t.Fatalf("unexpected error: %v", xe)
+	}
+
+	for _, key := range []string{"", "1abc", "bad key", "a=b", "host"} {
+		xe := CheckParamKeys("pg", map[string]string{key: "v"}, []string{"host"})
+		if xe == nil {
+			t.Errorf("%q: expected error", key)
+			continue
+		}
+		if xe.Details["param"] != key {
+			t.Errorf("%q: details.param = %v", key, xe.Details["param"])
+		}
+	}
+}
+
+func TestValidateParams_UnknownDriver(t *testing.T) {
+	if xe := ValidateParams("no-such-driver", map[string]string{"bad key": "v"}); xe != nil {
+		t.Errorf("unknown drivers should not be validated here: %v", xe)
+	}
+}
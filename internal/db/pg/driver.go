@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sort"
 	"strings"
 
 	"github.com/jackc/pgx/v5"
@@ -22,35 +23,57 @@ func init() {
 
 type Driver struct{}
 
+// reservedParams are libpq keywords derived from the profile's own fields.
+var reservedParams = []string{"host", "hostaddr", "port", "user", "password", "passfile", "dbname"}
+
+// ValidateParams implements db.ParamValidator. Other keys are libpq keywords
+// (connect_timeout, sslmode, ...) or server run-time parameters such as
+// application_name and search_path, which pgx sends at startup.
+func (d *Driver) ValidateParams(params map[string]string) *errors.XError {
+	return db.CheckParamKeys("pg", params, reservedParams)
+}
+
 func (d *Driver) Open(ctx context.Context, opts db.ConnOptions) (*sql.DB, *errors.XError) {
 	dsn := opts.DSN
 	if dsn == "" {
+		if xe := d.ValidateParams(opts.Params); xe != nil {
+			return nil, xe
+		}
 		dsn = buildDSN(opts)
 	}
 
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, errors.Wrap(errors.CodeCfgInvalid, "invalid pg dsn", nil, err)
+	}
+	if xe := applyTLS(config, opts); xe != nil {
+		return nil, xe
+	}
+
 	// Use pgx custom dialer
 	if opts.Dialer != nil {
-		config, err := pgx.ParseConfig(dsn)
-		if err != nil {
-			return nil, errors.Wrap(errors.CodeCfgInvalid, "invalid pg dsn", nil, err)
-		}
 		config.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
 			return opts.Dialer.DialContext(ctx, network, addr)
 		}
-		conn := stdlib.OpenDB(*config)
-		if err := conn.PingContext(ctx); err != nil {
-			if closeErr := conn.Close(); closeErr != nil {
-				log.Printf("failed to close pg connection: %v", closeErr)
-			}
-			return nil, errors.Wrap(errors.CodeDBConnectFailed, "failed to ping pg", nil, err)
-		}
-		return conn, nil
 	}
 
-	conn, err := sql.Open("pgx", dsn)
-	if err != nil {
-		return nil, errors.Wrap(errors.CodeDBConnectFailed, "failed to open pg connection", nil, err)
+	var openOpts []stdlib.OptionOpenDB
+	if opts.Token != nil {
+		// Fail with the provider's error rather than a generic ping failure.
+		if _, xe := opts.Token.Token(ctx); xe != nil {
+			return nil, xe
+		}
+		openOpts = append(openOpts, stdlib.OptionBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+			token, xe := opts.Token.Token(ctx)
+			if xe != nil {
+				return xe
+			}
+			cc.Password = token
+			return nil
+		}))
 	}
+
+	conn := stdlib.OpenDB(*config, openOpts...)
 	if err := conn.PingContext(ctx); err != nil {
 		if closeErr := conn.Close(); closeErr != nil {
 			log.Printf("failed to close pg connection: %v", closeErr)
@@ -60,25 +83,57 @@ func (d *Driver) Open(ctx context.Context, opts db.ConnOptions) (*sql.DB, *error
 	return conn, nil
 }
 
+// applyTLS replaces the sslmode-derived TLS settings when opts.TLS.Mode is set
+// and attaches the handshake observer to every attempt (including fallbacks).
+func applyTLS(config *pgx.ConnConfig, opts db.ConnOptions) *errors.XError {
+	if opts.TLS.Mode != "" {
+		tlsConfig, xe := opts.TLS.Config(config.Host)
+		if xe != nil {
+			return xe
+		}
+		config.TLSConfig = tlsConfig
+		config.Fallbacks = nil
+	}
+	config.TLSConfig = db.ObserveTLS(config.TLSConfig, opts.OnTLSHandshake)
+	for _, fb := range config.Fallbacks {
+		fb.TLSConfig = db.ObserveTLS(fb.TLSConfig, opts.OnTLSHandshake)
+	}
+	return nil
+}
+
 func buildDSN(opts db.ConnOptions) string {
 	parts := []string{}
 	if opts.Host != "" {
-		parts = append(parts, fmt.Sprintf("host=%s", opts.Host))
+		parts = append(parts, fmt.Sprintf("host=%s", dsnValue(opts.Host)))
 	}
 	if opts.Port != 0 {
 		parts = append(parts, fmt.Sprintf("port=%d", opts.Port))
 	}
 	if opts.User != "" {
-		parts = append(parts, fmt.Sprintf("user=%s", opts.User))
+		parts = append(parts, fmt.Sprintf("user=%s", dsnValue(opts.User)))
 	}
 	if opts.Password != "" {
-		parts = append(parts, fmt.Sprintf("password=%s", opts.Password))
+		parts = append(parts, fmt.Sprintf("password=%s", dsnValue(opts.Password)))
 	}
 	if opts.Database != "" {
-		parts = append(parts, fmt.Sprintf("dbname=%s", opts.Database))
+		parts = append(parts, fmt.Sprintf("dbname=%s", dsnValue(opts.Database)))
+	}
+	keys := make([]string, 0, len(opts.Params))
+	for k := range opts.Params {
+		keys = append(keys, k)
 	}
-	for k, v := range opts.Params {
-		parts = append(parts, fmt.Sprintf("%s=%s", k, v))
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, dsnValue(opts.Params[k])))
 	}
 	return strings.Join(parts, " ")
 }
+
+// dsnValue quotes a keyword/value DSN value when it is empty or contains
+// spaces, quotes or backslashes (e.g. search_path='app, public').
+func dsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n'\\") {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
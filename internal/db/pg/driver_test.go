@@ -6,7 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+
 	"github.com/zx06/xsql/internal/db"
+	"github.com/zx06/xsql/internal/errors"
 )
 
 func TestDriver_Registered(t *testing.T) {
@@ -93,6 +96,18 @@ func TestBuildDSN(t *testing.T) {
 			},
 			expected: []string{"sslmode=disable", "pool_max_conns=10"},
 		},
+		{
+			name: "quoted values",
+			opts: db.ConnOptions{
+				Host:     "localhost",
+				Password: "it's secret",
+				Params: map[string]string{
+					"search_path":      "app, public",
+					"application_name": "xsql",
+				},
+			},
+			expected: []string{`password='it\'s secret'`, `search_path='app, public'`, "application_name=xsql"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -221,3 +236,77 @@ func TestDriver_Open_ContextCancelled(t *testing.T) {
 		t.Fatal("expected error for cancelled context")
 	}
 }
+
+func TestApplyTLS(t *testing.T) {
+	config, err := pgx.ParseConfig("host=db.example.com user=u sslmode=prefer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Fallbacks) == 0 {
+		t.Fatal("sslmode=prefer should produce a plaintext fallback")
+	}
+	if xe := applyTLS(config, db.ConnOptions{TLS: db.TLSOptions{Mode: db.TLSModeVerifyFull}}); xe != nil {
+		t.Fatal(xe)
+	}
+	if config.TLSConfig == nil || config.TLSConfig.ServerName != "db.example.com" || config.TLSConfig.InsecureSkipVerify {
+		t.Errorf("unexpected tls config: %+v", config.TLSConfig)
+	}
+	if len(config.Fallbacks) != 0 {
+		t.Error("explicit tls mode should drop sslmode fallbacks")
+	}
+
+	config, _ = pgx.ParseConfig("host=db.example.com user=u sslmode=require")
+	if xe := applyTLS(config, db.ConnOptions{TLS: db.TLSOptions{Mode: db.TLSModeDisable}}); xe != nil {
+		t.Fatal(xe)
+	}
+	if config.TLSConfig != nil {
+		t.Error("disable should clear the tls config")
+	}
+}
+
+func TestDriver_Open_InvalidTLSMode(t *testing.T) {
+	drv, _ := db.Get("pg")
+	_, xe := drv.Open(context.Background(), db.ConnOptions{
+		Host: "127.0.0.1",
+		TLS:  db.TLSOptions{Mode: "prefer"},
+	})
+	if xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Fatalf("expected CfgInvalid, got %v", xe)
+	}
+}
+
+func TestDriver_ValidateParams(t *testing.T) {
+	drv := &Driver{}
+	if xe := drv.ValidateParams(map[string]string{"application_name": "xsql", "search_path": "app", "sslmode": "require"}); xe != nil {
+		t.Fatalf("unexpected error: %v", xe)
+	}
+	for _, key := range []string{"host", "user", "password", "dbname"} {
+		if xe := drv.ValidateParams(map[string]string{key: "x"}); xe == nil {
+			t.Errorf("%s should be rejected", key)
+		}
+	}
+
+	_, xe := drv.Open(context.Background(), db.ConnOptions{Host: "127.0.0.1", Params: map[string]string{"user": "root"}})
+	if xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Fatalf("expected CfgInvalid from Open, got %v", xe)
+	}
+}
+
+func TestBuildDSN_QuotedValuesParse(t *testing.T) {
+	dsn := buildDSN(db.ConnOptions{
+		Host:     "localhost",
+		User:     "app",
+		Password: `p'a\ss word`,
+		Params:   map[string]string{"search_path": "app, public"},
+	})
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("ParseConfig(%q): %v", dsn, err)
+	}
+	if config.Password != `p'a\ss word` {
+		t.Errorf("password = %q", config.Password)
+	}
+	if got := config.RuntimeParams["search_path"]; got != "app, public" {
+		t.Errorf("search_path = %q", got)
+	}
+}
@@ -0,0 +1,285 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// Projection describes column selection applied to a QueryResult after the query ran.
+// Steps are applied in order: Select, Columns, Exclude, Rename.
+type Projection struct {
+	Columns []string          // keep only these columns, in this order
+	Exclude []string          // drop these columns
+	Rename  map[string]string // old name -> new name
+	Select  []string          // JSONPath-style expressions into JSON columns, e.g. "uid=payload.user.id"
+}
+
+// IsZero reports whether the projection leaves results unchanged.
+func (p Projection) IsZero() bool {
+	return len(p.Columns) == 0 && len(p.Exclude) == 0 && len(p.Rename) == 0 && len(p.Select) == 0
+}
+
+// Validate checks the select expressions without needing a result.
+func (p Projection) Validate() *errors.XError {
+	_, xe := parseSelectExprs(p.Select)
+	return xe
+}
+
+// ParseRename parses "old=new" pairs into a rename map.
+func ParseRename(pairs []string) (map[string]string, *errors.XError) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		oldName, newName, ok := strings.Cut(pair, "=")
+		oldName, newName = strings.TrimSpace(oldName), strings.TrimSpace(newName)
+		if !ok || oldName == "" || newName == "" {
+			return nil, errors.New(errors.CodeCfgInvalid, "invalid rename, expected old=new", map[string]any{"rename": pair})
+		}
+		m[oldName] = newName
+	}
+	return m, nil
+}
+
+// Project returns a new result with the projection applied. The receiver is not modified.
+func (r *QueryResult) Project(p Projection) (*QueryResult, *errors.XError) {
+	if r == nil || p.IsZero() {
+		return r, nil
+	}
+	selects, xe := parseSelectExprs(p.Select)
+	if xe != nil {
+		return nil, xe
+	}
+
+	// Select: derive new columns from nested JSON values.
+	cols := append([]string(nil), r.Columns...)
+	derived := make([]map[string]any, len(r.Rows))
+	for _, s := range selects {
+		if !containsString(r.Columns, s.column) {
+			return nil, unknownColumn(s.column, r.Columns)
+		}
+		if containsString(cols, s.name) {
+			return nil, errors.New(errors.CodeCfgInvalid, "duplicate column name", map[string]any{"column": s.name})
+		}
+		cols = append(cols, s.name)
+		for i, row := range r.Rows {
+			if derived[i] == nil {
+				derived[i] = map[string]any{}
+			}
+			derived[i][s.name] = s.eval(row[s.column])
+		}
+	}
+
+	// Columns: keep only the requested columns, in the requested order.
+	if len(p.Columns) > 0 {
+		kept := make([]string, 0, len(p.Columns))
+		for _, c := range p.Columns {
+			if !containsString(cols, c) {
+				return nil, unknownColumn(c, cols)
+			}
+			if !containsString(kept, c) {
+				kept = append(kept, c)
+			}
+		}
+		cols = kept
+	}
+
+	// Exclude: drop columns.
+	if len(p.Exclude) > 0 {
+		for _, c := range p.Exclude {
+			if !containsString(cols, c) {
+				return nil, unknownColumn(c, cols)
+			}
+		}
+		kept := make([]string, 0, len(cols))
+		for _, c := range cols {
+			if !containsString(p.Exclude, c) {
+				kept = append(kept, c)
+			}
+		}
+		cols = kept
+	}
+
+	// Rename: map output names.
+	names := make([]string, len(cols))
+	copy(names, cols)
+	for oldName := range p.Rename {
+		if !containsString(cols, oldName) {
+			return nil, unknownColumn(oldName, cols)
+		}
+	}
+	for i, c := range cols {
+		if n, ok := p.Rename[c]; ok {
+			names[i] = n
+		}
+	}
+	seen := make(map[string]bool, len(names))
+	for _, n := range names {
+		if seen[n] {
+			return nil, errors.New(errors.CodeCfgInvalid, "duplicate column name", map[string]any{"column": n})
+		}
+		seen[n] = true
+	}
+
+	out := &QueryResult{Columns: names, Rows: make([]map[string]any, 0, len(r.Rows))}
+	for i, row := range r.Rows {
+		nr := make(map[string]any, len(cols))
+		for j, c := range cols {
+			if v, ok := derived[i][c]; ok {
+				nr[names[j]] = v
+			} else {
+				nr[names[j]] = row[c]
+			}
+		}
+		out.Rows = append(out.Rows, nr)
+	}
+	return out, nil
+}
+
+func unknownColumn(column string, available []string) *errors.XError {
+	return errors.New(errors.CodeCfgInvalid, "unknown column", map[string]any{
+		"column":    column,
+		"available": available,
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// selectExpr is a parsed --select expression: [name=][$.]column(.key|[index]|["key"])*
+type selectExpr struct {
+	name   string
+	column string
+	path   []any // string keys and int indexes
+}
+
+func parseSelectExprs(exprs []string) ([]selectExpr, *errors.XError) {
+	out := make([]selectExpr, 0, len(exprs))
+	for _, e := range exprs {
+		s, err := parseSelectExpr(e)
+		if err != nil {
+			return nil, errors.New(errors.CodeCfgInvalid, "invalid select expression", map[string]any{
+				"select": e,
+				"reason": err.Error(),
+			})
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func parseSelectExpr(expr string) (selectExpr, error) {
+	var s selectExpr
+	path := strings.TrimSpace(expr)
+	if name, rest, ok := strings.Cut(path, "="); ok && !strings.ContainsAny(name, ".[") {
+		s.name, path = strings.TrimSpace(name), strings.TrimSpace(rest)
+		if s.name == "" {
+			return s, fmt.Errorf("empty alias")
+		}
+	}
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if s.name == "" {
+		s.name = path
+	}
+
+	// Column name runs until the first '.' or '['.
+	end := strings.IndexAny(path, ".[")
+	if end < 0 {
+		end = len(path)
+	}
+	s.column = path[:end]
+	if s.column == "" {
+		return s, fmt.Errorf("missing column name")
+	}
+
+	rest := path[end:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			n := strings.IndexAny(rest, ".[")
+			if n < 0 {
+				n = len(rest)
+			}
+			if n == 0 {
+				return s, fmt.Errorf("empty key")
+			}
+			s.path = append(s.path, rest[:n])
+			rest = rest[n:]
+		case '[':
+			closeIdx := strings.IndexByte(rest, ']')
+			if closeIdx < 0 {
+				return s, fmt.Errorf("unterminated '['")
+			}
+			inner := strings.TrimSpace(rest[1:closeIdx])
+			rest = rest[closeIdx+1:]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				s.path = append(s.path, inner[1:len(inner)-1])
+				continue
+			}
+			idx, err := strconv.Atoi(inner)
+			if err != nil || idx < 0 {
+				return s, fmt.Errorf("invalid index %q", inner)
+			}
+			s.path = append(s.path, idx)
+		default:
+			return s, fmt.Errorf("unexpected %q", rest[0])
+		}
+	}
+	return s, nil
+}
+
+// eval walks the path through a JSON column value; missing paths yield nil.
+func (s selectExpr) eval(v any) any {
+	cur := decodeJSONValue(v)
+	for _, step := range s.path {
+		switch key := step.(type) {
+		case string:
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return nil
+			}
+			cur = m[key]
+		case int:
+			a, ok := cur.([]any)
+			if !ok || key >= len(a) {
+				return nil
+			}
+			cur = a[key]
+		}
+	}
+	return cur
+}
+
+// decodeJSONValue parses JSON text stored in string/[]byte columns.
+func decodeJSONValue(v any) any {
+	var raw []byte
+	switch val := v.(type) {
+	case string:
+		raw = []byte(val)
+	case []byte:
+		raw = val
+	default:
+		return v
+	}
+	// UseNumber keeps large integers exact.
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil || dec.More() {
+		return v
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func projectionSample() *QueryResult {
+	return &QueryResult{
+		Columns: []string{"id", "name", "payload", "noise"},
+		Rows: []map[string]any{
+			{"id": 1, "name": "alice", "payload": `{"user":{"id":9007199254740993},"tags":["a","b"],"odd key":1}`, "noise": "x"},
+			{"id": 2, "name": "bob", "payload": nil, "noise": "y"},
+		},
+	}
+}
+
+func TestProject_ColumnsExcludeRename(t *testing.T) {
+	res := projectionSample()
+	got, xe := res.Project(Projection{
+		Columns: []string{"name", "id", "noise"},
+		Exclude: []string{"noise"},
+		Rename:  map[string]string{"name": "username"},
+	})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if !reflect.DeepEqual(got.Columns, []string{"username", "id"}) {
+		t.Fatalf("columns = %v", got.Columns)
+	}
+	if !reflect.DeepEqual(got.Rows[1], map[string]any{"username": "bob", "id": 2}) {
+		t.Fatalf("row = %v", got.Rows[1])
+	}
+	if len(res.Columns) != 4 || len(res.Rows[0]) != 4 {
+		t.Fatal("original result must not be modified")
+	}
+}
+
+func TestProject_Select(t *testing.T) {
+	got, xe := projectionSample().Project(Projection{
+		Select:  []string{"uid=$.payload.user.id", "payload.tags[1]", `odd=payload["odd key"]`, "missing=payload.nope[3]"},
+		Exclude: []string{"payload", "noise"},
+	})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	want := []string{"id", "name", "uid", "payload.tags[1]", "odd", "missing"}
+	if !reflect.DeepEqual(got.Columns, want) {
+		t.Fatalf("columns = %v", got.Columns)
+	}
+	row := got.Rows[0]
+	if row["uid"] != json.Number("9007199254740993") || row["payload.tags[1]"] != "b" || row["odd"] != json.Number("1") || row["missing"] != nil {
+		t.Fatalf("row = %v", row)
+	}
+	if got.Rows[1]["uid"] != nil {
+		t.Fatalf("NULL payload should yield nil, got %v", got.Rows[1]["uid"])
+	}
+}
+
+func TestProject_Errors(t *testing.T) {
+	tests := map[string]Projection{
+		"unknown_column":   {Columns: []string{"nope"}},
+		"unknown_exclude":  {Exclude: []string{"nope"}},
+		"unknown_rename":   {Rename: map[string]string{"nope": "x"}},
+		"rename_collision": {Rename: map[string]string{"name": "id"}},
+		"select_unknown":   {Select: []string{"nope.a"}},
+		"select_duplicate": {Select: []string{"name=payload.user"}},
+		"select_bad_index": {Select: []string{"payload.tags[-1]"}},
+		"select_unclosed":  {Select: []string{"payload.tags[1"}},
+	}
+	for name, p := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, xe := projectionSample().Project(p)
+			if xe == nil || xe.Code != errors.CodeCfgInvalid {
+				t.Fatalf("expected CodeCfgInvalid, got %v", xe)
+			}
+		})
+	}
+}
+
+func TestProject_ZeroIsNoop(t *testing.T) {
+	res := projectionSample()
+	got, xe := res.Project(Projection{})
+	if xe != nil || got != res {
+		t.Fatalf("expected same result, got %v, %v", got, xe)
+	}
+}
+
+func TestParseRename(t *testing.T) {
+	m, xe := ParseRename([]string{"a=b", " c = d "})
+	if xe != nil || !reflect.DeepEqual(m, map[string]string{"a": "b", "c": "d"}) {
+		t.Fatalf("got %v, %v", m, xe)
+	}
+	for _, bad := range []string{"a", "=b", "a="} {
+		if _, xe := ParseRename([]string{bad}); xe == nil {
+			t.Errorf("ParseRename(%q) should fail", bad)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package db
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"net"
 	"sync"
@@ -31,11 +32,42 @@ type ConnOptions struct {
 	Database string
 	Params   map[string]string // Extra parameters
 	Dialer   Dialer            // Custom dialer (e.g. SSH tunnel)
+	TLS      TLSOptions        // TLS settings; zero value keeps the driver default
+	// Token, if set, supplies the password for every new physical connection
+	// (short-lived IAM auth tokens) and takes precedence over Password.
+	Token TokenSource
+	// OnTLSHandshake, if set, receives the state of each TLS handshake made by
+	// the driver (used to report the negotiated version and cipher).
+	OnTLSHandshake func(tls.ConnectionState)
 	// RegisterCloseHook allows drivers to register cleanup callbacks that should run
 	// when the owning connection is closed or setup fails.
 	RegisterCloseHook func(fn func())
 }
 
+// TokenSource supplies short-lived database passwords.
+type TokenSource interface {
+	Token(ctx context.Context) (string, *errors.XError)
+}
+
+// ParamValidator is implemented by drivers that can check ConnOptions.Params
+// before connecting (e.g. to reject keys that would override the host or user).
+type ParamValidator interface {
+	ValidateParams(params map[string]string) *errors.XError
+}
+
+// ValidateParams checks connection parameters with the named driver's
+// ParamValidator, if it has one.
+func ValidateParams(name string, params map[string]string) *errors.XError {
+	d, ok := Get(name)
+	if !ok || len(params) == 0 {
+		return nil
+	}
+	if v, ok := d.(ParamValidator); ok {
+		return v.ValidateParams(params)
+	}
+	return nil
+}
+
 var (
 	mu      sync.RWMutex
 	drivers = map[string]Driver{}
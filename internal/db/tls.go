@@ -0,0 +1,159 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// TLS modes accepted by TLSOptions.Mode, named after libpq's sslmode values.
+const (
+	TLSModeDisable    = "disable"     // plaintext only
+	TLSModeRequire    = "require"     // encrypt, do not verify the server certificate
+	TLSModeVerifyCA   = "verify-ca"   // verify the certificate chain, not the host name
+	TLSModeVerifyFull = "verify-full" // verify the chain and that the certificate matches the host
+)
+
+// TLSModes lists the supported TLS modes.
+var TLSModes = []string{TLSModeDisable, TLSModeRequire, TLSModeVerifyCA, TLSModeVerifyFull}
+
+// TLSOptions describes how to secure the database connection. An empty Mode
+// keeps the driver default (pg: prefer, mysql: no TLS unless the DSN asks for it).
+type TLSOptions struct {
+	Mode       string
+	CAFile     string // PEM bundle used instead of the system roots
+	CertFile   string // client certificate (PEM)
+	KeyFile    string // client private key (PEM)
+	ServerName string // name expected in the server certificate; defaults to the host
+}
+
+// Config builds the tls.Config for connecting to host. It returns nil when TLS
+// is disabled or Mode is empty. As with libpq, require plus a CA file verifies
+// the chain like verify-ca.
+func (o TLSOptions) Config(host string) (*tls.Config, *errors.XError) {
+	mode := o.Mode
+	switch mode {
+	case "", TLSModeDisable:
+		return nil, nil
+	case TLSModeRequire, TLSModeVerifyCA, TLSModeVerifyFull:
+	default:
+		return nil, errors.New(errors.CodeCfgInvalid, "unsupported tls mode", map[string]any{
+			"mode":      mode,
+			"supported": TLSModes,
+		})
+	}
+	if mode == TLSModeRequire && o.CAFile != "" {
+		mode = TLSModeVerifyCA
+	}
+
+	serverName := o.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(expandPath(o.CAFile))
+		if err != nil {
+			return nil, errors.Wrap(errors.CodeCfgInvalid, "failed to read tls ca_file", map[string]any{"path": o.CAFile}, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New(errors.CodeCfgInvalid, "tls ca_file contains no PEM certificates", map[string]any{"path": o.CAFile})
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, errors.New(errors.CodeCfgInvalid, "tls cert_file and key_file must be set together", nil)
+		}
+		cert, err := tls.LoadX509KeyPair(expandPath(o.CertFile), expandPath(o.KeyFile))
+		if err != nil {
+			return nil, errors.Wrap(errors.CodeCfgInvalid, "failed to load tls client certificate",
+				map[string]any{"cert_file": o.CertFile, "key_file": o.KeyFile}, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	switch mode {
+	case TLSModeRequire:
+		cfg.InsecureSkipVerify = true
+	case TLSModeVerifyCA:
+		// Go can only skip host name checks by skipping verification entirely,
+		// so the chain is verified by hand.
+		cfg.InsecureSkipVerify = true
+		roots := cfg.RootCAs
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyChain(cs, roots)
+		}
+	}
+	return cfg, nil
+}
+
+func verifyChain(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New(errors.CodeDBConnectFailed, "server presented no tls certificate", nil)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// ObserveTLS returns a copy of cfg that calls fn with the connection state of
+// every successful handshake. It returns cfg unchanged when either is nil.
+func ObserveTLS(cfg *tls.Config, fn func(tls.ConnectionState)) *tls.Config {
+	if cfg == nil || fn == nil {
+		return cfg
+	}
+	out := cfg.Clone()
+	verify := cfg.VerifyConnection
+	out.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+		fn(cs)
+		return nil
+	}
+	return out
+}
+
+// TLSState summarizes a negotiated TLS connection for reporting.
+type TLSState struct {
+	Version     string `json:"version" yaml:"version"`
+	CipherSuite string `json:"cipher_suite" yaml:"cipher_suite"`
+	ServerName  string `json:"server_name,omitempty" yaml:"server_name,omitempty"`
+}
+
+// NewTLSState converts a tls.ConnectionState into a TLSState.
+func NewTLSState(cs tls.ConnectionState) TLSState {
+	return TLSState{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ServerName:  cs.ServerName,
+	}
+}
+
+func expandPath(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return p
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// testServer starts a TLS listener whose certificate is valid for "db.internal"
+// only, and returns its address and the path of the CA (self-signed) PEM.
+func testServer(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "db.internal"},
+		DNSNames:              []string{"db.internal"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = c.(*tls.Conn).Handshake()
+			_ = c.Close()
+		}
+	}()
+	return ln.Addr().String(), caPath
+}
+
+func handshake(addr string, cfg *tls.Config) error {
+	c, err := tls.DialWithDialer(&net.Dialer{Timeout: 2 * time.Second}, "tcp", addr, cfg)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+func TestTLSOptions_Config(t *testing.T) {
+	for _, mode := range []string{"", TLSModeDisable} {
+		cfg, xe := TLSOptions{Mode: mode}.Config("db")
+		if xe != nil || cfg != nil {
+			t.Errorf("mode %q: got %v, %v; want nil config", mode, cfg, xe)
+		}
+	}
+
+	cfg, xe := TLSOptions{Mode: TLSModeVerifyFull}.Config("db.example.com")
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if cfg.InsecureSkipVerify || cfg.ServerName != "db.example.com" {
+		t.Errorf("verify-full: unexpected config %+v", cfg)
+	}
+
+	cfg, xe = TLSOptions{Mode: TLSModeRequire, ServerName: "override"}.Config("db")
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if !cfg.InsecureSkipVerify || cfg.ServerName != "override" {
+		t.Errorf("require: unexpected config %+v", cfg)
+	}
+}
+
+func TestTLSOptions_ConfigErrors(t *testing.T) {
+	cases := []TLSOptions{
+		{Mode: "prefer"},
+		{Mode: TLSModeVerifyFull, CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{Mode: TLSModeRequire, CertFile: "client.pem"},
+	}
+	for _, o := range cases {
+		_, xe := o.Config("db")
+		if xe == nil || xe.Code != errors.CodeCfgInvalid {
+			t.Errorf("%+v: expected CfgInvalid, got %v", o, xe)
+		}
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, xe := (TLSOptions{Mode: TLSModeVerifyCA, CAFile: notPEM}).Config("db"); xe == nil {
+		t.Error("expected error for CA file without certificates")
+	}
+}
+
+func TestTLSOptions_Handshake(t *testing.T) {
+	addr, caPath := testServer(t)
+
+	// The certificate does not name 127.0.0.1: verify-ca accepts it, verify-full does not.
+	cases := []struct {
+		opts TLSOptions
+		ok   bool
+	}{
+		{TLSOptions{Mode: TLSModeRequire}, true},
+		{TLSOptions{Mode: TLSModeRequire, CAFile: caPath}, true},
+		{TLSOptions{Mode: TLSModeVerifyCA, CAFile: caPath}, true},
+		{TLSOptions{Mode: TLSModeVerifyCA}, false}, // self-signed, not in system roots
+		{TLSOptions{Mode: TLSModeVerifyFull, CAFile: caPath}, false},
+		{TLSOptions{Mode: TLSModeVerifyFull, CAFile: caPath, ServerName: "db.internal"}, true},
+	}
+	for _, tc := range cases {
+		cfg, xe := tc.opts.Config("127.0.0.1")
+		if xe != nil {
+			t.Fatalf("%+v: %v", tc.opts, xe)
+		}
+		err := handshake(addr, cfg)
+		if (err == nil) != tc.ok {
+			t.Errorf("%+v: handshake error = %v, want ok=%v", tc.opts, err, tc.ok)
+		}
+	}
+}
+
+func TestObserveTLS(t *testing.T) {
+	addr, caPath := testServer(t)
+	cfg, xe := TLSOptions{Mode: TLSModeVerifyCA, CAFile: caPath}.Config("127.0.0.1")
+	if xe != nil {
+		t.Fatal(xe)
+	}
+
+	var got *TLSState
+	observed := ObserveTLS(cfg, func(cs tls.ConnectionState) {
+		st := NewTLSState(cs)
+		got = &st
+	})
+	if err := handshake(addr, observed); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Version == "" || got.CipherSuite == "" {
+		t.Fatalf("expected negotiated state, got %+v", got)
+	}
+	if cfg.VerifyConnection == nil || ObserveTLS(nil, func(tls.ConnectionState) {}) != nil {
+		t.Error("ObserveTLS should not modify its input or invent a config")
+	}
+}
@@ -0,0 +1,156 @@
+// Package dbauth produces short-lived database passwords (IAM auth tokens such
+// as AWS RDS or GCP Cloud SQL login tokens) at connect time and caches them
+// until shortly before they expire.
+package dbauth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// DefaultTTL is the token lifetime assumed when neither the provider nor the
+// profile reports one.
+const DefaultTTL = 15 * time.Minute
+
+// Request describes the connection a token is issued for.
+type Request struct {
+	DB       string
+	Host     string
+	Port     int
+	User     string
+	Database string
+
+	Command string        // token command for the command provider
+	Region  string        // cloud region, for providers that need one
+	TTL     time.Duration // token lifetime override; 0 uses the provider's
+}
+
+// Token is a database password valid until Expiry.
+type Token struct {
+	Value  string
+	Expiry time.Time // zero when the provider does not report one
+}
+
+// Provider issues tokens.
+type Provider interface {
+	Token(ctx context.Context, req Request) (Token, *errors.XError)
+}
+
+// ProviderFunc adapts a function to Provider.
+type ProviderFunc func(ctx context.Context, req Request) (Token, *errors.XError)
+
+func (f ProviderFunc) Token(ctx context.Context, req Request) (Token, *errors.XError) {
+	return f(ctx, req)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{}
+)
+
+// Register adds a provider under name, replacing any provider of that name.
+func Register(name string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = p
+}
+
+// Providers returns the registered provider names, sorted.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookup(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Source hands out the token for one request, fetching a new one when less
+// than a quarter of the current token's lifetime is left. It implements
+// db.TokenSource.
+type Source struct {
+	name     string
+	provider Provider
+	req      Request
+	now      func() time.Time
+
+	mu      sync.Mutex
+	tok     Token
+	renewAt time.Time
+}
+
+var (
+	sourcesMu sync.Mutex
+	sources   = map[string]*Source{}
+)
+
+// NewSource returns the token source for req. Sources are shared per process,
+// so long-running servers that open a connection per request reuse a token
+// until it is due for refresh instead of fetching one every time.
+func NewSource(provider string, req Request) (*Source, *errors.XError) {
+	p, ok := lookup(provider)
+	if !ok {
+		return nil, errors.New(errors.CodeCfgInvalid, "unknown auth provider", map[string]any{
+			"provider":  provider,
+			"supported": Providers(),
+		})
+	}
+	key := fmt.Sprintf("%s|%s|%s|%d|%s|%s|%s|%s|%s", provider, req.DB, req.Host, req.Port, req.User, req.Database, req.Command, req.Region, req.TTL)
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if s, ok := sources[key]; ok {
+		return s, nil
+	}
+	s := &Source{name: provider, provider: p, req: req, now: time.Now}
+	sources[key] = s
+	return s, nil
+}
+
+// Token returns a valid token, refreshing it first when it is close to expiry.
+func (s *Source) Token(ctx context.Context) (string, *errors.XError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.tok.Value != "" && now.Before(s.renewAt) {
+		return s.tok.Value, nil
+	}
+
+	tok, xe := s.provider.Token(ctx, s.req)
+	if xe != nil {
+		return "", xe
+	}
+	if tok.Value == "" {
+		return "", errors.New(errors.CodeDBAuthFailed, "auth provider returned an empty token", map[string]any{"provider": s.name})
+	}
+	switch {
+	case s.req.TTL > 0:
+		tok.Expiry = now.Add(s.req.TTL)
+	case tok.Expiry.IsZero():
+		tok.Expiry = now.Add(DefaultTTL)
+	}
+	s.tok = tok
+	s.renewAt = tok.Expiry.Add(-tok.Expiry.Sub(now) / 4)
+	return tok.Value, nil
+}
+
+// Expiry returns when the current token expires (zero before the first fetch).
+func (s *Source) Expiry() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tok.Expiry
+}
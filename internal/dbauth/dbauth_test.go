@@ -0,0 +1,119 @@
+package dbauth
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func TestProviders(t *testing.T) {
+	got := strings.Join(Providers(), ",")
+	for _, name := range []string{"aws-rds", "command", "gcp-cloudsql", "stub"} {
+		if !strings.Contains(got, name) {
+			t.Errorf("Providers() = %s, missing %s", got, name)
+		}
+	}
+	if _, xe := NewSource("nope", Request{}); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("unknown provider: got %v, want CfgInvalid", xe)
+	}
+}
+
+func TestSource_RefreshesBeforeExpiry(t *testing.T) {
+	var calls int
+	Register("test-counting", ProviderFunc(func(ctx context.Context, req Request) (Token, *errors.XError) {
+		calls++
+		return Token{Value: fmt.Sprintf("tok-%d", calls)}, nil
+	}))
+	req := Request{Host: "db.example", User: "app", TTL: 20 * time.Minute}
+	src, xe := NewSource("test-counting", req)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	src.now = func() time.Time { return now }
+
+	token := func() string {
+		t.Helper()
+		v, xe := src.Token(context.Background())
+		if xe != nil {
+			t.Fatal(xe)
+		}
+		return v
+	}
+	if v := token(); v != "tok-1" {
+		t.Fatalf("first token = %s", v)
+	}
+	if !src.Expiry().Equal(now.Add(20 * time.Minute)) {
+		t.Errorf("expiry = %v", src.Expiry())
+	}
+	now = now.Add(14 * time.Minute)
+	if v := token(); v != "tok-1" {
+		t.Errorf("token refreshed too early: %s", v)
+	}
+	// Refreshed once less than a quarter of the lifetime (5m) is left.
+	now = now.Add(2 * time.Minute)
+	if v := token(); v != "tok-2" {
+		t.Errorf("token not refreshed: %s", v)
+	}
+
+	// The same request shares the cached token.
+	again, _ := NewSource("test-counting", req)
+	if again != src {
+		t.Error("expected the source to be shared")
+	}
+}
+
+func TestSource_Errors(t *testing.T) {
+	Register("test-failing", ProviderFunc(func(ctx context.Context, req Request) (Token, *errors.XError) {
+		return Token{}, errors.New(errors.CodeDBAuthFailed, "denied", nil)
+	}))
+	Register("test-empty", ProviderFunc(func(ctx context.Context, req Request) (Token, *errors.XError) {
+		return Token{}, nil
+	}))
+	for _, name := range []string{"test-failing", "test-empty"} {
+		src, _ := NewSource(name, Request{})
+		if _, xe := src.Token(context.Background()); xe == nil || xe.Code != errors.CodeDBAuthFailed {
+			t.Errorf("%s: got %v, want DBAuthFailed", name, xe)
+		}
+	}
+}
+
+func TestStubProvider(t *testing.T) {
+	src, xe := NewSource("stub", Request{User: "stubuser", TTL: time.Minute})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	v, xe := src.Token(context.Background())
+	if xe != nil || !strings.HasPrefix(v, "stub-stubuser-") {
+		t.Fatalf("stub token = %q, %v", v, xe)
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	ctx := context.Background()
+	tok, xe := commandToken(ctx, Request{User: "app", Port: 5432, Command: `sh -c 'echo "$XSQL_DB_USER:$XSQL_DB_PORT"'`})
+	if xe != nil || tok.Value != "app:5432" || !tok.Expiry.IsZero() {
+		t.Errorf("plain token = %+v, %v", tok, xe)
+	}
+
+	tok, xe = commandToken(ctx, Request{Command: `echo '{"token":"t1","expires_at":"2030-01-02T03:04:05Z"}'`})
+	if xe != nil || tok.Value != "t1" || tok.Expiry.Year() != 2030 {
+		t.Errorf("json token = %+v, %v", tok, xe)
+	}
+
+	if _, xe := commandToken(ctx, Request{Command: `sh -c 'echo denied >&2; exit 3'`}); xe == nil ||
+		xe.Code != errors.CodeDBAuthFailed || xe.Details["stderr"] != "denied" {
+		t.Errorf("failing command: %v", xe)
+	}
+	if _, xe := commandToken(ctx, Request{}); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("missing command: %v", xe)
+	}
+}
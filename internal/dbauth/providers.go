@@ -0,0 +1,124 @@
+package dbauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/secret"
+)
+
+// CommandTimeout bounds how long a token command may run.
+const CommandTimeout = 30 * time.Second
+
+// maxStderr limits how much command stderr is reported in error details.
+const maxStderr = 512
+
+func init() {
+	Register("command", ProviderFunc(commandToken))
+	Register("aws-rds", ProviderFunc(awsRDSToken))
+	Register("gcp-cloudsql", ProviderFunc(gcpCloudSQLToken))
+	Register("stub", ProviderFunc(stubToken))
+}
+
+// commandToken runs auth.command without a shell. The connection is described
+// to the command by XSQL_DB, XSQL_DB_HOST, XSQL_DB_PORT, XSQL_DB_USER and
+// XSQL_DB_NAME. Its stdout is either the token itself or a JSON object
+// {"token": "...", "expires_at": "<RFC 3339>"}.
+func commandToken(ctx context.Context, req Request) (Token, *errors.XError) {
+	args, xe := secret.SplitArgs(req.Command)
+	if xe != nil {
+		return Token{}, errors.New(errors.CodeCfgInvalid, "invalid auth command: unterminated quote or escape", nil)
+	}
+	if len(args) == 0 {
+		return Token{}, errors.New(errors.CodeCfgInvalid, "auth provider command requires auth.command", nil)
+	}
+	out, xe := runCommand(ctx, args, []string{
+		"XSQL_DB=" + req.DB,
+		"XSQL_DB_HOST=" + req.Host,
+		"XSQL_DB_PORT=" + strconv.Itoa(req.Port),
+		"XSQL_DB_USER=" + req.User,
+		"XSQL_DB_NAME=" + req.Database,
+	})
+	if xe != nil {
+		return Token{}, xe
+	}
+	if !strings.HasPrefix(out, "{") {
+		return Token{Value: out}, nil
+	}
+	var v struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		return Token{}, errors.Wrap(errors.CodeDBAuthFailed, "auth command printed invalid JSON", map[string]any{"command": args[0]}, err)
+	}
+	return Token{Value: v.Token, Expiry: v.ExpiresAt}, nil
+}
+
+// awsRDSToken generates an RDS IAM auth token with the AWS CLI, using its usual
+// credential chain. Tokens are valid for 15 minutes.
+func awsRDSToken(ctx context.Context, req Request) (Token, *errors.XError) {
+	args := []string{"aws", "rds", "generate-db-auth-token",
+		"--hostname", req.Host, "--port", strconv.Itoa(req.Port), "--username", req.User}
+	if req.Region != "" {
+		args = append(args, "--region", req.Region)
+	}
+	out, xe := runCommand(ctx, args, nil)
+	if xe != nil {
+		return Token{}, xe
+	}
+	return Token{Value: out, Expiry: time.Now().Add(15 * time.Minute)}, nil
+}
+
+// gcpCloudSQLToken gets a Cloud SQL IAM login token (an OAuth access token)
+// from gcloud. Access tokens are valid for up to an hour.
+func gcpCloudSQLToken(ctx context.Context, req Request) (Token, *errors.XError) {
+	out, xe := runCommand(ctx, []string{"gcloud", "sql", "generate-login-token"}, nil)
+	if xe != nil {
+		return Token{}, xe
+	}
+	return Token{Value: out, Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+var stubCounter atomic.Uint64
+
+// stubToken issues stub-<user>-<n> tokens without contacting anything, for
+// trying out token auth and for tests.
+func stubToken(ctx context.Context, req Request) (Token, *errors.XError) {
+	return Token{Value: fmt.Sprintf("stub-%s-%d", req.User, stubCounter.Add(1))}, nil
+}
+
+func runCommand(ctx context.Context, args, env []string) (string, *errors.XError) {
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		details := map[string]any{"command": args[0]}
+		if ctx.Err() == context.DeadlineExceeded {
+			details["timeout"] = CommandTimeout.String()
+			return "", errors.Wrap(errors.CodeDBAuthFailed, "auth token command timed out", details, err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			if len(msg) > maxStderr {
+				msg = msg[:maxStderr] + "..."
+			}
+			details["stderr"] = msg
+		}
+		return "", errors.Wrap(errors.CodeDBAuthFailed, "auth token command failed", details, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -4,9 +4,9 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"strings"
+	"time"
 
 	"github.com/zx06/xsql/internal/db"
 	"github.com/zx06/xsql/internal/errors"
@@ -18,52 +18,53 @@ const (
 	FormatCSV      ExportFormat = "csv"
 	FormatJSON     ExportFormat = "json"
 	FormatMarkdown ExportFormat = "markdown"
+	FormatHTML     ExportFormat = "html"
 )
 
+// Meta describes where an exported result came from. Report formats such as html
+// render it alongside the data; plain data formats ignore it.
+type Meta struct {
+	Query       string
+	Profile     string
+	Duration    time.Duration
+	GeneratedAt time.Time
+}
+
+// ExportQueryResult writes result to filePath and returns its absolute path.
+// A .gz/.zst suffix on filePath compresses the file.
 func ExportQueryResult(result *db.QueryResult, format ExportFormat, filePath string) (string, *errors.XError) {
-	if result == nil {
-		return "", errors.New(errors.CodeCfgInvalid, "cannot export nil QueryResult", nil)
+	return ExportQueryResultWithMeta(result, format, filePath, Meta{})
+}
+
+// ExportQueryResultWithMeta is like ExportQueryResult but includes query metadata in report formats.
+func ExportQueryResultWithMeta(result *db.QueryResult, format ExportFormat, filePath string, meta Meta) (string, *errors.XError) {
+	m, xe := ExportFiles(result, format, filePath, Options{Meta: meta})
+	if xe != nil {
+		return "", xe
 	}
+	return m.FilePath(0), nil
+}
 
+func normalizeFormat(format ExportFormat) (ExportFormat, *errors.XError) {
 	format = ExportFormat(strings.ToLower(strings.TrimSpace(string(format))))
 	switch format {
-	case FormatCSV, FormatJSON, FormatMarkdown:
+	case FormatCSV, FormatJSON, FormatMarkdown, FormatHTML:
+		return format, nil
 	default:
 		return "", errors.New(errors.CodeCfgInvalid, "unsupported export format", map[string]any{
 			"format": format,
 		})
 	}
+}
 
-	if filePath == "" {
-		filePath = fmt.Sprintf("export_%s.%s", format, format)
-	}
-
-	// Ensure directory exists
-	dir := filepath.Dir(filePath)
-	if dir != "" && dir != "." {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return "", errors.New(errors.CodeInternal, "failed to create export directory", map[string]any{
-				"dir": dir,
-				"err": err.Error(),
-			})
-		}
-	}
-
-	f, err := os.Create(filePath)
-	if err != nil {
-		return "", errors.New(errors.CodeInternal, "failed to create export file", map[string]any{
-			"path": filePath,
-			"err":  err.Error(),
-		})
-	}
-	defer func() { _ = f.Close() }()
-
+// writeFormat encodes result in the given format.
+func writeFormat(w io.Writer, result *db.QueryResult, format ExportFormat, meta Meta) *errors.XError {
 	switch format {
 	case FormatJSON:
-		enc := json.NewEncoder(f)
+		enc := json.NewEncoder(w)
 		enc.SetIndent("", "  ")
 		if err := enc.Encode(result.Rows); err != nil {
-			return "", errors.New(errors.CodeInternal, "failed to write JSON export", map[string]any{"err": err.Error()})
+			return errors.New(errors.CodeInternal, "failed to write JSON export", map[string]any{"err": err.Error()})
 		}
 
 	case FormatMarkdown:
@@ -76,49 +77,63 @@ func ExportQueryResult(result *db.QueryResult, format ExportFormat, filePath str
 		sb.WriteString("| " + strings.Join(sep, " | ") + " |\n")
 
 		for _, row := range result.Rows {
-			var vals []string
-			for _, col := range result.Columns {
-				val := row[col]
-				if val == nil {
-					vals = append(vals, "NULL")
-				} else {
-					cellStr := fmt.Sprintf("%v", val)
-					cellStr = strings.ReplaceAll(cellStr, "\n", " ")
-					cellStr = strings.ReplaceAll(cellStr, "|", "\\|")
-					vals = append(vals, cellStr)
-				}
-			}
-			sb.WriteString("| " + strings.Join(vals, " | ") + " |\n")
+			sb.WriteString(markdownRow(result.Columns, row))
 		}
-		if _, err := f.WriteString(sb.String()); err != nil {
-			return "", errors.New(errors.CodeInternal, "failed to write Markdown export", map[string]any{"err": err.Error()})
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return errors.New(errors.CodeInternal, "failed to write Markdown export", map[string]any{"err": err.Error()})
+		}
+
+	case FormatHTML:
+		if meta.GeneratedAt.IsZero() {
+			meta.GeneratedAt = time.Now()
+		}
+		if err := writeHTMLReport(w, result, meta); err != nil {
+			return errors.New(errors.CodeInternal, "failed to write HTML export", map[string]any{"err": err.Error()})
 		}
 
 	case FormatCSV:
-		w := csv.NewWriter(f)
-		if err := w.Write(result.Columns); err != nil {
-			return "", errors.New(errors.CodeInternal, "failed to write CSV header", map[string]any{"err": err.Error()})
+		cw := csv.NewWriter(w)
+		if err := cw.Write(result.Columns); err != nil {
+			return errors.New(errors.CodeInternal, "failed to write CSV header", map[string]any{"err": err.Error()})
 		}
 		for _, row := range result.Rows {
-			var vals []string
-			for _, col := range result.Columns {
-				val := row[col]
-				if val == nil {
-					vals = append(vals, "")
-				} else {
-					vals = append(vals, fmt.Sprintf("%v", val))
-				}
-			}
-			if err := w.Write(vals); err != nil {
-				return "", errors.New(errors.CodeInternal, "failed to write CSV row", map[string]any{"err": err.Error()})
+			if err := cw.Write(csvRow(result.Columns, row)); err != nil {
+				return errors.New(errors.CodeInternal, "failed to write CSV row", map[string]any{"err": err.Error()})
 			}
 		}
-		w.Flush()
-		if err := w.Error(); err != nil {
-			return "", errors.New(errors.CodeInternal, "failed to flush CSV writer", map[string]any{"err": err.Error()})
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return errors.New(errors.CodeInternal, "failed to flush CSV writer", map[string]any{"err": err.Error()})
 		}
 	}
+	return nil
+}
 
-	absPath, _ := filepath.Abs(filePath)
-	return absPath, nil
+func markdownRow(columns []string, row map[string]any) string {
+	var vals []string
+	for _, col := range columns {
+		val := row[col]
+		if val == nil {
+			vals = append(vals, "NULL")
+		} else {
+			cellStr := fmt.Sprintf("%v", val)
+			cellStr = strings.ReplaceAll(cellStr, "\n", " ")
+			cellStr = strings.ReplaceAll(cellStr, "|", "\\|")
+			vals = append(vals, cellStr)
+		}
+	}
+	return "| " + strings.Join(vals, " | ") + " |\n"
+}
+
+func csvRow(columns []string, row map[string]any) []string {
+	var vals []string
+	for _, col := range columns {
+		val := row[col]
+		if val == nil {
+			vals = append(vals, "")
+		} else {
+			vals = append(vals, fmt.Sprintf("%v", val))
+		}
+	}
+	return vals
 }
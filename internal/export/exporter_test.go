@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/zx06/xsql/internal/db"
 )
@@ -79,3 +80,48 @@ func TestExportQueryResult_CSV_JSON_MD(t *testing.T) {
 		t.Fatalf("unsupported format should not create a file, stat err=%v", err)
 	}
 }
+
+func TestExportQueryResultWithMeta_HTML(t *testing.T) {
+	res := &db.QueryResult{
+		Columns: []string{"id", "note"},
+		Rows: []map[string]any{
+			{"id": 1, "note": "<script>alert(1)</script>"},
+			{"id": 2, "note": nil},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "report.html")
+	absPath, xe := ExportQueryResultWithMeta(res, FormatHTML, path, Meta{
+		Query:    "SELECT id, note FROM t WHERE a < b",
+		Profile:  "prod",
+		Duration: 1500 * time.Millisecond,
+	})
+	if xe != nil {
+		t.Fatalf("HTML export failed: %v", xe)
+	}
+	b, err := os.ReadFile(absPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(b)
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"SELECT id, note FROM t WHERE a &lt; b",
+		"<dd>prod</dd>",
+		"<dd>1.5s</dd>",
+		"<dd>2</dd>",
+		`<th data-col="1">note</th>`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`<td class="null">NULL</td>`,
+		`id="filter"`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("HTML report missing %q", want)
+		}
+	}
+	if strings.Contains(content, "<script>alert(1)") {
+		t.Error("cell values must be HTML-escaped")
+	}
+	if strings.Contains(content, "src=\"http") || strings.Contains(content, "href=\"http") {
+		t.Error("HTML report must not reference external assets")
+	}
+}
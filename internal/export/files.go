@@ -0,0 +1,371 @@
+package export
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/zx06/xsql/internal/db"
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// Compression selects the compression applied to exported files.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// compressionExts maps file suffixes to the compression they imply.
+var compressionExts = map[string]Compression{
+	".gz":   CompressionGzip,
+	".gzip": CompressionGzip,
+	".zst":  CompressionZstd,
+	".zstd": CompressionZstd,
+}
+
+// formatExts maps file suffixes to the export format they imply.
+var formatExts = map[string]ExportFormat{
+	".csv":      FormatCSV,
+	".json":     FormatJSON,
+	".md":       FormatMarkdown,
+	".markdown": FormatMarkdown,
+	".html":     FormatHTML,
+	".htm":      FormatHTML,
+}
+
+// Options controls how ExportFiles writes a result.
+type Options struct {
+	// Compression overrides the compression detected from the file extension.
+	Compression Compression
+	// SplitRows starts a new file after this many rows (0 disables).
+	SplitRows int
+	// SplitBytes starts a new file once a part reaches about this many uncompressed bytes (0 disables).
+	SplitBytes int64
+	// Meta is rendered by report formats.
+	Meta Meta
+}
+
+// ManifestFile describes one written file.
+type ManifestFile struct {
+	Path   string `json:"path" yaml:"path"`
+	Rows   int    `json:"rows" yaml:"rows"`
+	Bytes  int64  `json:"bytes" yaml:"bytes"`
+	SHA256 string `json:"sha256" yaml:"sha256"`
+}
+
+// Manifest lists the files produced by an export. File paths are relative to Dir.
+type Manifest struct {
+	Format      ExportFormat   `json:"format" yaml:"format"`
+	Compression Compression    `json:"compression" yaml:"compression"`
+	Columns     []string       `json:"columns" yaml:"columns"`
+	TotalRows   int            `json:"total_rows" yaml:"total_rows"`
+	Files       []ManifestFile `json:"files" yaml:"files"`
+
+	// Dir and ManifestPath locate the export on disk; they are not written to the manifest file.
+	Dir          string `json:"dir,omitempty" yaml:"dir,omitempty"`
+	ManifestPath string `json:"manifest_path,omitempty" yaml:"manifest_path,omitempty"`
+}
+
+// FilePath returns the absolute path of the i-th file.
+func (m *Manifest) FilePath(i int) string {
+	if i < 0 || i >= len(m.Files) {
+		return ""
+	}
+	return filepath.Join(m.Dir, m.Files[i].Path)
+}
+
+// ParseCompression validates a compression name; empty means detect from the file extension.
+func ParseCompression(s string) (Compression, *errors.XError) {
+	c := Compression(strings.ToLower(strings.TrimSpace(s)))
+	switch c {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+		return c, nil
+	default:
+		return "", errors.New(errors.CodeCfgInvalid, "unsupported export compression", map[string]any{
+			"compression": s,
+		})
+	}
+}
+
+// DetectFormat infers the export format from a file name such as "out.csv" or "out.json.gz".
+func DetectFormat(filePath string) (ExportFormat, bool) {
+	stem, _ := splitExt(filePath, compressionExts)
+	_, ext := splitExt(stem, formatExts)
+	f, ok := formatExts[strings.ToLower(ext)]
+	return f, ok
+}
+
+// ExportFiles writes result to filePath, optionally compressed and split into parts.
+// In split mode (SplitRows or SplitBytes set), parts are named <stem>_0001<ext> and a
+// <stem>.manifest.json listing files, row counts and SHA-256 checksums is written next
+// to them, even when the result fits in one part.
+func ExportFiles(result *db.QueryResult, format ExportFormat, filePath string, opts Options) (*Manifest, *errors.XError) {
+	if result == nil {
+		return nil, errors.New(errors.CodeCfgInvalid, "cannot export nil QueryResult", nil)
+	}
+	format, xe := normalizeFormat(format)
+	if xe != nil {
+		return nil, xe
+	}
+	if opts.SplitRows < 0 || opts.SplitBytes < 0 {
+		return nil, errors.New(errors.CodeCfgInvalid, "split limits must not be negative", map[string]any{
+			"split_rows":  opts.SplitRows,
+			"split_bytes": opts.SplitBytes,
+		})
+	}
+
+	if filePath == "" {
+		filePath = fmt.Sprintf("export_%s.%s", format, format)
+	}
+	filePath, compression, xe := resolveCompression(filePath, opts.Compression)
+	if xe != nil {
+		return nil, xe
+	}
+
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		absPath = filePath
+	}
+	dir := filepath.Dir(absPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.New(errors.CodeInternal, "failed to create export directory", map[string]any{
+			"dir": dir,
+			"err": err.Error(),
+		})
+	}
+
+	split := opts.SplitRows > 0 || opts.SplitBytes > 0
+	chunks := splitRows(result, format, opts)
+	m := &Manifest{
+		Format:      format,
+		Compression: compression,
+		Columns:     result.Columns,
+		TotalRows:   len(result.Rows),
+		Dir:         dir,
+	}
+	for i, rows := range chunks {
+		path := absPath
+		if split {
+			path = partPath(absPath, i+1)
+		}
+		part := &db.QueryResult{Columns: result.Columns, Rows: rows}
+		mf, xe := writeFile(path, part, format, compression, opts.Meta)
+		if xe != nil {
+			return nil, xe
+		}
+		m.Files = append(m.Files, mf)
+	}
+
+	if split {
+		m.ManifestPath = manifestPath(absPath)
+		if xe := writeManifest(m); xe != nil {
+			return nil, xe
+		}
+	}
+	return m, nil
+}
+
+// resolveCompression picks the compression from opts or the file extension and
+// makes sure the file name carries the matching suffix.
+func resolveCompression(filePath string, c Compression) (string, Compression, *errors.XError) {
+	_, ext := splitExt(filePath, compressionExts)
+	detected, hasExt := compressionExts[strings.ToLower(ext)]
+	switch {
+	case c == "":
+		if hasExt {
+			return filePath, detected, nil
+		}
+		return filePath, CompressionNone, nil
+	case c == CompressionNone:
+		if hasExt {
+			return "", "", errors.New(errors.CodeCfgInvalid, "file extension implies compression but compression is none", map[string]any{
+				"path": filePath,
+			})
+		}
+		return filePath, c, nil
+	case hasExt && detected == c:
+		return filePath, c, nil
+	case hasExt:
+		return "", "", errors.New(errors.CodeCfgInvalid, "file extension does not match export compression", map[string]any{
+			"path":        filePath,
+			"compression": c,
+		})
+	case c == CompressionGzip:
+		return filePath + ".gz", c, nil
+	case c == CompressionZstd:
+		return filePath + ".zst", c, nil
+	default:
+		return "", "", errors.New(errors.CodeCfgInvalid, "unsupported export compression", map[string]any{
+			"compression": c,
+		})
+	}
+}
+
+// splitRows groups rows into parts according to the split limits. It always
+// returns at least one part so an empty result still produces a file.
+func splitRows(result *db.QueryResult, format ExportFormat, opts Options) [][]map[string]any {
+	if opts.SplitRows == 0 && opts.SplitBytes == 0 {
+		return [][]map[string]any{result.Rows}
+	}
+
+	var chunks [][]map[string]any
+	var cur []map[string]any
+	var size int64
+	for _, row := range result.Rows {
+		rs := int64(0)
+		if opts.SplitBytes > 0 {
+			rs = estimateRowSize(result.Columns, row, format)
+		}
+		full := len(cur) > 0 &&
+			((opts.SplitRows > 0 && len(cur) >= opts.SplitRows) ||
+				(opts.SplitBytes > 0 && size+rs > opts.SplitBytes))
+		if full {
+			chunks = append(chunks, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, row)
+		size += rs
+	}
+	if len(cur) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, cur)
+	}
+	return chunks
+}
+
+// estimateRowSize approximates the uncompressed size of a row in the given format.
+func estimateRowSize(columns []string, row map[string]any, format ExportFormat) int64 {
+	switch format {
+	case FormatCSV:
+		var sb strings.Builder
+		for _, v := range csvRow(columns, row) {
+			sb.WriteString(v)
+			sb.WriteByte(',')
+		}
+		return int64(sb.Len())
+	case FormatMarkdown:
+		return int64(len(markdownRow(columns, row)))
+	default:
+		b, err := json.MarshalIndent(row, "  ", "  ")
+		if err != nil {
+			return 0
+		}
+		return int64(len(b) + 2)
+	}
+}
+
+// writeFile writes one part and returns its manifest entry.
+func writeFile(path string, result *db.QueryResult, format ExportFormat, compression Compression, meta Meta) (ManifestFile, *errors.XError) {
+	f, err := os.Create(path)
+	if err != nil {
+		return ManifestFile{}, errors.New(errors.CodeInternal, "failed to create export file", map[string]any{
+			"path": path,
+			"err":  err.Error(),
+		})
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(f, h)}
+
+	var out io.Writer = cw
+	var closer io.Closer
+	switch compression {
+	case CompressionGzip:
+		gz := gzip.NewWriter(cw)
+		out, closer = gz, gz
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(cw)
+		if err != nil {
+			return ManifestFile{}, errors.New(errors.CodeInternal, "failed to create zstd writer", map[string]any{"err": err.Error()})
+		}
+		out, closer = zw, zw
+	}
+
+	if xe := writeFormat(out, result, format, meta); xe != nil {
+		return ManifestFile{}, xe
+	}
+	if closer != nil {
+		if err := closer.Close(); err != nil {
+			return ManifestFile{}, errors.New(errors.CodeInternal, "failed to finish compressed export", map[string]any{
+				"path": path,
+				"err":  err.Error(),
+			})
+		}
+	}
+	if err := f.Close(); err != nil {
+		return ManifestFile{}, errors.New(errors.CodeInternal, "failed to close export file", map[string]any{
+			"path": path,
+			"err":  err.Error(),
+		})
+	}
+
+	return ManifestFile{
+		Path:   filepath.Base(path),
+		Rows:   len(result.Rows),
+		Bytes:  cw.n,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+func writeManifest(m *Manifest) *errors.XError {
+	onDisk := *m
+	onDisk.Dir = ""
+	onDisk.ManifestPath = ""
+	b, err := json.MarshalIndent(onDisk, "", "  ")
+	if err != nil {
+		return errors.New(errors.CodeInternal, "failed to encode export manifest", map[string]any{"err": err.Error()})
+	}
+	if err := os.WriteFile(m.ManifestPath, append(b, '\n'), 0644); err != nil {
+		return errors.New(errors.CodeInternal, "failed to write export manifest", map[string]any{
+			"path": m.ManifestPath,
+			"err":  err.Error(),
+		})
+	}
+	return nil
+}
+
+// partPath turns "dir/export.csv.gz" into "dir/export_0001.csv.gz".
+func partPath(path string, n int) string {
+	stem, compExt := splitExt(path, compressionExts)
+	stem, fmtExt := splitExt(stem, formatExts)
+	return fmt.Sprintf("%s_%04d%s%s", stem, n, fmtExt, compExt)
+}
+
+// manifestPath turns "dir/export.csv.gz" into "dir/export.manifest.json".
+func manifestPath(path string) string {
+	stem, _ := splitExt(path, compressionExts)
+	stem, _ = splitExt(stem, formatExts)
+	return stem + ".manifest.json"
+}
+
+// splitExt strips the extension of path when it is one of known (case-insensitive).
+// The returned extension keeps its original case.
+func splitExt[V any](path string, known map[string]V) (string, string) {
+	ext := filepath.Ext(path)
+	if _, ok := known[strings.ToLower(ext)]; !ok {
+		return path, ""
+	}
+	return path[:len(path)-len(ext)], ext
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,207 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/zx06/xsql/internal/db"
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func sampleResult(n int) *db.QueryResult {
+	res := &db.QueryResult{Columns: []string{"id", "name"}}
+	for i := 0; i < n; i++ {
+		res.Rows = append(res.Rows, map[string]any{"id": i + 1, "name": strings.Repeat("x", 10)})
+	}
+	return res
+}
+
+func TestExportFiles_GzipByExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv.gz")
+	m, xe := ExportFiles(sampleResult(3), FormatCSV, path, Options{})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if m.Compression != CompressionGzip || len(m.Files) != 1 || m.ManifestPath != "" {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+
+	f, err := os.Open(m.FilePath(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(zr)
+	if !strings.HasPrefix(string(b), "id,name\n1,xxxxxxxxxx\n") {
+		t.Fatalf("unexpected content: %q", b)
+	}
+}
+
+func TestExportFiles_ZstdFlagAppendsExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json")
+	m, xe := ExportFiles(sampleResult(2), FormatJSON, path, Options{Compression: CompressionZstd})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if got := m.FilePath(0); got != path+".zst" {
+		t.Fatalf("path = %s", got)
+	}
+	raw, _ := os.ReadFile(m.FilePath(0))
+	zr, err := zstd.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var rows []map[string]any
+	if err := json.NewDecoder(zr).Decode(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rows = %d", len(rows))
+	}
+}
+
+func TestExportFiles_SplitRowsWritesManifest(t *testing.T) {
+	dir := t.TempDir()
+	m, xe := ExportFiles(sampleResult(5), FormatCSV, filepath.Join(dir, "export.csv.gz"), Options{SplitRows: 2})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	wantNames := []string{"export_0001.csv.gz", "export_0002.csv.gz", "export_0003.csv.gz"}
+	wantRows := []int{2, 2, 1}
+	if len(m.Files) != len(wantNames) || m.TotalRows != 5 {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+	for i, f := range m.Files {
+		if f.Path != wantNames[i] || f.Rows != wantRows[i] {
+			t.Errorf("file %d = %+v", i, f)
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, f.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(raw)
+		if f.SHA256 != hex.EncodeToString(sum[:]) || f.Bytes != int64(len(raw)) {
+			t.Errorf("checksum/size mismatch for %s", f.Path)
+		}
+	}
+
+	if m.ManifestPath != filepath.Join(dir, "export.manifest.json") {
+		t.Fatalf("manifest path = %s", m.ManifestPath)
+	}
+	raw, err := os.ReadFile(m.ManifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk Manifest
+	if err := json.Unmarshal(raw, &onDisk); err != nil {
+		t.Fatal(err)
+	}
+	if onDisk.Dir != "" || len(onDisk.Files) != 3 || onDisk.Format != FormatCSV {
+		t.Fatalf("unexpected manifest on disk: %s", raw)
+	}
+}
+
+func TestExportFiles_SplitSingleChunkWritesManifest(t *testing.T) {
+	dir := t.TempDir()
+	m, xe := ExportFiles(sampleResult(3), FormatJSON, filepath.Join(dir, "small.json"), Options{SplitRows: 10})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if len(m.Files) != 1 || m.Files[0].Path != "small_0001.json" || m.Files[0].Rows != 3 {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+	if m.ManifestPath != filepath.Join(dir, "small.manifest.json") {
+		t.Fatalf("manifest path = %s", m.ManifestPath)
+	}
+	raw, err := os.ReadFile(m.ManifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk Manifest
+	if err := json.Unmarshal(raw, &onDisk); err != nil {
+		t.Fatal(err)
+	}
+	if len(onDisk.Files) != 1 || onDisk.TotalRows != 3 {
+		t.Fatalf("unexpected manifest on disk: %s", raw)
+	}
+}
+
+func TestExportFiles_SplitBytes(t *testing.T) {
+	m, xe := ExportFiles(sampleResult(10), FormatCSV, filepath.Join(t.TempDir(), "big.csv"), Options{SplitBytes: 40})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if len(m.Files) < 2 {
+		t.Fatalf("expected multiple files, got %+v", m.Files)
+	}
+	total := 0
+	for _, f := range m.Files {
+		total += f.Rows
+	}
+	if total != 10 {
+		t.Fatalf("total rows = %d", total)
+	}
+}
+
+func TestExportFiles_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		path string
+		opts Options
+	}{
+		{"none_with_gz_ext", "a.csv.gz", Options{Compression: CompressionNone}},
+		{"mismatched_ext", "a.csv.gz", Options{Compression: CompressionZstd}},
+		{"negative_split", "a.csv", Options{SplitRows: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, xe := ExportFiles(sampleResult(1), FormatCSV, filepath.Join(dir, tt.path), tt.opts)
+			if xe == nil || xe.Code != errors.CodeCfgInvalid {
+				t.Fatalf("expected CodeCfgInvalid, got %v", xe)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]ExportFormat{
+		"a.csv":        FormatCSV,
+		"a.JSON.gz":    FormatJSON,
+		"a.md.zst":     FormatMarkdown,
+		"report.html":  FormatHTML,
+		"dir.v2/x.htm": FormatHTML,
+	}
+	for path, want := range tests {
+		if got, ok := DetectFormat(path); !ok || got != want {
+			t.Errorf("DetectFormat(%q) = %q, %v", path, got, ok)
+		}
+	}
+	if _, ok := DetectFormat("a.txt.gz"); ok {
+		t.Error("expected unknown format")
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	if c, xe := ParseCompression(" GZIP "); xe != nil || c != CompressionGzip {
+		t.Fatalf("got %q, %v", c, xe)
+	}
+	if _, xe := ParseCompression("brotli"); xe == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,170 @@
+package export
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/zx06/xsql/internal/db"
+)
+
+type htmlCell struct {
+	Value string
+	Null  bool
+}
+
+type htmlReport struct {
+	Title       string
+	Query       string
+	Profile     string
+	Duration    string
+	GeneratedAt string
+	RowCount    int
+	Columns     []string
+	Rows        [][]htmlCell
+}
+
+// writeHTMLReport writes a self-contained HTML page (inline CSS/JS, no external assets)
+// with a sortable, filterable result table.
+func writeHTMLReport(w io.Writer, result *db.QueryResult, meta Meta) error {
+	report := htmlReport{
+		Title:       "xsql report",
+		Query:       meta.Query,
+		Profile:     meta.Profile,
+		GeneratedAt: meta.GeneratedAt.Format(time.RFC3339),
+		RowCount:    len(result.Rows),
+		Columns:     result.Columns,
+		Rows:        make([][]htmlCell, 0, len(result.Rows)),
+	}
+	if meta.Duration > 0 {
+		report.Duration = meta.Duration.Round(time.Millisecond).String()
+		if meta.Duration < time.Millisecond {
+			report.Duration = fmt.Sprintf("%.2fms", float64(meta.Duration.Microseconds())/1000.0)
+		}
+	}
+	if meta.Profile != "" {
+		report.Title = "xsql report - " + meta.Profile
+	}
+
+	for _, row := range result.Rows {
+		cells := make([]htmlCell, 0, len(result.Columns))
+		for _, col := range result.Columns {
+			val := row[col]
+			if val == nil {
+				cells = append(cells, htmlCell{Value: "NULL", Null: true})
+			} else {
+				cells = append(cells, htmlCell{Value: fmt.Sprintf("%v", val)})
+			}
+		}
+		report.Rows = append(report.Rows, cells)
+	}
+
+	return htmlReportTemplate.Execute(w, report)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; margin: 24px; color: #1e293b; background: #f8fafc; }
+h1 { font-size: 20px; margin: 0 0 16px; }
+dl.meta { display: grid; grid-template-columns: max-content 1fr; gap: 4px 16px; margin: 0 0 16px; font-size: 14px; }
+dl.meta dt { font-weight: 600; color: #475569; }
+dl.meta dd { margin: 0; }
+pre.query { background: #0f172a; color: #e2e8f0; padding: 12px; border-radius: 6px; overflow-x: auto; white-space: pre-wrap; font-size: 13px; }
+.toolbar { display: flex; align-items: center; gap: 12px; margin: 16px 0 8px; font-size: 14px; }
+.toolbar input { padding: 6px 10px; border: 1px solid #cbd5e1; border-radius: 4px; min-width: 280px; }
+.table-wrap { overflow-x: auto; background: #fff; border: 1px solid #e2e8f0; border-radius: 6px; }
+table { border-collapse: collapse; width: 100%; font-size: 13px; }
+th, td { padding: 6px 10px; border-bottom: 1px solid #e2e8f0; text-align: left; vertical-align: top; white-space: pre-wrap; }
+th { background: #eef2ff; color: #3730a3; cursor: pointer; user-select: none; position: sticky; top: 0; }
+th[data-dir="asc"]::after { content: " \25B2"; }
+th[data-dir="desc"]::after { content: " \25BC"; }
+tr:hover td { background: #f1f5f9; }
+td.null { color: #94a3b8; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl class="meta">
+{{- if .Profile}}
+<dt>Profile</dt><dd>{{.Profile}}</dd>
+{{- end}}
+{{- if .Duration}}
+<dt>Duration</dt><dd>{{.Duration}}</dd>
+{{- end}}
+<dt>Rows</dt><dd>{{.RowCount}}</dd>
+<dt>Generated</dt><dd>{{.GeneratedAt}}</dd>
+</dl>
+{{- if .Query}}
+<pre class="query">{{.Query}}</pre>
+{{- end}}
+<div class="toolbar">
+<input id="filter" type="search" placeholder="Filter rows..." aria-label="Filter rows">
+<span id="count">{{.RowCount}} rows</span>
+</div>
+<div class="table-wrap">
+<table id="results">
+<thead><tr>{{range $i, $c := .Columns}}<th data-col="{{$i}}">{{$c}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range .}}{{if .Null}}<td class="null">NULL</td>{{else}}<td>{{.Value}}</td>{{end}}{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+</div>
+<script>
+(function () {
+  var table = document.getElementById("results");
+  var tbody = table.tBodies[0];
+  var rows = Array.prototype.slice.call(tbody.rows);
+  var filter = document.getElementById("filter");
+  var count = document.getElementById("count");
+
+  function cellValue(row, col) {
+    var cell = row.cells[col];
+    return cell.classList.contains("null") ? null : cell.textContent;
+  }
+
+  function compare(a, b) {
+    if (a === b) return 0;
+    if (a === null) return -1;
+    if (b === null) return 1;
+    var na = Number(a), nb = Number(b);
+    if (a.trim() !== "" && b.trim() !== "" && !isNaN(na) && !isNaN(nb)) return na - nb;
+    return a.localeCompare(b);
+  }
+
+  Array.prototype.forEach.call(table.tHead.rows[0].cells, function (th) {
+    th.addEventListener("click", function () {
+      var col = Number(th.getAttribute("data-col"));
+      var dir = th.getAttribute("data-dir") === "asc" ? "desc" : "asc";
+      Array.prototype.forEach.call(table.tHead.rows[0].cells, function (h) { h.removeAttribute("data-dir"); });
+      th.setAttribute("data-dir", dir);
+      rows.sort(function (x, y) {
+        var r = compare(cellValue(x, col), cellValue(y, col));
+        return dir === "asc" ? r : -r;
+      });
+      rows.forEach(function (r) { tbody.appendChild(r); });
+    });
+  });
+
+  filter.addEventListener("input", function () {
+    var q = filter.value.toLowerCase();
+    var visible = 0;
+    rows.forEach(function (r) {
+      var match = q === "" || r.textContent.toLowerCase().indexOf(q) !== -1;
+      r.style.display = match ? "" : "none";
+      if (match) visible++;
+    });
+    count.textContent = visible === rows.length ? rows.length + " rows" : visible + " of " + rows.length + " rows";
+  });
+})();
+</script>
+</body>
+</html>
+`))
@@ -3,12 +3,14 @@ package mcp
 import (
 	"context"
 	"crypto/subtle"
+	"encoding/json"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/zx06/xsql/internal/app"
 	"github.com/zx06/xsql/internal/errors"
 )
 
@@ -44,6 +46,34 @@ func NewStreamableHTTPHandlerWithTimeout(server *mcp.Server, authToken string, t
 	return withTimeout(authHandler, timeout), nil
 }
 
+// HealthPath is where the streamable HTTP transport serves NewHealthHandler.
+const HealthPath = "/health"
+
+// NewHealthHandler reports server health and the state of the shared SSH
+// tunnels (tunnels may be nil). It requires the same bearer token as MCP.
+func NewHealthHandler(tunnels *app.SSHTunnels, authToken string) (http.Handler, error) {
+	if authToken == "" {
+		return nil, errors.New(errors.CodeCfgInvalid, "mcp streamable http auth token is required", nil)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		status := []app.SSHTunnelStatus{}
+		if tunnels != nil {
+			status = tunnels.Status()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"status":      "ok",
+			"ssh_tunnels": status,
+		})
+	})
+	return requireAuth(handler, authToken), nil
+}
+
 func withTimeout(next http.Handler, timeout time.Duration) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		ctx, cancel := context.WithTimeout(req.Context(), timeout)
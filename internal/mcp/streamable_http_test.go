@@ -1,11 +1,13 @@
 package mcp
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/zx06/xsql/internal/app"
 	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/stats"
@@ -15,7 +17,7 @@ func TestStreamableHTTPAuthRequired(t *testing.T) {
 	server, err := CreateServer("test", &config.File{
 		Profiles:   map[string]config.Profile{},
 		SSHProxies: map[string]config.SSHProxy{},
-	}, stats.StatsConfig{})
+	}, stats.StatsConfig{}, nil)
 	if err != nil {
 		t.Fatalf("CreateServer error: %v", err)
 	}
@@ -78,7 +80,7 @@ func TestNewStreamableHTTPHandler_Validation(t *testing.T) {
 	server, err := CreateServer("test", &config.File{
 		Profiles:   map[string]config.Profile{},
 		SSHProxies: map[string]config.SSHProxy{},
-	}, stats.StatsConfig{})
+	}, stats.StatsConfig{}, nil)
 	if err != nil {
 		t.Fatalf("CreateServer error: %v", err)
 	}
@@ -94,3 +96,39 @@ func TestNewStreamableHTTPHandler_Validation(t *testing.T) {
 		t.Fatalf("expected CodeCfgInvalid, got %s", xe.Code)
 	}
 }
+
+func TestHealthHandler(t *testing.T) {
+	if _, err := NewHealthHandler(nil, ""); err == nil {
+		t.Fatal("expected error for empty token")
+	}
+	tunnels := app.NewSSHTunnels(nil)
+	defer tunnels.Close()
+	handler, err := NewHealthHandler(tunnels, "secret-token")
+	if err != nil {
+		t.Fatalf("NewHealthHandler error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, HealthPath, nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body struct {
+		Status     string                `json:"status"`
+		SSHTunnels []app.SSHTunnelStatus `json:"ssh_tunnels"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Status != "ok" || body.SSHTunnels == nil {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
@@ -8,20 +8,38 @@ import (
 	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/zx06/xsql/internal/app"
 	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/db"
 	_ "github.com/zx06/xsql/internal/db/mysql"
 	_ "github.com/zx06/xsql/internal/db/pg"
 	"github.com/zx06/xsql/internal/errors"
-	"github.com/zx06/xsql/internal/secret"
-	"github.com/zx06/xsql/internal/ssh"
 	"github.com/zx06/xsql/internal/stats"
 )
 
 // QueryInput represents the input for the query tool
 type QueryInput struct {
-	SQL     string `json:"sql" jsonschema:"SQL query to execute"`
-	Profile string `json:"profile" jsonschema:"Profile name to use"`
+	SQL            string            `json:"sql" jsonschema:"SQL query to execute"`
+	Profile        string            `json:"profile" jsonschema:"Profile name to use"`
+	Columns        []string          `json:"columns,omitempty" jsonschema:"Only return these columns, in this order"`
+	ExcludeColumns []string          `json:"exclude_columns,omitempty" jsonschema:"Drop these columns from the result"`
+	Rename         map[string]string `json:"rename,omitempty" jsonschema:"Rename result columns (old name to new name)"`
+	Select         []string          `json:"select,omitempty" jsonschema:"Extract nested JSON values as new columns: [name=]column.path[0].key"`
+}
+
+// projection returns the post-query projection requested by the input.
+func (in QueryInput) projection() db.Projection {
+	return db.Projection{
+		Columns: in.Columns,
+		Exclude: in.ExcludeColumns,
+		Rename:  in.Rename,
+		Select:  in.Select,
+	}
+}
+
+// ProfileListInput represents the input for the profile_list tool
+type ProfileListInput struct {
+	Tags []string `json:"tags,omitempty" jsonschema:"Only list profiles carrying all of these tags"`
 }
 
 // ProfileShowInput represents the input for the profile_show tool
@@ -31,8 +49,9 @@ type ProfileShowInput struct {
 
 // ToolHandler manages MCP tools
 type ToolHandler struct {
-	config *config.File
-	stats  stats.StatsConfig
+	config  *config.File
+	stats   stats.StatsConfig
+	tunnels *app.SSHTunnels // shared SSH connections; nil connects per query
 }
 
 // NewToolHandler creates a new tool handler
@@ -61,9 +80,13 @@ func (h *ToolHandler) getProfileNames() []string {
 // RegisterTools registers all tools with the MCP server
 func (h *ToolHandler) RegisterTools(server *mcp.Server) {
 	profileNames := h.getProfileNames()
-	profileEnums := make([]any, len(profileNames))
-	for i, name := range profileNames {
-		profileEnums[i] = name
+	profileEnums := make([]any, 0, len(profileNames))
+	for _, name := range profileNames {
+		profileEnums = append(profileEnums, name)
+	}
+	// Tag selectors are accepted wherever a profile name is.
+	for _, tag := range config.AllTags(h.config.Profiles) {
+		profileEnums = append(profileEnums, config.TagSelectorPrefix+tag)
 	}
 
 	// Query tool with profile enum
@@ -77,9 +100,29 @@ func (h *ToolHandler) RegisterTools(server *mcp.Server) {
 			},
 			"profile": {
 				Type:        "string",
-				Description: "Profile name to use",
+				Description: "Profile name to use, or tag:<tag> for the single profile with that tag",
 				Enum:        profileEnums,
 			},
+			"columns": {
+				Type:        "array",
+				Description: "Only return these columns, in this order",
+				Items:       &jsonschema.Schema{Type: "string"},
+			},
+			"exclude_columns": {
+				Type:        "array",
+				Description: "Drop these columns from the result",
+				Items:       &jsonschema.Schema{Type: "string"},
+			},
+			"rename": {
+				Type:                 "object",
+				Description:          "Rename result columns (old name to new name)",
+				AdditionalProperties: &jsonschema.Schema{Type: "string"},
+			},
+			"select": {
+				Type:        "array",
+				Description: "Extract nested JSON values as new columns: [name=]column.path[0].key",
+				Items:       &jsonschema.Schema{Type: "string"},
+			},
 		},
 	}
 	server.AddTool(&mcp.Tool{
@@ -89,9 +132,9 @@ func (h *ToolHandler) RegisterTools(server *mcp.Server) {
 	}, h.queryHandler)
 
 	// Profile list tool
-	mcp.AddTool[struct{}, any](server, &mcp.Tool{
+	mcp.AddTool[ProfileListInput, any](server, &mcp.Tool{
 		Name:        "profile_list",
-		Description: "List all configured profiles",
+		Description: "List configured profiles, optionally filtered by tags",
 	}, h.ProfileList)
 
 	// Profile show tool with profile enum
@@ -101,7 +144,7 @@ func (h *ToolHandler) RegisterTools(server *mcp.Server) {
 		Properties: map[string]*jsonschema.Schema{
 			"name": {
 				Type:        "string",
-				Description: "Profile name",
+				Description: "Profile name, or tag:<tag> for the single profile with that tag",
 				Enum:        profileEnums,
 			},
 		},
@@ -164,6 +207,17 @@ func (h *ToolHandler) Query(ctx context.Context, req *mcp.CallToolRequest, input
 		}, nil, nil
 	}
 
+	name, xe := config.SelectProfile(h.config.Profiles, input.Profile)
+	if xe != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: h.formatError(xe)},
+			},
+		}, nil, nil
+	}
+	input.Profile = name
+
 	// Get profile
 	profile := h.getProfile(input.Profile)
 	if profile == nil {
@@ -185,91 +239,27 @@ func (h *ToolHandler) Query(ctx context.Context, req *mcp.CallToolRequest, input
 		}, nil, nil
 	}
 
-	if profile.DB == "" {
+	projection := input.projection()
+	if xe := projection.Validate(); xe != nil {
 		return &mcp.CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
-				&mcp.TextContent{Text: h.formatError(errors.New(errors.CodeCfgInvalid, "db type is required (mysql|pg)", nil))},
+				&mcp.TextContent{Text: h.formatError(xe)},
 			},
 		}, nil, nil
 	}
 
-	// Parse password
-	password := profile.Password
-	if password != "" {
-		pw, xe := secret.Resolve(password, secret.Options{AllowPlaintext: profile.AllowPlaintext})
-		if xe != nil {
-			return &mcp.CallToolResult{
-				IsError: true,
-				Content: []mcp.Content{
-					&mcp.TextContent{Text: h.formatError(xe)},
-				},
-			}, nil, nil
-		}
-		password = pw
-	}
-
-	// SSH proxy
-	var sshClient *ssh.Client
-	if profile.SSHConfig != nil {
-		passphrase := profile.SSHConfig.Passphrase
-		if passphrase != "" {
-			pp, xe := secret.Resolve(passphrase, secret.Options{AllowPlaintext: profile.AllowPlaintext})
-			if xe != nil {
-				return &mcp.CallToolResult{
-					IsError: true,
-					Content: []mcp.Content{
-						&mcp.TextContent{Text: h.formatError(xe)},
-					},
-				}, nil, nil
-			}
-			passphrase = pp
-		}
-		sshOpts := ssh.Options{
-			Host:           profile.SSHConfig.Host,
-			Port:           profile.SSHConfig.Port,
-			User:           profile.SSHConfig.User,
-			IdentityFile:   profile.SSHConfig.IdentityFile,
-			Passphrase:     passphrase,
-			KnownHostsFile: profile.SSHConfig.KnownHostsFile,
-		}
-		sc, xe := ssh.Connect(ctx, sshOpts)
-		if xe != nil {
-			return &mcp.CallToolResult{
-				IsError: true,
-				Content: []mcp.Content{
-					&mcp.TextContent{Text: h.formatError(xe)},
-				},
-			}, nil, nil
-		}
-		defer sc.Close()
-		sshClient = sc
-	}
-
-	// Get driver
-	drv, ok := db.Get(profile.DB)
-	if !ok {
+	if profile.DB == "" {
 		return &mcp.CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
-				&mcp.TextContent{Text: h.formatError(errors.New(errors.CodeDBDriverUnsupported, "unsupported db driver", map[string]any{"db": profile.DB}))},
+				&mcp.TextContent{Text: h.formatError(errors.New(errors.CodeCfgInvalid, "db type is required (mysql|pg)", nil))},
 			},
 		}, nil, nil
 	}
 
-	connOpts := db.ConnOptions{
-		DSN:      profile.DSN,
-		Host:     profile.Host,
-		Port:     profile.Port,
-		User:     profile.User,
-		Password: password,
-		Database: profile.Database,
-	}
-	if sshClient != nil {
-		connOpts.Dialer = sshClient
-	}
-
-	conn, xe := drv.Open(ctx, connOpts)
+	// Connect the same way as the CLI (secrets, token auth, SSH, TLS, params).
+	conn, xe := app.ResolveConnection(ctx, app.ConnectionOptions{Profile: *profile, SSHTunnels: h.tunnels})
 	if xe != nil {
 		return &mcp.CallToolResult{
 			IsError: true,
@@ -278,11 +268,11 @@ func (h *ToolHandler) Query(ctx context.Context, req *mcp.CallToolRequest, input
 			},
 		}, nil, nil
 	}
-	defer conn.Close()
+	defer func() { _ = conn.Close() }()
 
 	// Query options - use read-only mode by default
 	start := time.Now()
-	result, xe := db.Query(ctx, conn, input.SQL, db.QueryOptions{
+	result, xe := db.Query(ctx, conn.DB, input.SQL, db.QueryOptions{
 		UnsafeAllowWrite: profile.UnsafeAllowWrite,
 		DBType:           profile.DB,
 	})
@@ -299,6 +289,16 @@ func (h *ToolHandler) Query(ctx context.Context, req *mcp.CallToolRequest, input
 		}, nil, nil
 	}
 
+	result, xe = result.Project(projection)
+	if xe != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: h.formatError(xe)},
+			},
+		}, nil, nil
+	}
+
 	output := map[string]any{
 		"ok":             true,
 		"schema_version": 1,
@@ -323,9 +323,12 @@ func (h *ToolHandler) Query(ctx context.Context, req *mcp.CallToolRequest, input
 }
 
 // ProfileList lists all profiles
-func (h *ToolHandler) ProfileList(ctx context.Context, req *mcp.CallToolRequest, input struct{}) (*mcp.CallToolResult, any, error) {
+func (h *ToolHandler) ProfileList(ctx context.Context, req *mcp.CallToolRequest, input ProfileListInput) (*mcp.CallToolResult, any, error) {
 	profiles := make([]config.ProfileInfo, 0, len(h.config.Profiles))
 	for name, p := range h.config.Profiles {
+		if !p.HasTags(input.Tags) {
+			continue
+		}
 		profiles = append(profiles, config.ProfileToInfo(name, p))
 	}
 
@@ -355,6 +358,16 @@ func (h *ToolHandler) ProfileList(ctx context.Context, req *mcp.CallToolRequest,
 
 // ProfileShow shows profile details
 func (h *ToolHandler) ProfileShow(ctx context.Context, req *mcp.CallToolRequest, input ProfileShowInput) (*mcp.CallToolResult, any, error) {
+	name, xe := config.SelectProfile(h.config.Profiles, input.Name)
+	if xe != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: h.formatError(xe)},
+			},
+		}, nil, nil
+	}
+	input.Name = name
 	profile, ok := h.config.Profiles[input.Name]
 	if !ok {
 		return &mcp.CallToolResult{
@@ -377,9 +390,24 @@ func (h *ToolHandler) ProfileShow(ctx context.Context, req *mcp.CallToolRequest,
 		"unsafe_allow_write": profile.UnsafeAllowWrite,
 		"allow_plaintext":    profile.AllowPlaintext,
 	}
+	if len(profile.Tags) > 0 {
+		result["tags"] = profile.Tags
+	}
 	if profile.DSN != "" {
 		result["dsn"] = "***"
 	}
+	if profile.URL != "" {
+		result["url"] = config.RedactURL(profile.URL)
+	}
+	if params := profile.ConnParams(); len(params) > 0 {
+		result["params"] = params
+	}
+	if profile.TLS != (config.TLSConfig{}) {
+		result["tls"] = profile.TLS
+	}
+	if profile.Auth != (config.AuthConfig{}) {
+		result["auth"] = profile.Auth
+	}
 	if profile.Password != "" {
 		result["password"] = "***"
 	}
@@ -436,8 +464,8 @@ func (h *ToolHandler) getProfile(name string) *config.Profile {
 
 	// Resolve SSH proxy reference
 	if profile.SSHProxy != "" {
-		if proxy, ok := h.config.SSHProxies[profile.SSHProxy]; ok {
-			profile.SSHConfig = &proxy
+		if proxy, xe := config.ResolveSSHProxy(h.config.SSHProxies, profile.SSHProxy); xe == nil {
+			profile.SSHConfig = proxy
 		}
 	}
 
@@ -468,14 +496,16 @@ func (h *ToolHandler) formatError(err error) string {
 	return string(jsonData)
 }
 
-// CreateServer creates a new MCP server
-func CreateServer(version string, cfg *config.File, st stats.StatsConfig) (*mcp.Server, error) {
+// CreateServer creates the MCP server. Queries through an ssh proxy share the
+// connections of tunnels when it is set.
+func CreateServer(version string, cfg *config.File, st stats.StatsConfig, tunnels *app.SSHTunnels) (*mcp.Server, error) {
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "xsql",
 		Version: version,
 	}, nil)
 
 	handler := NewToolHandler(cfg, st)
+	handler.tunnels = tunnels
 	handler.RegisterTools(server)
 
 	return server, nil
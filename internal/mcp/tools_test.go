@@ -24,7 +24,7 @@ func TestCreateServer(t *testing.T) {
 		},
 	}
 
-	server, err := CreateServer("test", cfg, stats.StatsConfig{})
+	server, err := CreateServer("test", cfg, stats.StatsConfig{}, nil)
 	if err != nil {
 		t.Fatalf("CreateServer failed: %v", err)
 	}
@@ -207,7 +207,7 @@ func TestProfileList(t *testing.T) {
 	// Create a mock request
 	req := &mcp.CallToolRequest{}
 
-	result, _, err := handler.ProfileList(context.TODO(), req, struct{}{})
+	result, _, err := handler.ProfileList(context.TODO(), req, ProfileListInput{})
 	if err != nil {
 		t.Fatalf("ProfileList failed: %v", err)
 	}
@@ -225,6 +225,39 @@ func TestProfileList(t *testing.T) {
 	}
 }
 
+func TestProfileList_TagFilterAndSelector(t *testing.T) {
+	cfg := &config.File{
+		Profiles: map[string]config.Profile{
+			"orders-eu": {DB: "pg", Tags: []string{"prod", "eu"}},
+			"orders-us": {DB: "pg", Tags: []string{"prod", "us"}},
+			"dev":       {DB: "mysql"},
+		},
+	}
+	handler := NewToolHandler(cfg, stats.StatsConfig{})
+
+	result, _, err := handler.ProfileList(context.Background(), &mcp.CallToolRequest{}, ProfileListInput{Tags: []string{"eu"}})
+	if err != nil || result.IsError {
+		t.Fatalf("ProfileList failed: %v %v", err, result)
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !strings.Contains(text, "orders-eu") || strings.Contains(text, "orders-us") || strings.Contains(text, `"dev"`) {
+		t.Errorf("unexpected filter result: %s", text)
+	}
+
+	result, _, err = handler.ProfileShow(context.Background(), &mcp.CallToolRequest{}, ProfileShowInput{Name: "tag:us"})
+	if err != nil || result.IsError {
+		t.Fatalf("ProfileShow by tag failed: %v %v", err, result)
+	}
+	if text := result.Content[0].(*mcp.TextContent).Text; !strings.Contains(text, `"orders-us"`) {
+		t.Errorf("unexpected profile: %s", text)
+	}
+
+	result, _, _ = handler.Query(context.Background(), &mcp.CallToolRequest{}, QueryInput{SQL: "select 1", Profile: "tag:prod"})
+	if !result.IsError || !strings.Contains(result.Content[0].(*mcp.TextContent).Text, "matches multiple profiles") {
+		t.Errorf("expected ambiguous selector error, got %v", result.Content)
+	}
+}
+
 func TestQuery_SSHProxyMissing(t *testing.T) {
 	cfg := &config.File{
 		Profiles: map[string]config.Profile{
@@ -294,7 +327,7 @@ func TestProfileList_Output(t *testing.T) {
 	}
 	handler := NewToolHandler(cfg, stats.StatsConfig{})
 
-	result, _, err := handler.ProfileList(context.Background(), &mcp.CallToolRequest{}, struct{}{})
+	result, _, err := handler.ProfileList(context.Background(), &mcp.CallToolRequest{}, ProfileListInput{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -473,6 +506,35 @@ func TestQuery_InvalidPasswordFormat(t *testing.T) {
 	}
 }
 
+func TestQuery_UsesTokenAuth(t *testing.T) {
+	cfg := &config.File{
+		Profiles: map[string]config.Profile{
+			"iam": {
+				DB:   "pg",
+				Host: "127.0.0.1",
+				User: "iam_user",
+				Auth: config.AuthConfig{Provider: "command"},
+			},
+		},
+	}
+
+	handler := NewToolHandler(cfg, stats.StatsConfig{})
+
+	result, _, err := handler.Query(context.TODO(), &mcp.CallToolRequest{}, QueryInput{
+		SQL:     "SELECT 1",
+		Profile: "iam",
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	// The token provider runs before any connection attempt.
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !result.IsError || !strings.Contains(text, "auth.command") {
+		t.Errorf("expected the auth provider error, got %s", text)
+	}
+}
+
 func TestQuery_WithSSHConfig(t *testing.T) {
 	cfg := &config.File{
 		Profiles: map[string]config.Profile{
@@ -652,7 +714,7 @@ func TestProfileList_WithReadWriteProfile(t *testing.T) {
 
 	handler := NewToolHandler(cfg, stats.StatsConfig{})
 
-	result, _, err := handler.ProfileList(context.TODO(), &mcp.CallToolRequest{}, struct{}{})
+	result, _, err := handler.ProfileList(context.TODO(), &mcp.CallToolRequest{}, ProfileListInput{})
 	if err != nil {
 		t.Fatalf("ProfileList failed: %v", err)
 	}
@@ -892,7 +954,7 @@ func TestProfileList_EmptyProfiles(t *testing.T) {
 	}
 	handler := NewToolHandler(cfg, stats.StatsConfig{})
 
-	result, _, err := handler.ProfileList(context.Background(), &mcp.CallToolRequest{}, struct{}{})
+	result, _, err := handler.ProfileList(context.Background(), &mcp.CallToolRequest{}, ProfileListInput{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -907,3 +969,29 @@ func TestProfileList_EmptyProfiles(t *testing.T) {
 		t.Fatalf("expected empty profiles list, got: %s", text)
 	}
 }
+
+func TestQuery_InvalidSelectExpression(t *testing.T) {
+	cfg := &config.File{
+		Profiles: map[string]config.Profile{
+			"dev": {DB: "mysql"},
+		},
+	}
+
+	handler := NewToolHandler(cfg, stats.StatsConfig{})
+
+	result, _, err := handler.Query(context.TODO(), &mcp.CallToolRequest{}, QueryInput{
+		SQL:     "SELECT 1",
+		Profile: "dev",
+		Select:  []string{"payload.items[x]"},
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected error for invalid select expression")
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !strings.Contains(text, "invalid select expression") {
+		t.Errorf("unexpected error: %s", text)
+	}
+}
@@ -8,11 +8,13 @@ const (
 	FormatYAML  Format = "yaml"
 	FormatTable Format = "table"
 	FormatCSV   Format = "csv"
+	// FormatTemplate renders data through a user-supplied Go text/template.
+	FormatTemplate Format = "template"
 )
 
 func IsValid(f Format) bool {
 	switch f {
-	case FormatAuto, FormatJSON, FormatYAML, FormatTable, FormatCSV:
+	case FormatAuto, FormatJSON, FormatYAML, FormatTable, FormatCSV, FormatTemplate:
 		return true
 	default:
 		return false
@@ -0,0 +1,106 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// TableLayout controls how query results are rendered in table format.
+type TableLayout string
+
+const (
+	// TableLayoutAuto renders horizontally unless the table would exceed the terminal width.
+	TableLayoutAuto TableLayout = "auto"
+	// TableLayoutHorizontal always renders one row per line.
+	TableLayoutHorizontal TableLayout = "horizontal"
+	// TableLayoutVertical renders one "column | value" line per field (psql \x / MySQL \G).
+	TableLayoutVertical TableLayout = "vertical"
+)
+
+// IsValidTableLayout reports whether l is a supported table layout.
+func IsValidTableLayout(l TableLayout) bool {
+	switch l {
+	case TableLayoutAuto, TableLayoutHorizontal, TableLayoutVertical:
+		return true
+	default:
+		return false
+	}
+}
+
+// tableColumnPadding matches the padding used by the horizontal tabwriter.
+const tableColumnPadding = 2
+
+// useVerticalLayout decides whether a query result should be rendered vertically.
+func useVerticalLayout(layout TableLayout, termWidth int, cols []string, rows []map[string]any) bool {
+	switch layout {
+	case TableLayoutVertical:
+		return true
+	case TableLayoutHorizontal:
+		return false
+	default:
+		if termWidth <= 0 || len(cols) == 0 {
+			return false
+		}
+		return horizontalTableWidth(cols, rows) > termWidth
+	}
+}
+
+// horizontalTableWidth returns the display width of the widest line of the horizontal layout.
+func horizontalTableWidth(cols []string, rows []map[string]any) int {
+	total := 0
+	for i, c := range cols {
+		w := runewidth.StringWidth(c)
+		for _, row := range rows {
+			if cw := cellDisplayWidth(formatCellValue(row[c], "<null>")); cw > w {
+				w = cw
+			}
+		}
+		total += w
+		if i < len(cols)-1 {
+			total += tableColumnPadding
+		}
+	}
+	return total
+}
+
+// cellDisplayWidth returns the width of the longest line in a cell value.
+func cellDisplayWidth(s string) int {
+	w := 0
+	for _, line := range strings.Split(s, "\n") {
+		if lw := runewidth.StringWidth(line); lw > w {
+			w = lw
+		}
+	}
+	return w
+}
+
+// writeQueryResultVertical writes the query result in expanded form:
+//
+//	-[ RECORD 1 ]-
+//	id   | 1
+//	name | Alice
+func writeQueryResultVertical(out io.Writer, cols []string, rows []map[string]any) error {
+	keyWidth := 0
+	for _, c := range cols {
+		if w := runewidth.StringWidth(c); w > keyWidth {
+			keyWidth = w
+		}
+	}
+
+	for i, row := range rows {
+		_, _ = fmt.Fprintf(out, "-[ RECORD %d ]%s\n", i+1, strings.Repeat("-", keyWidth+2))
+		for _, c := range cols {
+			pad := strings.Repeat(" ", keyWidth-runewidth.StringWidth(c))
+			val := formatCellValue(row[c], "<null>")
+			// Keep continuation lines aligned with the value column.
+			val = strings.ReplaceAll(val, "\n", "\n"+strings.Repeat(" ", keyWidth)+" | ")
+			_, _ = fmt.Fprintf(out, "%s%s | %s\n", c, pad, val)
+		}
+	}
+
+	_, err := fmt.Fprintf(out, "\n(%d rows)\n", len(rows))
+	return err
+}
@@ -0,0 +1,128 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// templateFuncs are the helper functions available to output templates.
+var templateFuncs = template.FuncMap{
+	"json":      templateJSON,
+	"csvEscape": templateCSVEscape,
+	"truncate":  templateTruncate,
+	"default":   templateDefault,
+}
+
+// ParseTemplate parses an output template with the xsql helper functions.
+func ParseTemplate(text string) (*template.Template, *errors.XError) {
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(errors.CodeCfgInvalid, "invalid output template", map[string]any{"reason": err.Error()}, err)
+	}
+	return tmpl, nil
+}
+
+// writeTemplate renders the envelope data through the user template.
+// The template's dot is the data as it appears in JSON output (e.g. .columns, .rows).
+func writeTemplate(out io.Writer, env Envelope, text string) error {
+	if !env.OK {
+		if env.Error != nil {
+			_, _ = fmt.Fprintf(out, "Error [%s]: %s\n", env.Error.Code, env.Error.Message)
+		}
+		return nil
+	}
+	if text == "" {
+		return errors.New(errors.CodeCfgInvalid, "template format requires --template or --template-file", nil)
+	}
+
+	tmpl, xe := ParseTemplate(text)
+	if xe != nil {
+		return xe
+	}
+	data, err := normalizeTemplateData(env.Data)
+	if err != nil {
+		return errors.Wrap(errors.CodeInternal, "failed to prepare template data", nil, err)
+	}
+	if err := tmpl.Execute(out, data); err != nil {
+		return errors.Wrap(errors.CodeCfgInvalid, "failed to render output template", map[string]any{"reason": err.Error()}, err)
+	}
+	return nil
+}
+
+// normalizeTemplateData converts data to its JSON shape so templates can use the
+// same field names as JSON output. Numbers are kept as json.Number to avoid
+// float64 precision loss on large integers.
+func normalizeTemplateData(data any) (any, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// templateJSON encodes v as compact JSON.
+func templateJSON(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// templateCSVEscape formats v as a single CSV field, quoting it when needed.
+func templateCSVEscape(v any) string {
+	s := templateString(v)
+	if !strings.ContainsAny(s, ",\"\r\n") && strings.TrimSpace(s) == s {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// templateTruncate shortens v to at most n display columns, appending "..." when cut.
+// The argument order allows pipelines: {{.name | truncate 10}}.
+func templateTruncate(n int, v any) string {
+	s := templateString(v)
+	if n <= 0 || runewidth.StringWidth(s) <= n {
+		return s
+	}
+	if n <= 3 {
+		return runewidth.Truncate(s, n, "")
+	}
+	return runewidth.Truncate(s, n, "...")
+}
+
+// templateDefault returns def when v is nil or an empty string.
+// The argument order allows pipelines: {{.email | default "-"}}.
+func templateDefault(def, v any) any {
+	if v == nil {
+		return def
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return def
+	}
+	return v
+}
+
+// templateString converts a template value to its display string.
+func templateString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return formatCellValue(v, "")
+}
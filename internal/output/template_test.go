@@ -0,0 +1,108 @@
+package output
+
+import (
+	"bytes"
+	stderrors "errors"
+	"testing"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func TestWriteOK_TemplateFormat_QueryResult(t *testing.T) {
+	var out bytes.Buffer
+	w := New(&out, &bytes.Buffer{})
+	w.Template = `{{range .rows}}{{.id}},{{csvEscape .name}},{{.email | default "-"}}{{"\n"}}{{end}}`
+	data := map[string]any{
+		"columns": []string{"id", "name", "email"},
+		"rows": []map[string]any{
+			{"id": int64(9007199254740993), "name": "Smith, John", "email": nil},
+			{"id": 2, "name": "Bob", "email": "bob@example.com"},
+		},
+	}
+	if err := w.WriteOK(FormatTemplate, data); err != nil {
+		t.Fatal(err)
+	}
+	want := "9007199254740993,\"Smith, John\",-\n2,Bob,bob@example.com\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestWriteOK_TemplateFormat_ProfileList(t *testing.T) {
+	var out bytes.Buffer
+	w := New(&out, &bytes.Buffer{})
+	w.Template = `{{range .profiles}}{{.name}}={{.db}};{{end}}`
+	data := struct {
+		Profiles []struct {
+			Name string `json:"name"`
+			DB   string `json:"db"`
+		} `json:"profiles"`
+	}{}
+	data.Profiles = append(data.Profiles, struct {
+		Name string `json:"name"`
+		DB   string `json:"db"`
+	}{Name: "dev", DB: "mysql"})
+	if err := w.WriteOK(FormatTemplate, data); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "dev=mysql;" {
+		t.Fatalf("got %q", out.String())
+	}
+}
+
+func TestWriteOK_TemplateFormat_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{"missing", ""},
+		{"parse", "{{.rows"},
+		{"execute", "{{truncate .rows 1}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := New(&bytes.Buffer{}, &bytes.Buffer{})
+			w.Template = tt.template
+			err := w.WriteOK(FormatTemplate, map[string]any{"rows": []any{}})
+			var xe *errors.XError
+			if !stderrors.As(err, &xe) || xe.Code != errors.CodeCfgInvalid {
+				t.Fatalf("expected %s, got %v", errors.CodeCfgInvalid, err)
+			}
+		})
+	}
+}
+
+func TestWriteError_TemplateFormat(t *testing.T) {
+	var out bytes.Buffer
+	w := New(&out, &bytes.Buffer{})
+	if err := w.WriteError(FormatTemplate, errors.New(errors.CodeDBExecFailed, "boom", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Error [XSQL_DB_EXEC_FAILED]: boom\n" {
+		t.Fatalf("got %q", out.String())
+	}
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	if got, _ := templateJSON(map[string]any{"a": "<b>"}); got != `{"a":"<b>"}` {
+		t.Errorf("json = %q", got)
+	}
+	if got := templateCSVEscape(`say "hi"`); got != `"say ""hi"""` {
+		t.Errorf("csvEscape = %q", got)
+	}
+	if got := templateCSVEscape(nil); got != "" {
+		t.Errorf("csvEscape(nil) = %q", got)
+	}
+	if got := templateTruncate(5, "abcdefgh"); got != "ab..." {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := templateTruncate(10, "short"); got != "short" {
+		t.Errorf("truncate short = %q", got)
+	}
+	if got := templateDefault("-", ""); got != "-" {
+		t.Errorf("default empty = %v", got)
+	}
+	if got := templateDefault("-", 0); got != 0 {
+		t.Errorf("default zero = %v", got)
+	}
+}
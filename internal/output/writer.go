@@ -18,6 +18,13 @@ import (
 type Writer struct {
 	Out io.Writer
 	Err io.Writer
+
+	// TableLayout selects how query results are rendered in table format (empty means auto).
+	TableLayout TableLayout
+	// TermWidth is the terminal width used by the auto layout; zero disables auto switching.
+	TermWidth int
+	// Template is the Go text/template source used by the template format.
+	Template string
 }
 
 func New(out, err io.Writer) Writer {
@@ -53,9 +60,11 @@ func (w Writer) write(format Format, env Envelope) error {
 		}
 		return nil
 	case FormatTable:
-		return writeTable(w.Out, env)
+		return writeTable(w.Out, env, tableOptions{layout: w.TableLayout, termWidth: w.TermWidth})
 	case FormatCSV:
 		return writeCSV(w.Out, env)
+	case FormatTemplate:
+		return writeTemplate(w.Out, env, w.Template)
 	default:
 		return errors.New(errors.CodeCfgInvalid, "invalid output format", map[string]any{"format": string(format)})
 	}
@@ -84,7 +93,13 @@ type ProfileListFormatter interface {
 	ToProfileListData() (configPath string, profiles []ProfileListItem, ok bool)
 }
 
-func writeTable(out io.Writer, env Envelope) error {
+// tableOptions carries the Writer's table layout settings into the table renderers.
+type tableOptions struct {
+	layout    TableLayout
+	termWidth int
+}
+
+func writeTable(out io.Writer, env Envelope, opts tableOptions) error {
 	if !env.OK {
 		// Error output: display error message concisely
 		if env.Error != nil {
@@ -96,7 +111,7 @@ func writeTable(out io.Writer, env Envelope) error {
 	// First check if the data implements the TableFormatter interface (no JSON encode/decode)
 	if formatter, ok := env.Data.(TableFormatter); ok {
 		if cols, rows, ok := formatter.ToTableData(); ok {
-			return writeQueryResultTable(out, cols, rows)
+			return writeQueryResult(out, cols, rows, opts)
 		}
 	}
 
@@ -119,7 +134,7 @@ func writeTable(out io.Writer, env Envelope) error {
 		// Try to extract query result
 		if cols, ok := extractStringSlice(m["columns"]); ok {
 			if rows, ok := extractMapSlice(m["rows"]); ok {
-				return writeQueryResultTable(out, cols, rows)
+				return writeQueryResult(out, cols, rows, opts)
 			}
 		}
 
@@ -141,7 +156,7 @@ func writeTable(out io.Writer, env Envelope) error {
 
 	// Last resort: try reflection-based extraction (no JSON encode/decode)
 	if result, ok := tryAsQueryResultReflect(env.Data); ok {
-		return writeQueryResultTable(out, result.columns, result.rows)
+		return writeQueryResult(out, result.columns, result.rows, opts)
 	}
 
 	// Default: output data as key-value pairs
@@ -170,10 +185,27 @@ func writeProfileListTable(out io.Writer, cfgPath string, profiles []ProfileList
 	if cfgPath != "" {
 		_, _ = fmt.Fprintf(tw, "Config: %s\n\n", cfgPath)
 	}
+	// The TAGS column is only shown when some profile has tags.
+	withTags := false
+	for _, p := range profiles {
+		if len(p.Tags) > 0 {
+			withTags = true
+			break
+		}
+	}
 	// Output profiles table
-	_, _ = fmt.Fprintln(tw, "NAME\tDESCRIPTION\tDB\tMODE")
-	_, _ = fmt.Fprintln(tw, "----\t-----------\t--\t----")
+	if withTags {
+		_, _ = fmt.Fprintln(tw, "NAME\tDESCRIPTION\tDB\tMODE\tTAGS")
+		_, _ = fmt.Fprintln(tw, "----\t-----------\t--\t----\t----")
+	} else {
+		_, _ = fmt.Fprintln(tw, "NAME\tDESCRIPTION\tDB\tMODE")
+		_, _ = fmt.Fprintln(tw, "----\t-----------\t--\t----")
+	}
 	for _, p := range profiles {
+		if withTags {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", p.Name, p.Description, p.DB, p.Mode, strings.Join(p.Tags, ","))
+			continue
+		}
 		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Name, p.Description, p.DB, p.Mode)
 	}
 	// Use correct singular/plural form
@@ -232,10 +264,11 @@ func extractMapSlice(v any) ([]map[string]any, bool) {
 }
 
 type ProfileListItem struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	DB          string `json:"db"`
-	Mode        string `json:"mode"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	DB          string   `json:"db"`
+	Mode        string   `json:"mode"`
+	Tags        []string `json:"tags,omitempty"`
 }
 
 func tryAsProfileList(data any) ([]ProfileListItem, bool) {
@@ -261,6 +294,16 @@ func tryAsProfileList(data any) ([]ProfileListItem, bool) {
 			if v, ok := m["mode"].(string); ok {
 				p.Mode = v
 			}
+			switch v := m["tags"].(type) {
+			case []string:
+				p.Tags = v
+			case []any:
+				for _, t := range v {
+					if s, ok := t.(string); ok {
+						p.Tags = append(p.Tags, s)
+					}
+				}
+			}
 			if p.Name == "" {
 				return nil, false
 			}
@@ -297,6 +340,11 @@ func tryAsProfileList(data any) ([]ProfileListItem, bool) {
 			if f := elem.FieldByName("Mode"); f.IsValid() && f.Kind() == reflect.String {
 				p.Mode = f.String()
 			}
+			if f := elem.FieldByName("Tags"); f.IsValid() && f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String {
+				for j := 0; j < f.Len(); j++ {
+					p.Tags = append(p.Tags, f.Index(j).String())
+				}
+			}
 			if p.Name == "" {
 				return nil, false
 			}
@@ -426,6 +474,14 @@ func tryAsQueryResultReflect(data any) (*queryResultLike, bool) {
 	return &queryResultLike{columns: cols, rows: rows}, true
 }
 
+// writeQueryResult writes a query result using the layout selected by opts.
+func writeQueryResult(out io.Writer, cols []string, rows []map[string]any, opts tableOptions) error {
+	if useVerticalLayout(opts.layout, opts.termWidth, cols, rows) {
+		return writeQueryResultVertical(out, cols, rows)
+	}
+	return writeQueryResultTable(out, cols, rows)
+}
+
 func writeQueryResultTable(out io.Writer, cols []string, rows []map[string]any) error {
 	tw := tabwriter.NewWriter(out, 0, 2, 2, ' ', 0)
 
@@ -332,7 +332,7 @@ func TestWriteError_CSVFormat(t *testing.T) {
 }
 
 func TestIsValid(t *testing.T) {
-	validFormats := []Format{FormatJSON, FormatYAML, FormatTable, FormatCSV, FormatAuto}
+	validFormats := []Format{FormatJSON, FormatYAML, FormatTable, FormatCSV, FormatTemplate, FormatAuto}
 	for _, f := range validFormats {
 		if !IsValid(f) {
 			t.Errorf("IsValid(%s) should be true", f)
@@ -818,7 +818,7 @@ func TestTryAsQueryResultReflect_RowMapNonStringKey(t *testing.T) {
 
 func TestWriteTable_ErrorWithoutErrorObject(t *testing.T) {
 	var out bytes.Buffer
-	err := writeTable(&out, Envelope{OK: false, Error: nil})
+	err := writeTable(&out, Envelope{OK: false, Error: nil}, tableOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -887,3 +887,81 @@ func TestWriteOK_TableFormat_JSONFallback(t *testing.T) {
 		t.Fatalf("expected JSON fallback output, got: %s", result)
 	}
 }
+
+func TestWriteOK_TableFormat_VerticalLayout(t *testing.T) {
+	var out bytes.Buffer
+	w := New(&out, &bytes.Buffer{})
+	w.TableLayout = TableLayoutVertical
+
+	data := map[string]any{
+		"columns": []string{"id", "name"},
+		"rows": []map[string]any{
+			{"id": 1, "name": "Alice"},
+			{"id": 2, "name": nil},
+		},
+	}
+	if err := w.WriteOK(FormatTable, data); err != nil {
+		t.Fatal(err)
+	}
+
+	result := out.String()
+	for _, want := range []string{"-[ RECORD 1 ]", "-[ RECORD 2 ]", "id   | 1", "name | Alice", "name | <null>", "(2 rows)"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("vertical output should contain %q, got:\n%s", want, result)
+		}
+	}
+}
+
+func TestWriteOK_TableFormat_AutoLayout(t *testing.T) {
+	data := &tableFormatterWide{}
+
+	var narrow bytes.Buffer
+	w := New(&narrow, &bytes.Buffer{})
+	w.TermWidth = 20
+	if err := w.WriteOK(FormatTable, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(narrow.String(), "-[ RECORD 1 ]") {
+		t.Errorf("auto layout should switch to vertical on narrow terminal, got:\n%s", narrow.String())
+	}
+
+	var wide bytes.Buffer
+	w = New(&wide, &bytes.Buffer{})
+	w.TermWidth = 200
+	if err := w.WriteOK(FormatTable, data); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(wide.String(), "RECORD") {
+		t.Errorf("auto layout should stay horizontal on wide terminal, got:\n%s", wide.String())
+	}
+
+	var forced bytes.Buffer
+	w = New(&forced, &bytes.Buffer{})
+	w.TermWidth = 20
+	w.TableLayout = TableLayoutHorizontal
+	if err := w.WriteOK(FormatTable, data); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(forced.String(), "RECORD") {
+		t.Errorf("horizontal layout must not switch to vertical, got:\n%s", forced.String())
+	}
+}
+
+type tableFormatterWide struct{}
+
+func (tableFormatterWide) ToTableData() ([]string, []map[string]any, bool) {
+	return []string{"id", "description"}, []map[string]any{
+		{"id": 1, "description": "a fairly long description value"},
+	}, true
+}
+
+func TestIsValidTableLayout(t *testing.T) {
+	for _, l := range []TableLayout{TableLayoutAuto, TableLayoutHorizontal, TableLayoutVertical} {
+		if !IsValidTableLayout(l) {
+			t.Errorf("expected %q to be valid", l)
+		}
+	}
+	if IsValidTableLayout("diagonal") {
+		t.Error("expected unknown layout to be invalid")
+	}
+}
@@ -0,0 +1,83 @@
+package secret
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// Backend resolves secret references of one scheme, e.g. "env" for env:VAR.
+// ref is the part after "<scheme>:".
+type Backend interface {
+	Resolve(ref string, opts Options) (string, *errors.XError)
+}
+
+// BackendFunc adapts a function to the Backend interface.
+type BackendFunc func(ref string, opts Options) (string, *errors.XError)
+
+func (f BackendFunc) Resolve(ref string, opts Options) (string, *errors.XError) {
+	return f(ref, opts)
+}
+
+// Credentials is a password, optionally with the username it belongs to.
+// Leased credentials have a LeaseID and a Revoke func that ends the lease.
+type Credentials struct {
+	Username string
+	Password string
+	LeaseID  string
+	Revoke   func() *errors.XError
+}
+
+// CredentialBackend is implemented by backends that can return a username
+// along with the password, e.g. dynamic database credentials.
+type CredentialBackend interface {
+	Backend
+	Credentials(ref string, opts Options) (*Credentials, *errors.XError)
+}
+
+var (
+	mu       sync.RWMutex
+	backends = map[string]Backend{}
+)
+
+// Register makes a backend available for "<scheme>:" references.
+func Register(scheme string, b Backend) {
+	mu.Lock()
+	defer mu.Unlock()
+	if scheme == "" || strings.Contains(scheme, ":") {
+		panic("secret.Register: invalid scheme: " + scheme)
+	}
+	if b == nil {
+		panic("secret.Register: nil backend")
+	}
+	if _, exists := backends[scheme]; exists {
+		panic("secret.Register: duplicate scheme: " + scheme)
+	}
+	backends[scheme] = b
+}
+
+// Schemes returns the registered reference schemes, sorted.
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]string, 0, len(backends))
+	for k := range backends {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// lookup splits raw into a registered backend and its reference.
+func lookup(raw string) (Backend, string, bool) {
+	scheme, ref, ok := strings.Cut(raw, ":")
+	if !ok {
+		return nil, "", false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	b, ok := backends[scheme]
+	return b, ref, ok
+}
@@ -0,0 +1,143 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func TestSchemes(t *testing.T) {
+	want := []string{"env", "exec", "file", "keyring", "vault"}
+	if got := Schemes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Schemes() = %v, want %v", got, want)
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for duplicate scheme")
+		}
+	}()
+	Register("env", BackendFunc(resolveEnv))
+}
+
+func TestIsRefAndScheme(t *testing.T) {
+	tests := map[string]string{
+		"keyring:prod/pg": "keyring",
+		"env:PGPASSWORD":  "env",
+		"file:~/.pgpass":  "file",
+		"exec:pass show":  "exec",
+		"vault:kv/db":     "vault",
+		"op://item":       "",
+		"plain:text":      "",
+		"secret":          "",
+	}
+	for s, want := range tests {
+		if got := Scheme(s); got != want {
+			t.Errorf("Scheme(%q) = %q, want %q", s, got, want)
+		}
+		if IsRef(s) != (want != "") {
+			t.Errorf("IsRef(%q) = %v", s, IsRef(s))
+		}
+	}
+}
+
+func TestResolve_Env(t *testing.T) {
+	t.Setenv("XSQL_TEST_SECRET", "from-env")
+	val, xe := Resolve("env:XSQL_TEST_SECRET", Options{})
+	if xe != nil || val != "from-env" {
+		t.Fatalf("got %q, %v", val, xe)
+	}
+
+	_, xe = Resolve("env:XSQL_TEST_SECRET_UNSET", Options{})
+	if xe == nil || xe.Code != errors.CodeSecretNotFound || xe.Details["env"] != "XSQL_TEST_SECRET_UNSET" {
+		t.Fatalf("expected SecretNotFound, got %v", xe)
+	}
+	if _, xe := Resolve("env:", Options{}); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Fatalf("expected CfgInvalid for empty name, got %v", xe)
+	}
+}
+
+func TestResolve_File(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pw")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	val, xe := Resolve("file:"+path, Options{})
+	if xe != nil || val != "s3cret" {
+		t.Fatalf("got %q, %v", val, xe)
+	}
+
+	if _, xe := Resolve("file:"+filepath.Join(dir, "missing"), Options{}); xe == nil || xe.Code != errors.CodeSecretNotFound {
+		t.Fatalf("expected SecretNotFound for a missing file, got %v", xe)
+	}
+
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(path, 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, xe := Resolve("file:"+path, Options{})
+		if xe == nil || xe.Code != errors.CodeCfgInvalid || xe.Details["mode"] != "0644" {
+			t.Fatalf("expected CfgInvalid for a world-readable file, got %v", xe)
+		}
+	}
+}
+
+func TestResolve_Exec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	val, xe := Resolve(`exec:sh -c 'echo "hunter 2"'`, Options{})
+	if xe != nil || val != "hunter 2" {
+		t.Fatalf("got %q, %v", val, xe)
+	}
+
+	_, xe = Resolve(`exec:sh -c 'echo denied >&2; exit 3'`, Options{})
+	if xe == nil || xe.Code != errors.CodeSecretNotFound || xe.Details["stderr"] != "denied" {
+		t.Fatalf("expected SecretNotFound with stderr, got %v", xe)
+	}
+
+	_, xe = Resolve("exec:sleep 5", Options{ExecTimeout: 50 * time.Millisecond})
+	if xe == nil || !strings.Contains(xe.Message, "timed out") {
+		t.Fatalf("expected timeout, got %v", xe)
+	}
+
+	if _, xe := Resolve("exec:true", Options{}); xe == nil || xe.Code != errors.CodeSecretNotFound {
+		t.Fatalf("expected SecretNotFound for empty output, got %v", xe)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"pass show db/prod", []string{"pass", "show", "db/prod"}},
+		{`op read "op://vault/My Item/password"`, []string{"op", "read", "op://vault/My Item/password"}},
+		{`a 'b "c"' d\ e`, []string{"a", `b "c"`, "d e"}},
+		{"  spaced   out  ", []string{"spaced", "out"}},
+		{`empty ""`, []string{"empty", ""}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, xe := SplitArgs(tt.in)
+		if xe != nil {
+			t.Errorf("SplitArgs(%q): %v", tt.in, xe)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if _, xe := SplitArgs(`op read "unterminated`); xe == nil {
+		t.Error("expected error for unterminated quote")
+	}
+}
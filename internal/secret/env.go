@@ -0,0 +1,25 @@
+package secret
+
+import (
+	"os"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func init() {
+	Register("env", BackendFunc(resolveEnv))
+}
+
+// resolveEnv reads env:<VAR> from the process environment. An unset variable
+// is an error; a variable set to "" resolves to "".
+func resolveEnv(ref string, _ Options) (string, *errors.XError) {
+	if ref == "" {
+		return "", errors.New(errors.CodeCfgInvalid, "invalid env reference: empty variable name", nil)
+	}
+	val, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", errors.New(errors.CodeSecretNotFound, "environment variable for secret is not set",
+			map[string]any{"env": ref})
+	}
+	return val, nil
+}
@@ -0,0 +1,180 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// Encrypted config values use SOPS-style envelopes:
+//
+//	ENC[AES256_GCM,data:<base64>,iv:<base64>,tag:<base64>,type:<str|int|float|bool|file>]
+//
+// The AES-256 key is read from, in order: the file named by
+// XSQL_CONFIG_KEY_FILE, ~/.config/xsql/key, or the keyring account config-key.
+const (
+	KeyFileEnv        = "XSQL_CONFIG_KEY_FILE"
+	KeyringKeyAccount = "config-key"
+
+	keyTextPrefix  = "XSQL-KEY-"
+	envelopePrefix = "ENC[AES256_GCM,"
+)
+
+// Key is a config encryption key.
+type Key struct {
+	b []byte
+}
+
+// DefaultKeyPath returns ~/.config/xsql/key, or "" without a home directory.
+func DefaultKeyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "xsql", "key")
+}
+
+// GenerateKey returns a new random key in text form (XSQL-KEY-...).
+func GenerateKey() (string, *errors.XError) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(errors.CodeInternal, "failed to generate key", nil, err)
+	}
+	return keyTextPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ParseKey parses the text form of a key; source names where it came from.
+func ParseKey(text, source string) (*Key, *errors.XError) {
+	text = strings.TrimSpace(text)
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(text, keyTextPrefix))
+	if !strings.HasPrefix(text, keyTextPrefix) || err != nil || len(b) != 32 {
+		return nil, errors.New(errors.CodeCfgInvalid, "invalid config encryption key", map[string]any{"source": source})
+	}
+	return &Key{b: b}, nil
+}
+
+// LoadKey finds the config encryption key. A nil kr uses the default keyring.
+func LoadKey(kr KeyringAPI) (*Key, *errors.XError) {
+	path, explicit := os.Getenv(KeyFileEnv), true
+	if path == "" {
+		path, explicit = DefaultKeyPath(), false
+	}
+	if path != "" {
+		st, err := os.Stat(path)
+		switch {
+		case err == nil:
+			if runtime.GOOS != "windows" && st.Mode().Perm()&0o077 != 0 {
+				return nil, errors.New(errors.CodeCfgInvalid, "config key file must not be accessible by group or others (chmod 600)",
+					map[string]any{"path": path, "mode": fmt.Sprintf("%#o", st.Mode().Perm())})
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, errors.Wrap(errors.CodeCfgInvalid, "failed to read config key file", map[string]any{"path": path}, err)
+			}
+			return ParseKey(string(b), path)
+		case explicit || !os.IsNotExist(err):
+			return nil, errors.Wrap(errors.CodeCfgInvalid, "failed to read config key file", map[string]any{"path": path}, err)
+		}
+	}
+	text, xe := resolveKeyring(KeyringKeyAccount, Options{Keyring: kr})
+	if xe != nil {
+		return nil, errors.New(errors.CodeSecretNotFound,
+			"no config encryption key found; run 'xsql config keygen' or set "+KeyFileEnv,
+			map[string]any{"key_file": path, "keyring_account": KeyringKeyAccount})
+	}
+	return ParseKey(text, KeyringRef(KeyringKeyAccount))
+}
+
+// IsEncrypted reports whether s is an encrypted envelope.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, envelopePrefix) && strings.HasSuffix(s, "]")
+}
+
+// Encrypt seals plaintext into an envelope; typ records the YAML type of the
+// value (str, int, float, bool) or "file" for a whole config file.
+func (k *Key) Encrypt(plaintext, typ string) (string, *errors.XError) {
+	gcm, xe := k.gcm()
+	if xe != nil {
+		return "", xe
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", errors.Wrap(errors.CodeInternal, "failed to generate nonce", nil, err)
+	}
+	sealed := gcm.Seal(nil, iv, []byte(plaintext), []byte(typ))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	enc := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("%sdata:%s,iv:%s,tag:%s,type:%s]", envelopePrefix, enc(data), enc(iv), enc(tag), typ), nil
+}
+
+// Decrypt opens an envelope and returns the plaintext and its type.
+func (k *Key) Decrypt(envelope string) (string, string, *errors.XError) {
+	invalid := errors.New(errors.CodeCfgInvalid, "malformed encrypted value", nil)
+	if !IsEncrypted(envelope) {
+		return "", "", invalid
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(envelope, envelopePrefix), "]"), ",") {
+		name, value, ok := strings.Cut(part, ":")
+		if !ok {
+			return "", "", invalid
+		}
+		fields[name] = value
+	}
+	dec := base64.StdEncoding.DecodeString
+	data, err1 := dec(fields["data"])
+	iv, err2 := dec(fields["iv"])
+	tag, err3 := dec(fields["tag"])
+	typ := fields["type"]
+	if err1 != nil || err2 != nil || err3 != nil || typ == "" {
+		return "", "", invalid
+	}
+	gcm, xe := k.gcm()
+	if xe != nil {
+		return "", "", xe
+	}
+	if len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+		return "", "", invalid
+	}
+	plain, err := gcm.Open(nil, iv, append(data, tag...), []byte(typ))
+	if err != nil {
+		return "", "", errors.New(errors.CodeCfgInvalid, "failed to decrypt value; wrong config key?", nil)
+	}
+	return string(plain), typ, nil
+}
+
+func (k *Key) gcm() (cipher.AEAD, *errors.XError) {
+	block, err := aes.NewCipher(k.b)
+	if err != nil {
+		return nil, errors.Wrap(errors.CodeInternal, "invalid key", nil, err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(errors.CodeInternal, "invalid key", nil, err)
+	}
+	return gcm, nil
+}
+
+// resolveEnvelope decrypts an encrypted secret value.
+func resolveEnvelope(raw string, opts Options) (string, *errors.XError) {
+	key, xe := LoadKey(opts.Keyring)
+	if xe != nil {
+		return "", xe
+	}
+	val, typ, xe := key.Decrypt(raw)
+	if xe != nil {
+		return "", xe
+	}
+	if typ != "str" {
+		return "", errors.New(errors.CodeCfgInvalid, "encrypted secret must be a string", map[string]any{"type": typ})
+	}
+	return val, nil
+}
@@ -0,0 +1,107 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// writeTestKey writes a new key file and points XSQL_CONFIG_KEY_FILE at it.
+func writeTestKey(t *testing.T) *Key {
+	t.Helper()
+	text, xe := GenerateKey()
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(text+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(KeyFileEnv, path)
+	key, xe := ParseKey(text, path)
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	return key
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	key := writeTestKey(t)
+
+	env, xe := key.Encrypt("p@ss, word]", "str")
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if !IsEncrypted(env) || !strings.HasSuffix(env, ",type:str]") {
+		t.Fatalf("unexpected envelope %q", env)
+	}
+	plain, typ, xe := key.Decrypt(env)
+	if xe != nil || plain != "p@ss, word]" || typ != "str" {
+		t.Fatalf("got %q, %q, %v", plain, typ, xe)
+	}
+
+	// Envelopes are secret references resolved with the configured key.
+	if !IsRef(env) || Scheme(env) != "" {
+		t.Errorf("IsRef/Scheme mismatch for envelope")
+	}
+	val, xe := Resolve(env, Options{})
+	if xe != nil || val != "p@ss, word]" {
+		t.Fatalf("Resolve = %q, %v", val, xe)
+	}
+
+	other := writeTestKey(t)
+	if _, _, xe := other.Decrypt(env); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("expected wrong-key error, got %v", xe)
+	}
+	tampered := strings.Replace(env, "type:str", "type:int", 1)
+	if _, _, xe := key.Decrypt(tampered); xe == nil {
+		t.Error("expected error for tampered type")
+	}
+	if _, _, xe := key.Decrypt("ENC[AES256_GCM,data:??]"); xe == nil {
+		t.Error("expected error for malformed envelope")
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(KeyFileEnv, "")
+
+	if _, xe := LoadKey(nil); xe == nil || xe.Code != errors.CodeSecretNotFound {
+		t.Fatalf("expected SecretNotFound without a key, got %v", xe)
+	}
+
+	text, _ := GenerateKey()
+	if xe := SetKeyring(nil, KeyringKeyAccount, text); xe != nil {
+		t.Fatal(xe)
+	}
+	if _, xe := LoadKey(nil); xe != nil {
+		t.Fatalf("expected keyring key, got %v", xe)
+	}
+
+	t.Setenv(KeyFileEnv, filepath.Join(t.TempDir(), "missing"))
+	if _, xe := LoadKey(nil); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("expected CfgInvalid for a missing explicit key file, got %v", xe)
+	}
+
+	if runtime.GOOS != "windows" {
+		path := filepath.Join(t.TempDir(), "key")
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(KeyFileEnv, path)
+		if _, xe := LoadKey(nil); xe == nil || xe.Details["mode"] != "0644" {
+			t.Errorf("expected permission error, got %v", xe)
+		}
+	}
+
+	if _, xe := ParseKey("XSQL-KEY-short", "test"); xe == nil {
+		t.Error("expected error for short key")
+	}
+}
@@ -0,0 +1,112 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// DefaultExecTimeout bounds how long an exec: helper may run.
+const DefaultExecTimeout = 10 * time.Second
+
+// maxStderr limits how much helper stderr is reported in error details.
+const maxStderr = 512
+
+func init() {
+	Register("exec", BackendFunc(resolveExec))
+}
+
+// resolveExec runs exec:<command args> (e.g. "exec:pass show db/prod" or
+// "exec:op read op://vault/db/password") without a shell and returns its
+// stdout with trailing newlines trimmed. Arguments are split on spaces; single
+// or double quotes group an argument containing spaces.
+func resolveExec(ref string, opts Options) (string, *errors.XError) {
+	args, xe := SplitArgs(ref)
+	if xe != nil {
+		return "", xe
+	}
+	if len(args) == 0 {
+		return "", errors.New(errors.CodeCfgInvalid, "invalid exec reference: empty command", nil)
+	}
+
+	timeout := opts.ExecTimeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		details := map[string]any{"command": args[0]}
+		if ctx.Err() == context.DeadlineExceeded {
+			details["timeout"] = timeout.String()
+			return "", errors.Wrap(errors.CodeSecretNotFound, "secret command timed out", details, err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			if len(msg) > maxStderr {
+				msg = msg[:maxStderr] + "..."
+			}
+			details["stderr"] = msg
+		}
+		return "", errors.Wrap(errors.CodeSecretNotFound, "secret command failed", details, err)
+	}
+
+	val := strings.TrimRight(stdout.String(), "\r\n")
+	if val == "" {
+		return "", errors.New(errors.CodeSecretNotFound, "secret command produced no output", map[string]any{"command": args[0]})
+	}
+	return val, nil
+}
+
+// SplitArgs splits a command line on whitespace, honoring single and double
+// quotes and backslash escapes outside single quotes.
+func SplitArgs(s string) ([]string, *errors.XError) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New(errors.CodeCfgInvalid, "invalid exec reference: unterminated quote or escape", nil)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
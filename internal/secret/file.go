@@ -0,0 +1,56 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func init() {
+	Register("file", BackendFunc(resolveFile))
+}
+
+// resolveFile reads file:<path>. Like ssh with private keys, it refuses files
+// that group or others can access. Trailing newlines are trimmed.
+func resolveFile(ref string, _ Options) (string, *errors.XError) {
+	if ref == "" {
+		return "", errors.New(errors.CodeCfgInvalid, "invalid file reference: empty path", nil)
+	}
+	path := expandHome(ref)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", errors.Wrap(errors.CodeSecretNotFound, "failed to read secret file", map[string]any{"path": ref}, err)
+	}
+	if info.IsDir() {
+		return "", errors.New(errors.CodeCfgInvalid, "secret file is a directory", map[string]any{"path": ref})
+	}
+	// Windows has no POSIX permission bits to check.
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return "", errors.New(errors.CodeCfgInvalid, "secret file permissions are too open; run chmod 600", map[string]any{
+			"path": ref,
+			"mode": fmt.Sprintf("%#o", info.Mode().Perm()),
+		})
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(errors.CodeSecretNotFound, "failed to read secret file", map[string]any{"path": ref}, err)
+	}
+	val := strings.TrimRight(string(b), "\r\n")
+	if val == "" {
+		return "", errors.New(errors.CodeSecretNotFound, "secret file is empty", map[string]any{"path": ref})
+	}
+	return val, nil
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return p
+}
@@ -1,6 +1,14 @@
 // Package secret handles credential resolution including OS keyring integration.
 package secret
 
+import (
+	stderrors "errors"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
 // KeyringAPI is a minimal abstraction over the OS keyring for testability and cross-platform support.
 // service corresponds to the keyring service name; account corresponds to the user/account.
 type KeyringAPI interface {
@@ -18,3 +26,68 @@ func defaultKeyring() KeyringAPI {
 type osKeyring struct{}
 
 // Get/Set/Delete are implemented in keyring_default.go.
+
+func init() {
+	Register("keyring", BackendFunc(resolveKeyring))
+}
+
+// resolveKeyring reads keyring:<account> from the OS keyring (service "xsql").
+func resolveKeyring(ref string, opts Options) (string, *errors.XError) {
+	service, account, parseErr := parseKeyringRef(ref)
+	if parseErr != nil {
+		return "", parseErr
+	}
+	kr := opts.Keyring
+	if kr == nil {
+		kr = defaultKeyring()
+	}
+	val, err := kr.Get(service, account)
+	if err != nil {
+		return "", errors.Wrap(errors.CodeSecretNotFound, "failed to read secret from keyring",
+			map[string]any{"service": service, "account": account}, err)
+	}
+	return val, nil
+}
+
+// KeyringRef returns the keyring:<account> reference for account.
+func KeyringRef(account string) string {
+	return keyringPrefix + account
+}
+
+// SetKeyring stores value for account in the OS keyring (service "xsql").
+// A nil kr uses the default keyring.
+func SetKeyring(kr KeyringAPI, account, value string) *errors.XError {
+	service, account, xe := parseKeyringRef(account)
+	if xe != nil {
+		return xe
+	}
+	if kr == nil {
+		kr = defaultKeyring()
+	}
+	if err := kr.Set(service, account, value); err != nil {
+		return errors.Wrap(errors.CodeInternal, "failed to write secret to keyring",
+			map[string]any{"service": service, "account": account}, err)
+	}
+	return nil
+}
+
+// DeleteKeyring removes account from the OS keyring (service "xsql").
+// A nil kr uses the default keyring.
+func DeleteKeyring(kr KeyringAPI, account string) *errors.XError {
+	service, account, xe := parseKeyringRef(account)
+	if xe != nil {
+		return xe
+	}
+	if kr == nil {
+		kr = defaultKeyring()
+	}
+	if err := kr.Delete(service, account); err != nil {
+		code := errors.CodeInternal
+		if stderrors.Is(err, keyring.ErrNotFound) {
+			code = errors.CodeSecretNotFound
+		}
+		return errors.Wrap(code, "failed to delete secret from keyring",
+			map[string]any{"service": service, "account": account}, err)
+	}
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/zalando/go-keyring"
+
+	"github.com/zx06/xsql/internal/errors"
 )
 
 // nullByteKeyring 模拟 Windows cmdkey 返回带 null 字节的值
@@ -264,3 +266,24 @@ func TestDefaultKeyring_NullByteBehavior(t *testing.T) {
 		t.Fatalf("expected raw value on non-windows, got %q", got)
 	}
 }
+
+func TestSetAndDeleteKeyring(t *testing.T) {
+	keyring.MockInit()
+
+	if xe := SetKeyring(nil, "prod/db", "s3cret"); xe != nil {
+		t.Fatal(xe)
+	}
+	val, xe := Resolve(KeyringRef("prod/db"), Options{})
+	if xe != nil || val != "s3cret" {
+		t.Fatalf("got %q, %v", val, xe)
+	}
+	if xe := DeleteKeyring(nil, "prod/db"); xe != nil {
+		t.Fatal(xe)
+	}
+	if xe := DeleteKeyring(nil, "prod/db"); xe == nil || xe.Code != errors.CodeSecretNotFound {
+		t.Errorf("expected SecretNotFound deleting a missing account, got %v", xe)
+	}
+	if xe := SetKeyring(nil, "", "x"); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("expected CfgInvalid for empty account, got %v", xe)
+	}
+}
@@ -2,6 +2,7 @@ package secret
 
 import (
 	"strings"
+	"time"
 
 	"github.com/zx06/xsql/internal/errors"
 )
@@ -10,8 +11,10 @@ const keyringPrefix = "keyring:"
 
 // Options controls secret resolution behavior.
 type Options struct {
-	AllowPlaintext bool       // whether to allow plaintext secrets (default false)
-	Keyring        KeyringAPI // injectable keyring implementation (nil uses default)
+	AllowPlaintext bool          // whether to allow plaintext secrets (default false)
+	Keyring        KeyringAPI    // injectable keyring implementation (nil uses default)
+	ExecTimeout    time.Duration // timeout for exec: helpers (0 uses DefaultExecTimeout)
+	VaultTimeout   time.Duration // timeout for each Vault request (0 uses DefaultVaultTimeout)
 }
 
 const defaultService = "xsql"
@@ -29,37 +32,63 @@ func parseKeyringRef(ref string) (service, account string, err *errors.XError) {
 }
 
 // Resolve resolves a secret value following the order defined in docs/config.md:
-//  1. keyring:<service>/<account> → read from the keyring
+//  1. <scheme>:<ref> for a registered backend (keyring:, env:, file:, exec:, vault:) → read from the backend
+//     or an ENC[...] envelope → decrypt with the config key
 //  2. otherwise, if plaintext and plaintext is allowed → return as-is
 //  3. otherwise → return an error
 //
 // Note: TTY interactive input is not implemented at this layer (left to the cmd layer).
 func Resolve(raw string, opts Options) (string, *errors.XError) {
-	if strings.HasPrefix(raw, keyringPrefix) {
-		ref := strings.TrimPrefix(raw, keyringPrefix)
-		service, account, parseErr := parseKeyringRef(ref)
-		if parseErr != nil {
-			return "", parseErr
-		}
-		kr := opts.Keyring
-		if kr == nil {
-			kr = defaultKeyring()
-		}
-		val, err := kr.Get(service, account)
-		if err != nil {
-			return "", errors.Wrap(errors.CodeSecretNotFound, "failed to read secret from keyring",
-				map[string]any{"service": service, "account": account}, err)
-		}
-		return val, nil
+	if IsEncrypted(raw) {
+		return resolveEnvelope(raw, opts)
+	}
+	if b, ref, ok := lookup(raw); ok {
+		return b.Resolve(ref, opts)
 	}
 	// Plaintext
 	if opts.AllowPlaintext {
 		return raw, nil
 	}
-	return "", errors.New(errors.CodeCfgInvalid, "plaintext secret not allowed; use keyring: reference or enable --allow-plaintext", nil)
+	return "", errors.New(errors.CodeCfgInvalid, "plaintext secret not allowed; use a keyring:, env:, file:, exec: or vault: reference or enable --allow-plaintext", nil)
+}
+
+// ResolveCredentials resolves a password value like Resolve. When the backend
+// supplies a whole credential pair (a Vault database secrets engine), the
+// result also carries the username and, for leased credentials, Revoke.
+func ResolveCredentials(raw string, opts Options) (*Credentials, *errors.XError) {
+	if b, ref, ok := lookup(raw); ok {
+		if cb, ok := b.(CredentialBackend); ok {
+			return cb.Credentials(ref, opts)
+		}
+	}
+	pw, xe := Resolve(raw, opts)
+	if xe != nil {
+		return nil, xe
+	}
+	return &Credentials{Password: pw}, nil
 }
 
 // IsKeyringRef reports whether s is a keyring reference.
 func IsKeyringRef(s string) bool {
 	return strings.HasPrefix(s, keyringPrefix)
 }
+
+// IsRef reports whether s is a reference to any registered backend or an
+// encrypted envelope rather than a plaintext secret.
+func IsRef(s string) bool {
+	if IsEncrypted(s) {
+		return true
+	}
+	_, _, ok := lookup(s)
+	return ok
+}
+
+// Scheme returns the backend scheme of a reference ("keyring", "exec", ...),
+// or "" for plaintext.
+func Scheme(s string) string {
+	if _, _, ok := lookup(s); !ok {
+		return ""
+	}
+	scheme, _, _ := strings.Cut(s, ":")
+	return scheme
+}
@@ -0,0 +1,256 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// Vault is configured through the same environment variables as the vault CLI.
+const (
+	vaultAddrEnv      = "VAULT_ADDR"
+	vaultTokenEnv     = "VAULT_TOKEN"
+	vaultNamespaceEnv = "VAULT_NAMESPACE"
+	vaultCACertEnv    = "VAULT_CACERT"
+)
+
+// DefaultVaultTimeout bounds each request to Vault.
+const DefaultVaultTimeout = 10 * time.Second
+
+func init() {
+	Register("vault", vaultBackend{})
+}
+
+// vaultBackend resolves vault:<path>[#field] references.
+//
+// A KV secret (v1 or v2, e.g. "vault:secret/data/prod/db#password") resolves
+// to one field; the field may be omitted when the secret has a single key.
+// A database secrets engine path (e.g. "vault:database/creds/readonly") leases
+// a dynamic username/password pair, which only Credentials can return since
+// the caller must revoke the lease when done.
+type vaultBackend struct{}
+
+func (b vaultBackend) Resolve(ref string, opts Options) (string, *errors.XError) {
+	creds, xe := b.Credentials(ref, opts)
+	if xe != nil {
+		return "", xe
+	}
+	if creds.Revoke != nil {
+		_ = creds.Revoke()
+		return "", errors.New(errors.CodeCfgInvalid, "vault dynamic credentials can only be used as a profile password",
+			map[string]any{"ref": ref})
+	}
+	return creds.Password, nil
+}
+
+func (vaultBackend) Credentials(ref string, opts Options) (*Credentials, *errors.XError) {
+	path, field := ref, ""
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		path, field = ref[:i], ref[i+1:]
+	}
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, errors.New(errors.CodeCfgInvalid, "invalid vault reference: empty path", nil)
+	}
+
+	c, xe := newVaultClient(opts)
+	if xe != nil {
+		return nil, xe
+	}
+	resp, xe := c.do(http.MethodGet, path, nil)
+	if xe != nil {
+		return nil, xe
+	}
+
+	if resp.LeaseID != "" && field == "" {
+		user, _ := resp.Data["username"].(string)
+		pass, _ := resp.Data["password"].(string)
+		if user != "" && pass != "" {
+			leaseID := resp.LeaseID
+			return &Credentials{
+				Username: user,
+				Password: pass,
+				LeaseID:  leaseID,
+				Revoke: func() *errors.XError {
+					_, xe := c.do(http.MethodPut, "sys/leases/revoke", map[string]any{"lease_id": leaseID})
+					return xe
+				},
+			}, nil
+		}
+	}
+
+	data := resp.Data
+	// KV v2 nests the secret under data.data next to data.metadata.
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	val, xe := vaultField(path, data, field)
+	if xe != nil {
+		return nil, xe
+	}
+	return &Credentials{Password: val}, nil
+}
+
+// vaultField picks field from a secret's data. An empty field selects the only
+// key of a single-key secret.
+func vaultField(path string, data map[string]any, field string) (string, *errors.XError) {
+	if field == "" {
+		if len(data) != 1 {
+			keys := make([]string, 0, len(data))
+			for k := range data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return "", errors.New(errors.CodeCfgInvalid, "vault secret has several fields; select one with #<field>",
+				map[string]any{"path": path, "fields": keys})
+		}
+		for k := range data {
+			field = k
+		}
+	}
+	raw, ok := data[field]
+	if !ok {
+		return "", errors.New(errors.CodeSecretNotFound, "vault secret field not found",
+			map[string]any{"path": path, "field": field})
+	}
+	val, ok := raw.(string)
+	if !ok {
+		return "", errors.New(errors.CodeCfgInvalid, "vault secret field is not a string",
+			map[string]any{"path": path, "field": field})
+	}
+	return val, nil
+}
+
+type vaultClient struct {
+	addr      string
+	token     string
+	namespace string
+	timeout   time.Duration
+	http      *http.Client
+}
+
+type vaultResponse struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Data          map[string]any `json:"data"`
+	Errors        []string       `json:"errors"`
+}
+
+// newVaultClient reads the Vault address and token from the environment,
+// falling back to ~/.vault-token like the vault CLI.
+func newVaultClient(opts Options) (*vaultClient, *errors.XError) {
+	addr := strings.TrimRight(os.Getenv(vaultAddrEnv), "/")
+	if addr == "" {
+		return nil, errors.New(errors.CodeCfgInvalid, "vault: references require "+vaultAddrEnv, nil)
+	}
+	token := os.Getenv(vaultTokenEnv)
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if b, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(b))
+			}
+		}
+	}
+	if token == "" {
+		return nil, errors.New(errors.CodeCfgInvalid, "vault: references require "+vaultTokenEnv+" or ~/.vault-token", nil)
+	}
+
+	client := &http.Client{}
+	if ca := os.Getenv(vaultCACertEnv); ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, errors.Wrap(errors.CodeCfgInvalid, "failed to read "+vaultCACertEnv, map[string]any{"path": ca}, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New(errors.CodeCfgInvalid, vaultCACertEnv+" contains no certificates", map[string]any{"path": ca})
+		}
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}
+	}
+
+	timeout := opts.VaultTimeout
+	if timeout <= 0 {
+		timeout = DefaultVaultTimeout
+	}
+	return &vaultClient{
+		addr:      addr,
+		token:     token,
+		namespace: os.Getenv(vaultNamespaceEnv),
+		timeout:   timeout,
+		http:      client,
+	}, nil
+}
+
+// do sends a request to /v1/<path> and decodes the response.
+func (c *vaultClient) do(method, path string, body any) (*vaultResponse, *errors.XError) {
+	details := map[string]any{"path": path}
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(errors.CodeInternal, "failed to encode vault request", details, err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, c.addr+"/v1/"+path, reader)
+	if err != nil {
+		return nil, errors.Wrap(errors.CodeCfgInvalid, "invalid vault request", details, err)
+	}
+	req.Header.Set("X-Vault-Token", c.token)
+	req.Header.Set("X-Vault-Request", "true")
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(errors.CodeSecretNotFound, "vault request failed", details, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, errors.Wrap(errors.CodeSecretNotFound, "failed to read vault response", details, err)
+	}
+
+	var out vaultResponse
+	if len(bytes.TrimSpace(b)) > 0 {
+		if err := json.Unmarshal(b, &out); err != nil && resp.StatusCode < 300 {
+			return nil, errors.Wrap(errors.CodeSecretNotFound, "invalid vault response", details, err)
+		}
+	}
+	if resp.StatusCode >= 300 {
+		details["status"] = resp.StatusCode
+		if len(out.Errors) > 0 {
+			details["errors"] = out.Errors
+		}
+		msg := fmt.Sprintf("vault returned HTTP %d", resp.StatusCode)
+		if resp.StatusCode == http.StatusNotFound {
+			msg = "vault secret not found"
+		}
+		return nil, errors.New(errors.CodeSecretNotFound, msg, details)
+	}
+	return &out, nil
+}
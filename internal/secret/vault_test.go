@@ -0,0 +1,159 @@
+package secret
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+// fakeVault is an HTTP stand-in for the Vault API paths xsql uses.
+type fakeVault struct {
+	mu      sync.Mutex
+	revoked []string
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	var body any
+	switch r.Method + " " + r.URL.Path {
+	case "GET /v1/secret/data/prod/db":
+		body = map[string]any{"data": map[string]any{
+			"data":     map[string]any{"username": "app", "password": "kv2-pass"},
+			"metadata": map[string]any{"version": 3},
+		}}
+	case "GET /v1/kv/prod/token":
+		body = map[string]any{"data": map[string]any{"value": "kv1-token"}}
+	case "GET /v1/database/creds/readonly":
+		body = map[string]any{
+			"lease_id":       "database/creds/readonly/abc123",
+			"lease_duration": 3600,
+			"data":           map[string]any{"username": "v-ro-abc123", "password": "dyn-pass"},
+		}
+	case "PUT /v1/sys/leases/revoke":
+		var req struct {
+			LeaseID string `json:"lease_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		f.revoked = append(f.revoked, req.LeaseID)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors":[]}`))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeVault) revokedLeases() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.revoked...)
+}
+
+func startFakeVault(t *testing.T) *fakeVault {
+	t.Helper()
+	fv := &fakeVault{}
+	srv := httptest.NewServer(fv)
+	t.Cleanup(srv.Close)
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+	return fv
+}
+
+func TestVault_ResolveKV(t *testing.T) {
+	startFakeVault(t)
+
+	cases := map[string]string{
+		"vault:secret/data/prod/db#password": "kv2-pass",
+		"vault:kv/prod/token":                "kv1-token",
+		"vault:kv/prod/token#value":          "kv1-token",
+	}
+	for raw, want := range cases {
+		got, xe := Resolve(raw, Options{})
+		if xe != nil {
+			t.Errorf("%s: %v", raw, xe)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestVault_ResolveErrors(t *testing.T) {
+	startFakeVault(t)
+
+	cases := map[string]errors.Code{
+		"vault:":                         errors.CodeCfgInvalid,
+		"vault:secret/data/prod/db":      errors.CodeCfgInvalid, // several fields
+		"vault:secret/data/prod/db#nope": errors.CodeSecretNotFound,
+		"vault:secret/data/missing#x":    errors.CodeSecretNotFound,
+		"vault:database/creds/readonly":  errors.CodeCfgInvalid, // leased, not a single value
+	}
+	for raw, want := range cases {
+		_, xe := Resolve(raw, Options{})
+		if xe == nil || xe.Code != want {
+			t.Errorf("%s: expected %s, got %v", raw, want, xe)
+		}
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	_, xe := Resolve("vault:kv/prod/token", Options{})
+	if xe == nil || xe.Details["status"] != http.StatusForbidden {
+		t.Errorf("expected 403 error, got %v", xe)
+	}
+
+	t.Setenv("VAULT_ADDR", "")
+	if _, xe := Resolve("vault:kv/prod/token", Options{}); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("expected CfgInvalid without VAULT_ADDR, got %v", xe)
+	}
+}
+
+func TestVault_ResolveLeasedRevokes(t *testing.T) {
+	fv := startFakeVault(t)
+	if _, xe := Resolve("vault:database/creds/readonly", Options{}); xe == nil {
+		t.Fatal("expected error")
+	}
+	if got := fv.revokedLeases(); len(got) != 1 {
+		t.Errorf("expected the lease to be revoked, got %v", got)
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	fv := startFakeVault(t)
+
+	creds, xe := ResolveCredentials("vault:database/creds/readonly", Options{})
+	if xe != nil {
+		t.Fatal(xe)
+	}
+	if creds.Username != "v-ro-abc123" || creds.Password != "dyn-pass" || creds.LeaseID == "" || creds.Revoke == nil {
+		t.Fatalf("unexpected credentials %+v", creds)
+	}
+	if xe := creds.Revoke(); xe != nil {
+		t.Fatal(xe)
+	}
+	if got := fv.revokedLeases(); len(got) != 1 || got[0] != "database/creds/readonly/abc123" {
+		t.Errorf("revoked = %v", got)
+	}
+
+	// KV secrets and other backends only supply the password.
+	creds, xe = ResolveCredentials("vault:secret/data/prod/db#password", Options{})
+	if xe != nil || creds.Username != "" || creds.Password != "kv2-pass" || creds.Revoke != nil {
+		t.Errorf("unexpected KV credentials %+v, %v", creds, xe)
+	}
+	creds, xe = ResolveCredentials("plain", Options{AllowPlaintext: true})
+	if xe != nil || creds.Password != "plain" {
+		t.Errorf("unexpected plaintext credentials %+v, %v", creds, xe)
+	}
+}
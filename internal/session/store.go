@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/zx06/xsql/internal/db"
 )
@@ -11,6 +12,8 @@ import (
 type DatasetEntry struct {
 	ID          string          `json:"id"`
 	Description string          `json:"description"`
+	Profile     string          `json:"profile,omitempty"`
+	Duration    time.Duration   `json:"duration,omitempty"`
 	Result      *db.QueryResult `json:"result"`
 }
 
@@ -29,6 +32,11 @@ func NewSessionDataStore() *SessionDataStore {
 }
 
 func (s *SessionDataStore) Save(description string, result *db.QueryResult) string {
+	return s.SaveQuery(description, "", 0, result)
+}
+
+// SaveQuery stores a query result together with the profile it ran against and its execution time.
+func (s *SessionDataStore) SaveQuery(description, profile string, duration time.Duration, result *db.QueryResult) string {
 	if result == nil {
 		return ""
 	}
@@ -41,6 +49,8 @@ func (s *SessionDataStore) Save(description string, result *db.QueryResult) stri
 	entry := &DatasetEntry{
 		ID:          id,
 		Description: strings.TrimSpace(description),
+		Profile:     profile,
+		Duration:    duration,
 		Result:      result,
 	}
 
@@ -60,6 +70,18 @@ func (s *SessionDataStore) Get(id string) (*db.QueryResult, bool) {
 	return entry.Result, true
 }
 
+// GetEntry returns the dataset entry including its metadata.
+func (s *SessionDataStore) GetEntry(id string) (*DatasetEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.datasets[id]
+	if !ok || entry == nil {
+		return nil, false
+	}
+	return entry, true
+}
+
 func (s *SessionDataStore) Latest() (*db.QueryResult, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
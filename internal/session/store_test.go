@@ -3,6 +3,7 @@ package session
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/zx06/xsql/internal/db"
 )
@@ -52,3 +53,20 @@ func TestSessionDataStore(t *testing.T) {
 		t.Fatalf("expected GetAll to return 2 datasets, got %d", len(all))
 	}
 }
+
+func TestSessionDataStore_SaveQueryMetadata(t *testing.T) {
+	store := NewSessionDataStore()
+	res := &db.QueryResult{Columns: []string{"id"}, Rows: []map[string]any{{"id": 1}}}
+
+	id := store.SaveQuery(" SELECT 1 ", "prod", 42*time.Millisecond, res)
+	entry, ok := store.GetEntry(id)
+	if !ok {
+		t.Fatalf("expected entry %s", id)
+	}
+	if entry.Description != "SELECT 1" || entry.Profile != "prod" || entry.Duration != 42*time.Millisecond || entry.Result != res {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if _, ok := store.GetEntry("missing"); ok {
+		t.Fatal("expected missing entry")
+	}
+}
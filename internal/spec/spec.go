@@ -20,4 +20,7 @@ type Spec struct {
 	SchemaVersion int           `json:"schema_version" yaml:"schema_version"`
 	Commands      []CommandSpec `json:"commands" yaml:"commands"`
 	ErrorCodes    []errors.Code `json:"error_codes" yaml:"error_codes"`
+
+	// ConfigSchema is the JSON Schema for xsql.yaml.
+	ConfigSchema map[string]any `json:"config_schema,omitempty" yaml:"config_schema,omitempty"`
 }
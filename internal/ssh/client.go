@@ -2,16 +2,20 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
+	stderrors "errors"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/knownhosts"
 
 	"github.com/zx06/xsql/internal/errors"
@@ -20,15 +24,67 @@ import (
 // Client wraps ssh.Client and provides DialContext for use by database drivers.
 type Client struct {
 	client           *ssh.Client
+	jumps            []*ssh.Client // jump host connections, first hop first
 	netConn          net.Conn
 	keepaliveTimeout time.Duration
 	alive            atomic.Bool
 }
 
-// Connect establishes an SSH connection.
+// Connect establishes an SSH connection, through opts.Jump first when set.
 func Connect(ctx context.Context, opts Options) (*Client, *errors.XError) {
+	hops := make([]Options, 0, len(opts.Jump)+1)
+	hops = append(hops, opts.Jump...)
+	hops = append(hops, opts)
+
+	var (
+		clients []*ssh.Client
+		netConn net.Conn
+	)
+	for i, hop := range hops {
+		var via *ssh.Client
+		if i > 0 {
+			via = clients[i-1]
+		}
+		client, conn, xe := connectHop(ctx, hop, via)
+		if xe != nil {
+			for j := len(clients) - 1; j >= 0; j-- {
+				_ = clients[j].Close()
+			}
+			if len(hops) > 1 {
+				xe = withHop(xe, hop, i, len(hops))
+			}
+			return nil, xe
+		}
+		clients = append(clients, client)
+		netConn = conn
+	}
+
+	timeout := 5 * time.Second
+	if opts.KeepaliveInterval > 0 {
+		timeout = opts.KeepaliveInterval * 2
+		if timeout < 500*time.Millisecond {
+			timeout = 500 * time.Millisecond
+		}
+		if timeout > 5*time.Second {
+			timeout = 5 * time.Second
+		}
+	}
+
+	c := &Client{
+		client:           clients[len(clients)-1],
+		jumps:            clients[:len(clients)-1],
+		netConn:          netConn,
+		keepaliveTimeout: timeout,
+	}
+	c.alive.Store(true)
+	return c, nil
+}
+
+// connectHop opens the SSH connection to one host, over a TCP connection made
+// directly or, when via is set, forwarded by the previous hop.
+func connectHop(ctx context.Context, opts Options, via *ssh.Client) (*ssh.Client, net.Conn, *errors.XError) {
 	if opts.Host == "" {
-		return nil, errors.New(errors.CodeCfgInvalid, "ssh host is required", nil)
+		return nil, nil, errors.New(errors.CodeCfgInvalid, "ssh host is required", nil)
 	}
 	if opts.Port == 0 {
 		opts.Port = 22
@@ -40,67 +96,101 @@ func Connect(ctx context.Context, opts Options) (*Client, *errors.XError) {
 		}
 	}
 
-	authMethods, xe := buildAuthMethods(opts)
+	ag, closeAgent, xe := dialAgent(opts)
 	if xe != nil {
-		return nil, xe
+		return nil, nil, xe
+	}
+	defer closeAgent()
+	authMethods, xe := buildAuthMethods(opts, ag)
+	if xe != nil {
+		return nil, nil, xe
 	}
 
 	hostKeyCallback, xe := buildHostKeyCallback(opts)
 	if xe != nil {
-		return nil, xe
+		return nil, nil, xe
 	}
 
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
 	config := &ssh.ClientConfig{
 		User:            opts.User,
 		Auth:            authMethods,
 		HostKeyCallback: hostKeyCallback,
 	}
+	if !opts.SkipKnownHostsCheck {
+		config.HostKeyAlgorithms = hostKeyAlgorithms(knownHostsPath(opts), addr)
+	}
 
-	addr := fmt.Sprintf("%s:%d", opts.Host, opts.Port)
-
-	// Use net.Dialer with context so that context cancellation/timeout
-	// can interrupt the TCP connection phase (ssh.Dial does not accept context).
-	d := net.Dialer{}
-	netConn, err := d.DialContext(ctx, "tcp", addr)
+	// Use a context-aware dial so that context cancellation/timeout can
+	// interrupt the TCP connection phase (ssh.Dial does not accept context).
+	var (
+		netConn net.Conn
+		err     error
+	)
+	if via != nil {
+		netConn, err = via.DialContext(ctx, "tcp", addr)
+	} else {
+		d := net.Dialer{}
+		netConn, err = d.DialContext(ctx, "tcp", addr)
+	}
 	if err != nil {
-		return nil, errors.Wrap(errors.CodeSSHDialFailed, "failed to connect to ssh server", map[string]any{"host": opts.Host}, err)
+		return nil, nil, errors.Wrap(errors.CodeSSHDialFailed, "failed to connect to ssh server", map[string]any{"host": opts.Host}, err)
 	}
 
-	// Perform SSH handshake over the established TCP connection.
-	// Set a deadline derived from context to prevent hanging during handshake.
+	// Perform SSH handshake over the established connection. Later hops run over
+	// an SSH channel, which does not support deadlines, so the connection is also
+	// closed when the context ends to prevent hanging during the handshake.
 	if deadline, ok := ctx.Deadline(); ok {
 		_ = netConn.SetDeadline(deadline)
 	}
+	stop := context.AfterFunc(ctx, func() { _ = netConn.Close() })
 	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
+	if !stop() && err == nil {
+		// The context ended just as the handshake completed.
+		_ = sshConn.Close()
+		err = ctx.Err()
+	}
 	if err != nil {
 		_ = netConn.Close()
-		if strings.Contains(err.Error(), "unable to authenticate") {
-			return nil, errors.Wrap(errors.CodeSSHAuthFailed, "ssh authentication failed", map[string]any{"host": opts.Host}, err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		var (
+			pe  *promptError
+			hke *hostKeyError
+		)
+		if stderrors.As(err, &hke) {
+			return nil, nil, hke.xerror(opts.Host)
+		}
+		if strings.Contains(err.Error(), "unable to authenticate") || stderrors.As(err, &pe) {
+			return nil, nil, errors.Wrap(errors.CodeSSHAuthFailed, "ssh authentication failed", map[string]any{"host": opts.Host}, err)
 		}
-		return nil, errors.Wrap(errors.CodeSSHDialFailed, "failed to connect to ssh server", map[string]any{"host": opts.Host}, err)
+		return nil, nil, errors.Wrap(errors.CodeSSHDialFailed, "failed to connect to ssh server", map[string]any{"host": opts.Host}, err)
 	}
 	// Clear the deadline after successful handshake so it doesn't affect later I/O.
 	_ = netConn.SetDeadline(time.Time{})
 
-	timeout := 5 * time.Second
-	if opts.KeepaliveInterval > 0 {
-		timeout = opts.KeepaliveInterval * 2
-		if timeout < 500*time.Millisecond {
-			timeout = 500 * time.Millisecond
-		}
-		if timeout > 5*time.Second {
-			timeout = 5 * time.Second
-		}
-	}
+	return ssh.NewClient(sshConn, chans, reqs), netConn, nil
+}
 
-	client := ssh.NewClient(sshConn, chans, reqs)
-	c := &Client{
-		client:           client,
-		netConn:          netConn,
-		keepaliveTimeout: timeout,
+// withHop records which host of a jump chain failed.
+func withHop(xe *errors.XError, hop Options, index, total int) *errors.XError {
+	if xe.Details == nil {
+		xe.Details = map[string]any{}
 	}
-	c.alive.Store(true)
-	return c, nil
+	name := hop.Name
+	if name == "" {
+		name = hop.Host
+	}
+	xe.Details["hop"] = name
+	xe.Details["hop_index"] = index + 1
+	xe.Details["hops"] = total
+	role := "jump host"
+	if index == total-1 {
+		role = "target host"
+	}
+	xe.Message = fmt.Sprintf("%s (%s %s, hop %d of %d)", xe.Message, role, name, index+1, total)
+	return xe
 }
 
 // DialContext establishes a connection to the target through the SSH tunnel.
@@ -111,13 +201,17 @@ func (c *Client) DialContext(ctx context.Context, network, addr string) (net.Con
 	return c.client.Dial(network, addr)
 }
 
-// Close closes the SSH connection.
+// Close closes the SSH connection and then the jump host connections.
 func (c *Client) Close() error {
 	c.alive.Store(false)
+	var err error
 	if c.client != nil {
-		return c.client.Close()
+		err = c.client.Close()
+	}
+	for i := len(c.jumps) - 1; i >= 0; i-- {
+		_ = c.jumps[i].Close()
 	}
-	return nil
+	return err
 }
 
 // SendKeepalive sends a single SSH keepalive request and returns any error.
@@ -148,8 +242,50 @@ func (c *Client) Alive() bool {
 	return c.alive.Load()
 }
 
-func buildAuthMethods(opts Options) ([]ssh.AuthMethod, *errors.XError) {
-	var methods []ssh.AuthMethod
+// dialAgent connects to the ssh-agent named by opts.IdentityAgent, or by
+// $SSH_AUTH_SOCK when that is empty. An unreachable $SSH_AUTH_SOCK is ignored,
+// as ssh does; an unreachable configured socket is an error. The returned func
+// closes the connection once the handshake no longer needs the agent.
+func dialAgent(opts Options) (agent.Agent, func(), *errors.XError) {
+	sock := opts.IdentityAgent
+	explicit := sock != "" && sock != "SSH_AUTH_SOCK" && sock != "$SSH_AUTH_SOCK"
+	if !explicit {
+		sock = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if sock == "" || sock == "none" {
+		return nil, func() {}, nil
+	}
+	sock = filepath.Clean(expandPath(sock))
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		if explicit {
+			return nil, nil, errors.Wrap(errors.CodeSSHAuthFailed, "failed to connect to ssh agent", map[string]any{"path": sock}, err)
+		}
+		return nil, func() {}, nil
+	}
+	return agent.NewClient(conn), func() { _ = conn.Close() }, nil
+}
+
+// buildAuthMethods returns the publickey, keyboard-interactive and password
+// methods that opts allows. The publickey method offers, in order: user
+// certificates, the configured identity file, the agent's keys and, when no
+// identity file is configured, the first usable default key. They are offered
+// as one method because the client attempts each method type only once.
+func buildAuthMethods(opts Options, ag agent.Agent) ([]ssh.AuthMethod, *errors.XError) {
+	var signers, certs []ssh.Signer
+	// addKey adds a key read from keyPath and, as ssh does, its certificate
+	// <keyPath>-cert.pub when that exists and no certificate is configured.
+	addKey := func(signer ssh.Signer, keyPath string) {
+		signers = append(signers, signer)
+		if opts.CertificateFile != "" {
+			return
+		}
+		if cert, err := readCertificate(keyPath + "-cert.pub"); err == nil {
+			if cs, err := ssh.NewCertSigner(cert, signer); err == nil {
+				certs = append(certs, cs)
+			}
+		}
+	}
 
 	// Private key authentication
 	if opts.IdentityFile != "" {
@@ -174,11 +310,18 @@ func buildAuthMethods(opts Options) ([]ssh.AuthMethod, *errors.XError) {
 		if err != nil {
 			return nil, errors.Wrap(errors.CodeSSHAuthFailed, "failed to parse ssh private key", nil, err)
 		}
-		methods = append(methods, ssh.PublicKeys(signer))
+		addKey(signer, absPath)
+	}
+
+	// ssh-agent keys (an agent lists loaded certificates itself)
+	if ag != nil {
+		if agentSigners, err := ag.Signers(); err == nil {
+			signers = append(signers, agentSigners...)
+		}
 	}
 
 	// Try default private key paths
-	if len(methods) == 0 {
+	if opts.IdentityFile == "" {
 		for _, name := range []string{"id_ed25519", "id_rsa", "id_ecdsa"} {
 			keyPath := filepath.Clean(expandPath("~/.ssh/" + name))
 			if keyData, err := os.ReadFile(keyPath); err == nil {
@@ -189,36 +332,142 @@ func buildAuthMethods(opts Options) ([]ssh.AuthMethod, *errors.XError) {
 					signer, err = ssh.ParsePrivateKey(keyData)
 				}
 				if err == nil {
-					methods = append(methods, ssh.PublicKeys(signer))
+					addKey(signer, keyPath)
 					break
 				}
 			}
 		}
 	}
 
+	// A configured certificate is used with whichever key it was issued for.
+	if opts.CertificateFile != "" {
+		certPath := filepath.Clean(expandPath(opts.CertificateFile))
+		cert, err := readCertificate(certPath)
+		if err != nil {
+			return nil, errors.Wrap(errors.CodeCfgInvalid, "failed to read ssh certificate", map[string]any{"path": certPath}, err)
+		}
+		var certSigner ssh.Signer
+		for _, signer := range signers {
+			if bytes.Equal(signer.PublicKey().Marshal(), cert.Key.Marshal()) {
+				if certSigner, err = ssh.NewCertSigner(cert, signer); err != nil {
+					return nil, errors.Wrap(errors.CodeCfgInvalid, "invalid ssh certificate", map[string]any{"path": certPath}, err)
+				}
+				break
+			}
+		}
+		if certSigner == nil {
+			return nil, errors.New(errors.CodeCfgInvalid, "ssh certificate does not match the identity file, an agent key or a default key", map[string]any{"path": certPath})
+		}
+		certs = append(certs, certSigner)
+	}
+
+	var methods []ssh.AuthMethod
+	if signers = append(certs, signers...); len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if opts.Password != "" || opts.KeyboardInteractive != nil {
+		methods = append(methods, ssh.KeyboardInteractive(keyboardInteractive(opts)))
+	}
+	if opts.Password != "" {
+		methods = append(methods, ssh.Password(opts.Password))
+	}
 	if len(methods) == 0 {
-		return nil, errors.New(errors.CodeSSHAuthFailed, "no ssh authentication method available", nil)
+		return nil, errors.New(errors.CodeSSHAuthFailed, "no ssh authentication method available (no identity file, ssh-agent key, default key or password)", nil)
 	}
 	return methods, nil
 }
 
+// readCertificate reads an OpenSSH user certificate (a *-cert.pub file).
+func readCertificate(path string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(path) // nolint:gosec // user configured certificate
+	if err != nil {
+		return nil, err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, err
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("not an ssh user certificate")
+	}
+	return cert, nil
+}
+
+// keyboardInteractive answers hidden password prompts with opts.Password and
+// passes any other prompts (such as one-time passwords) to
+// opts.KeyboardInteractive.
+func keyboardInteractive(opts Options) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		var rest []int
+		for i, q := range questions {
+			if opts.Password != "" && !echos[i] && strings.Contains(strings.ToLower(q), "password") {
+				answers[i] = opts.Password
+				continue
+			}
+			rest = append(rest, i)
+		}
+		if len(rest) == 0 {
+			return answers, nil
+		}
+		if opts.KeyboardInteractive == nil {
+			return nil, &promptError{msg: fmt.Sprintf("server prompted %q; enable keyboard_interactive to answer on the terminal", strings.TrimSpace(questions[rest[0]]))}
+		}
+		qs := make([]string, len(rest))
+		es := make([]bool, len(rest))
+		for k, i := range rest {
+			qs[k], es[k] = questions[i], echos[i]
+		}
+		got, err := opts.KeyboardInteractive(name, instruction, qs, es)
+		if err != nil {
+			return nil, err
+		}
+		if len(got) != len(rest) {
+			return nil, &promptError{msg: "wrong number of keyboard-interactive answers"}
+		}
+		for k, i := range rest {
+			answers[i] = got[k]
+		}
+		return answers, nil
+	}
+}
+
+// buildHostKeyCallback verifies host keys against the known_hosts file. With
+// opts.AcceptNewHostKey, keys of hosts that have no entry are recorded instead
+// of rejected; a changed key is always rejected.
 func buildHostKeyCallback(opts Options) (ssh.HostKeyCallback, *errors.XError) {
 	if opts.SkipKnownHostsCheck {
 		return ssh.InsecureIgnoreHostKey(), nil
 	}
-	khPath := opts.KnownHostsFile
-	if khPath == "" {
-		khPath = "~/.ssh/known_hosts"
+	khPath := knownHostsPath(opts)
+	if opts.AcceptNewHostKey {
+		if err := createKnownHosts(khPath); err != nil {
+			return nil, errors.Wrap(errors.CodeSSHHostKeyMismatch, "failed to create known_hosts", map[string]any{"path": khPath}, err)
+		}
 	}
-	khPath = filepath.Clean(expandPath(khPath))
 	cb, err := knownhosts.New(khPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, errors.New(errors.CodeSSHHostKeyMismatch, "known_hosts file not found; use --ssh-skip-known-hosts-check to bypass (not recommended)", map[string]any{"path": khPath})
+			return nil, errors.New(errors.CodeSSHHostKeyMismatch, "known_hosts file not found; record the host key with `xsql ssh trust` or set strict_host_key_checking: accept-new", map[string]any{"path": khPath})
 		}
 		return nil, errors.Wrap(errors.CodeSSHHostKeyMismatch, "failed to parse known_hosts", map[string]any{"path": khPath}, err)
 	}
-	return cb, nil
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		var ke *knownhosts.KeyError
+		unknown := stderrors.As(err, &ke) && len(ke.Want) == 0
+		if unknown && opts.AcceptNewHostKey {
+			if err := appendKnownHost(khPath, hostname, key); err != nil {
+				return &hostKeyError{path: khPath, key: key, err: fmt.Errorf("failed to record host key: %w", err)}
+			}
+			return nil
+		}
+		return &hostKeyError{path: khPath, key: key, unknown: unknown, changed: ke != nil && len(ke.Want) > 0, err: err}
+	}, nil
 }
 
 func expandPath(p string) string {
@@ -1,7 +1,9 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -16,6 +18,7 @@ import (
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/knownhosts"
 
 	"github.com/zx06/xsql/internal/errors"
@@ -164,7 +167,7 @@ func TestBuildAuthMethods_WithInvalidKeyFile(t *testing.T) {
 		IdentityFile: "/nonexistent/id_rsa",
 	}
 
-	_, xe := buildAuthMethods(opts)
+	_, xe := buildAuthMethods(opts, nil)
 	if xe == nil {
 		t.Fatal("expected error for non-existent key file")
 	}
@@ -233,7 +236,7 @@ func TestBuildAuthMethods_WithIdentityFile(t *testing.T) {
 		IdentityFile: keyPath,
 	}
 
-	methods, xe := buildAuthMethods(opts)
+	methods, xe := buildAuthMethods(opts, nil)
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -255,7 +258,7 @@ func TestBuildAuthMethods_DefaultKeyLookup(t *testing.T) {
 	t.Setenv("USERPROFILE", tmpDir)
 
 	opts := Options{}
-	methods, xe := buildAuthMethods(opts)
+	methods, xe := buildAuthMethods(opts, nil)
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -411,7 +414,7 @@ func TestBuildAuthMethods_WithPassphrase(t *testing.T) {
 		Passphrase:   "testpassphrase",
 	}
 
-	methods, xe := buildAuthMethods(opts)
+	methods, xe := buildAuthMethods(opts, nil)
 	if xe != nil {
 		t.Fatalf("unexpected error: %v", xe)
 	}
@@ -428,7 +431,7 @@ func TestBuildAuthMethods_WithWrongPassphrase(t *testing.T) {
 		Passphrase:   "wrongpassphrase",
 	}
 
-	methods, xe := buildAuthMethods(opts)
+	methods, xe := buildAuthMethods(opts, nil)
 	if xe == nil {
 		t.Fatal("expected error for wrong passphrase")
 	}
@@ -645,3 +648,400 @@ func TestClient_DialContext_RealSSHTunnel(t *testing.T) {
 		t.Errorf("echo mismatch: got %q, want %q", buf, msg)
 	}
 }
+
+func TestConnect_JumpChain(t *testing.T) {
+	echoLn := startEchoServer(t)
+
+	// bastion forwards to target, target forwards to the echo server.
+	target := newTestSSHServer(t)
+	target.mu.Lock()
+	target.onDirectTCPIP = func(destHost string, destPort uint32) (net.Conn, error) {
+		return net.Dial("tcp", echoLn.Addr().String())
+	}
+	target.mu.Unlock()
+	bastion := newTestSSHServer(t)
+	forwarded := make(chan string, 1)
+	bastion.mu.Lock()
+	bastion.onDirectTCPIP = func(destHost string, destPort uint32) (net.Conn, error) {
+		addr := net.JoinHostPort(destHost, fmt.Sprint(destPort))
+		forwarded <- addr
+		return net.Dial("tcp", addr)
+	}
+	bastion.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hop := connectToTestServer(bastion)
+	hop.Name = "bastion"
+	opts := connectToTestServer(target)
+	opts.Name = "target"
+	opts.Jump = []Options{hop}
+
+	client, xe := Connect(ctx, opts)
+	if xe != nil {
+		t.Fatalf("connect failed: %v", xe)
+	}
+	defer client.Close()
+	if got := <-forwarded; got != target.Addr() {
+		t.Errorf("bastion forwarded to %q, want %q", got, target.Addr())
+	}
+	if len(client.jumps) != 1 {
+		t.Fatalf("expected 1 jump connection, got %d", len(client.jumps))
+	}
+
+	conn, err := client.DialContext(ctx, "tcp", echoLn.Addr().String())
+	if err != nil {
+		t.Fatalf("dial through jump chain failed: %v", err)
+	}
+	defer conn.Close()
+	msg := []byte("hello-jump")
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != string(msg) {
+		t.Errorf("echo = %q, %v", buf, err)
+	}
+}
+
+func TestConnect_JumpChainReportsFailingHop(t *testing.T) {
+	bastion := newTestSSHServer(t)
+	bastion.mu.Lock()
+	bastion.onDirectTCPIP = func(destHost string, destPort uint32) (net.Conn, error) {
+		return net.Dial("tcp", net.JoinHostPort(destHost, fmt.Sprint(destPort)))
+	}
+	bastion.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hop := connectToTestServer(bastion)
+	hop.Name = "bastion"
+
+	// The target's host key is not in its (empty) known_hosts file.
+	target := newTestSSHServer(t)
+	opts := connectToTestServer(target)
+	opts.Name = "db-gw"
+	opts.SkipKnownHostsCheck = false
+	opts.KnownHostsFile = filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(opts.KnownHostsFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts.Jump = []Options{hop}
+
+	_, xe := Connect(ctx, opts)
+	if xe == nil {
+		t.Fatal("expected error")
+	}
+	if xe.Details["hop"] != "db-gw" || xe.Details["hop_index"] != 2 || xe.Details["hops"] != 2 {
+		t.Errorf("details = %v", xe.Details)
+	}
+	if !strings.Contains(xe.Message, "target host db-gw, hop 2 of 2") {
+		t.Errorf("message = %q", xe.Message)
+	}
+
+	// An unreachable first hop is reported as such.
+	bad := hop
+	bad.Port = 1
+	opts.Jump = []Options{bad}
+	_, xe = Connect(ctx, opts)
+	if xe == nil || xe.Code != errors.CodeSSHDialFailed || xe.Details["hop"] != "bastion" || xe.Details["hop_index"] != 1 {
+		t.Errorf("unreachable hop: %v %v", xe, xe.Details)
+	}
+}
+
+func TestConnect_JumpChainContextTimeout_StalledHop(t *testing.T) {
+	// The second hop accepts TCP but never speaks SSH; it is reached over an
+	// SSH channel, which has no deadlines.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	bastion := newTestSSHServer(t)
+	bastion.mu.Lock()
+	bastion.onDirectTCPIP = func(destHost string, destPort uint32) (net.Conn, error) {
+		return net.Dial("tcp", ln.Addr().String())
+	}
+	bastion.mu.Unlock()
+
+	hop := connectToTestServer(bastion)
+	hop.Name = "bastion"
+	opts := hop
+	opts.Name = "stalled"
+	opts.Host, opts.Port = parseHostPort(ln.Addr().String())
+	opts.Jump = []Options{hop}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, xe := Connect(ctx, opts)
+	elapsed := time.Since(start)
+
+	if xe == nil {
+		t.Fatal("expected error on timeout")
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("expected timeout within ~200ms, took %v", elapsed)
+	}
+	if xe.Details["hop"] != "stalled" || xe.Details["hop_index"] != 2 {
+		t.Errorf("details = %v", xe.Details)
+	}
+}
+
+func TestConnect_AgentAuth(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	want := signer.PublicKey().Marshal()
+	srv := newTestSSHServer(t, func(cfg *ssh.ServerConfig) {
+		cfg.NoClientAuth = false
+		cfg.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(want) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key")
+		}
+	})
+	host, port := srv.HostPort()
+
+	// No default keys: only the agent can authenticate.
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	opts := Options{Host: host, Port: port, User: "test", SkipKnownHostsCheck: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, xe := Connect(ctx, opts)
+	if xe != nil {
+		t.Fatalf("connect with SSH_AUTH_SOCK: %v", xe)
+	}
+	client.Close()
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	opts.IdentityAgent = sock
+	client, xe = Connect(ctx, opts)
+	if xe != nil {
+		t.Fatalf("connect with identity agent: %v", xe)
+	}
+	client.Close()
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	opts.IdentityAgent = "none"
+	if _, xe := Connect(ctx, opts); xe == nil || xe.Code != errors.CodeSSHAuthFailed {
+		t.Errorf("agent disabled: got %v, want SSHAuthFailed", xe)
+	}
+
+	opts.IdentityAgent = filepath.Join(t.TempDir(), "missing.sock")
+	if _, xe := Connect(ctx, opts); xe == nil || xe.Code != errors.CodeSSHAuthFailed || xe.Details["path"] == nil {
+		t.Errorf("unreachable agent: got %v", xe)
+	}
+}
+
+func TestConnect_PasswordAndKeyboardInteractive(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	t.Setenv("SSH_AUTH_SOCK", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pwSrv := newTestSSHServer(t, func(cfg *ssh.ServerConfig) {
+		cfg.NoClientAuth = false
+		cfg.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == "s3cret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("wrong password")
+		}
+	})
+	// An OTP bastion: a password prompt followed by a verification code.
+	otpSrv := newTestSSHServer(t, func(cfg *ssh.ServerConfig) {
+		cfg.NoClientAuth = false
+		cfg.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("", "", []string{"Password: ", "Verification code: "}, []bool{false, true})
+			if err != nil {
+				return nil, err
+			}
+			if answers[0] != "s3cret" || answers[1] != "123456" {
+				return nil, fmt.Errorf("denied")
+			}
+			return nil, nil
+		}
+	})
+	optsFor := func(srv *testSSHServer) Options {
+		host, port := srv.HostPort()
+		return Options{Host: host, Port: port, User: "test", SkipKnownHostsCheck: true, IdentityAgent: "none"}
+	}
+
+	opts := optsFor(pwSrv)
+	opts.Password = "s3cret"
+	client, xe := Connect(ctx, opts)
+	if xe != nil {
+		t.Fatalf("password auth: %v", xe)
+	}
+	client.Close()
+	opts.Password = "wrong"
+	if _, xe := Connect(ctx, opts); xe == nil || xe.Code != errors.CodeSSHAuthFailed {
+		t.Errorf("wrong password: got %v, want SSHAuthFailed", xe)
+	}
+
+	var asked []string
+	opts = optsFor(otpSrv)
+	opts.Password = "s3cret"
+	opts.KeyboardInteractive = func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		asked = append(asked, questions...)
+		return []string{"123456"}, nil
+	}
+	client, xe = Connect(ctx, opts)
+	if xe != nil {
+		t.Fatalf("keyboard-interactive auth: %v", xe)
+	}
+	client.Close()
+	// The password prompt is answered from Password; only the OTP is asked.
+	if strings.Join(asked, "|") != "Verification code: " {
+		t.Errorf("prompted for %q", asked)
+	}
+
+	opts.KeyboardInteractive = nil
+	if _, xe := Connect(ctx, opts); xe == nil || xe.Code != errors.CodeSSHAuthFailed {
+		t.Errorf("unanswered OTP: got %v, want SSHAuthFailed", xe)
+	}
+}
+
+func TestConnect_UserCertificate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	t.Setenv("SSH_AUTH_SOCK", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ca := newTestSigner(t)
+	srv := newTestSSHServer(t, func(cfg *ssh.ServerConfig) {
+		checker := &ssh.CertChecker{IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), ca.PublicKey().Marshal())
+		}}
+		cfg.NoClientAuth = false
+		cfg.PublicKeyCallback = checker.Authenticate
+	})
+	host, port := srv.HostPort()
+
+	dir := t.TempDir()
+	keyPath := writeTempKey(t)
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := signTestCert(t, ca, signer.PublicKey(), ssh.UserCert, "test")
+	certPath := filepath.Join(dir, "user-cert.pub")
+	if err := os.WriteFile(certPath, ssh.MarshalAuthorizedKey(cert), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{Host: host, Port: port, User: "test", IdentityFile: keyPath, SkipKnownHostsCheck: true, IdentityAgent: "none"}
+	if _, xe := Connect(ctx, opts); xe == nil || xe.Code != errors.CodeSSHAuthFailed {
+		t.Fatalf("without certificate: got %v, want SSHAuthFailed", xe)
+	}
+
+	opts.CertificateFile = certPath
+	client, xe := Connect(ctx, opts)
+	if xe != nil {
+		t.Fatalf("certificate_file: %v", xe)
+	}
+	client.Close()
+
+	// <key>-cert.pub next to the key is picked up like ssh does.
+	if err := os.WriteFile(keyPath+"-cert.pub", ssh.MarshalAuthorizedKey(cert), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts.CertificateFile = ""
+	client, xe = Connect(ctx, opts)
+	if xe != nil {
+		t.Fatalf("implicit certificate: %v", xe)
+	}
+	client.Close()
+
+	other := signTestCert(t, ca, newTestSigner(t).PublicKey(), ssh.UserCert, "test")
+	otherPath := filepath.Join(dir, "other-cert.pub")
+	if err := os.WriteFile(otherPath, ssh.MarshalAuthorizedKey(other), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts.CertificateFile = otherPath
+	if _, xe := buildAuthMethods(opts, nil); xe == nil || xe.Code != errors.CodeCfgInvalid {
+		t.Errorf("mismatched certificate: got %v, want CfgInvalid", xe)
+	}
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func signTestCert(t *testing.T, ca ssh.Signer, key ssh.PublicKey, certType uint32, principal string) *ssh.Certificate {
+	t.Helper()
+	cert := &ssh.Certificate{
+		Key:             key,
+		CertType:        certType,
+		KeyId:           "test",
+		ValidPrincipals: []string{principal},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -0,0 +1,335 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" // nolint:gosec // known_hosts hashes host names with HMAC-SHA1
+	"encoding/base64"
+	stderrors "errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/zx06/xsql/internal/errors"
+	"github.com/zx06/xsql/internal/sshconfig"
+)
+
+// Host key states reported by ScanHostKey.
+const (
+	HostKeyKnown   = "known"   // recorded in known_hosts
+	HostKeyUnknown = "unknown" // the host has no entry in known_hosts
+	HostKeyChanged = "changed" // known_hosts records a different key
+	HostKeyAdded   = "added"   // recorded by TrustHostKey
+)
+
+// hostKeyError is a failed host key check; the handshake fails with
+// CodeSSHHostKeyMismatch.
+type hostKeyError struct {
+	path    string
+	key     ssh.PublicKey
+	unknown bool // the host has no entry in known_hosts
+	changed bool // known_hosts records a different key
+	err     error
+}
+
+func (e *hostKeyError) Error() string { return e.err.Error() }
+
+func (e *hostKeyError) Unwrap() error { return e.err }
+
+func (e *hostKeyError) xerror(host string) *errors.XError {
+	msg := "ssh host key verification failed"
+	switch {
+	case e.unknown:
+		msg = "ssh host key is not in known_hosts; check and record it with `xsql ssh trust` or set strict_host_key_checking: accept-new"
+	case e.changed:
+		msg = "ssh host key does not match known_hosts (it may have been replaced, or the connection intercepted)"
+	}
+	return errors.Wrap(errors.CodeSSHHostKeyMismatch, msg, map[string]any{
+		"host":        host,
+		"key_type":    e.key.Type(),
+		"fingerprint": ssh.FingerprintSHA256(e.key),
+		"known_hosts": e.path,
+	}, e.err)
+}
+
+// knownHostsMu serializes known_hosts updates within the process.
+var knownHostsMu sync.Mutex
+
+// createKnownHosts creates an empty known_hosts file, and its directory, when
+// path does not exist.
+func createKnownHosts(path string) error {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return nil
+	}
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o600) // nolint:gosec // user known_hosts file
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// appendKnownHost records key for addr (host:port) in the known_hosts file at
+// path, creating the file when needed.
+func appendKnownHost(path, addr string, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600) // nolint:gosec // user known_hosts file
+	if err != nil {
+		return err
+	}
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, key) + "\n"
+	// Start a new line when the file does not end with one.
+	if st, err := f.Stat(); err == nil && st.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, st.Size()-1); err == nil && last[0] != '\n' {
+			line = "\n" + line
+		}
+	}
+	if _, err := f.WriteString(line); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// knownHostsPath returns the known_hosts file used for opts.
+func knownHostsPath(opts Options) string {
+	p := opts.KnownHostsFile
+	if p == "" {
+		p = DefaultKnownHostsPath()
+	}
+	return filepath.Clean(expandPath(p))
+}
+
+// hostKeyAlgorithms returns the host key algorithms to offer to addr, based on
+// its entries in the known_hosts file at path. By default a server with a host
+// certificate presents the certificate first, which cannot be verified unless
+// a @cert-authority line covers the host. So certificate algorithms are only
+// offered (first, with plain keys as fallback) when such a line exists.
+// Otherwise the types of the host's recorded keys are offered, or every plain
+// key type for a host with no entries.
+func hostKeyAlgorithms(path, addr string) []string {
+	var (
+		hasCA bool
+		known []string
+	)
+	if data, err := os.ReadFile(path); err == nil { // nolint:gosec // user known_hosts file
+		host := knownhosts.Normalize(addr)
+		for len(data) > 0 {
+			marker, hosts, key, _, rest, err := ssh.ParseKnownHosts(data)
+			if err != nil {
+				break
+			}
+			data = rest
+			if !matchKnownHost(hosts, host) {
+				continue
+			}
+			switch marker {
+			case "cert-authority":
+				hasCA = true
+			case "":
+				known = append(known, key.Type())
+			}
+		}
+	}
+	if hasCA {
+		return nil
+	}
+
+	var algos []string
+	seen := map[string]bool{}
+	add := func(a string) {
+		if !seen[a] {
+			seen[a] = true
+			algos = append(algos, a)
+		}
+	}
+	if len(known) == 0 {
+		for _, a := range ssh.SupportedAlgorithms().HostKeys {
+			if !strings.Contains(a, "-cert-") {
+				add(a)
+			}
+		}
+		return algos
+	}
+	for _, t := range known {
+		if t == ssh.KeyAlgoRSA {
+			// RSA keys are used with the SHA-2 signature algorithms.
+			add(ssh.KeyAlgoRSASHA512)
+			add(ssh.KeyAlgoRSASHA256)
+		}
+		add(t)
+	}
+	return algos
+}
+
+// matchKnownHost reports whether host (as normalized by knownhosts.Normalize)
+// matches the host patterns of a known_hosts line: plain, wildcard, negated
+// (!pattern) or hashed (|1|salt|hash) entries.
+func matchKnownHost(patterns []string, host string) bool {
+	matched := false
+	for _, p := range patterns {
+		neg := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		var ok bool
+		if strings.HasPrefix(p, "|1|") {
+			ok = matchHashedHost(p, host)
+		} else {
+			ok = sshconfig.MatchPattern(p, host)
+		}
+		if ok && neg {
+			return false
+		}
+		matched = matched || ok
+	}
+	return matched
+}
+
+func matchHashedHost(entry, host string) bool {
+	parts := strings.Split(entry, "|")
+	if len(parts) != 4 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(host))
+	return bytes.Equal(mac.Sum(nil), want)
+}
+
+// HostKey is the host key a server presents, and its state in known_hosts.
+type HostKey struct {
+	Name           string // the host's ssh_proxies entry, or Host
+	Host           string
+	Port           int
+	Type           string // key type, e.g. ssh-ed25519
+	Fingerprint    string // SHA256 fingerprint, as shown by ssh-keygen -l
+	Status         string // HostKeyKnown, HostKeyUnknown, HostKeyChanged or HostKeyAdded
+	KnownHostsFile string
+
+	addr string
+	key  ssh.PublicKey
+}
+
+// errHostKeyScanned ends the handshake of ScanHostKey once the key is read.
+var errHostKeyScanned = stderrors.New("host key scanned")
+
+// ScanHostKey reads the host key of opts.Host, without authenticating, and
+// checks it against the known_hosts file. opts.Jump hosts are connected to
+// first, so their keys must already be trusted.
+func ScanHostKey(ctx context.Context, opts Options) (*HostKey, *errors.XError) {
+	if opts.Host == "" {
+		return nil, errors.New(errors.CodeCfgInvalid, "ssh host is required", nil)
+	}
+	if opts.Port == 0 {
+		opts.Port = 22
+	}
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+
+	var (
+		netConn net.Conn
+		err     error
+	)
+	if n := len(opts.Jump); n > 0 {
+		last := opts.Jump[n-1]
+		last.Jump = opts.Jump[:n-1]
+		via, xe := Connect(ctx, last)
+		if xe != nil {
+			return nil, xe
+		}
+		defer via.Close()
+		netConn, err = via.DialContext(ctx, "tcp", addr)
+	} else {
+		d := net.Dialer{}
+		netConn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, errors.Wrap(errors.CodeSSHDialFailed, "failed to connect to ssh server", map[string]any{"host": opts.Host}, err)
+	}
+	defer netConn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = netConn.SetDeadline(deadline)
+	}
+
+	path := knownHostsPath(opts)
+	var key ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User: opts.User,
+		HostKeyCallback: func(_ string, _ net.Addr, k ssh.PublicKey) error {
+			key = k
+			return errHostKeyScanned
+		},
+		HostKeyAlgorithms: hostKeyAlgorithms(path, addr),
+	}
+	if _, _, _, err := ssh.NewClientConn(netConn, addr, config); key == nil {
+		return nil, errors.Wrap(errors.CodeSSHDialFailed, "failed to read ssh host key", map[string]any{"host": opts.Host}, err)
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = opts.Host
+	}
+	hk := &HostKey{
+		Name:           name,
+		Host:           opts.Host,
+		Port:           opts.Port,
+		Type:           key.Type(),
+		Fingerprint:    ssh.FingerprintSHA256(key),
+		Status:         HostKeyUnknown,
+		KnownHostsFile: path,
+		addr:           addr,
+		key:            key,
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return hk, nil
+	}
+	cb, err := knownhosts.New(path)
+	if err != nil {
+		return nil, errors.Wrap(errors.CodeSSHHostKeyMismatch, "failed to parse known_hosts", map[string]any{"path": path}, err)
+	}
+	err = cb(addr, netConn.RemoteAddr(), key)
+	var ke *knownhosts.KeyError
+	switch {
+	case err == nil:
+		hk.Status = HostKeyKnown
+	case stderrors.As(err, &ke) && len(ke.Want) > 0:
+		hk.Status = HostKeyChanged
+	case ke == nil:
+		return nil, (&hostKeyError{path: path, key: key, err: err}).xerror(opts.Host)
+	}
+	return hk, nil
+}
+
+// TrustHostKey records an unknown host key found by ScanHostKey in its
+// known_hosts file.
+func TrustHostKey(hk *HostKey) *errors.XError {
+	if hk.Status != HostKeyUnknown {
+		return errors.New(errors.CodeInternal, "only unknown host keys can be trusted", map[string]any{"host": hk.Host, "status": hk.Status})
+	}
+	if err := appendKnownHost(hk.KnownHostsFile, hk.addr, hk.key); err != nil {
+		return errors.Wrap(errors.CodeInternal, "failed to record host key", map[string]any{"path": hk.KnownHostsFile}, err)
+	}
+	hk.Status = HostKeyAdded
+	return nil
+}
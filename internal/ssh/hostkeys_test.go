@@ -0,0 +1,224 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/zx06/xsql/internal/errors"
+)
+
+func TestHostKeyAlgorithms(t *testing.T) {
+	ca := newTestSigner(t)
+	ed := newTestSigner(t)
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	lines := []string{
+		"# comment",
+		"@cert-authority *.corp.example " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(ca.PublicKey()))),
+		knownhosts.Line([]string{knownhosts.HashHostname("hashed.example")}, ed.PublicKey()),
+		knownhosts.Line([]string{"plain.example", "[plain.example]:2222", "!legacy.corp.example"}, ed.PublicKey()),
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr string
+		want string // "" = default algorithms (certificates first)
+	}{
+		{"db.corp.example:22", ""},
+		{"hashed.example:22", ssh.KeyAlgoED25519},
+		{"plain.example:2222", ssh.KeyAlgoED25519},
+	}
+	for _, tt := range tests {
+		got := hostKeyAlgorithms(path, tt.addr)
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("hostKeyAlgorithms(%s) = %v, want %q", tt.addr, got, tt.want)
+		}
+	}
+
+	// Unknown hosts are offered plain keys only.
+	for _, a := range hostKeyAlgorithms(path, "unknown.example:22") {
+		if strings.Contains(a, "-cert-") {
+			t.Errorf("unknown host offered %s", a)
+		}
+	}
+	if matchKnownHost([]string{"*.corp.example", "!legacy.corp.example"}, "legacy.corp.example") {
+		t.Error("negated pattern matched")
+	}
+}
+
+func TestConnect_HostCertificate(t *testing.T) {
+	ca := newTestSigner(t)
+	hostCertKey := newTestSigner(t)
+	var hostCert *ssh.Certificate
+	srv := newTestSSHServer(t, func(cfg *ssh.ServerConfig) {
+		hostCert = signTestCert(t, ca, hostCertKey.PublicKey(), ssh.HostCert, "127.0.0.1")
+		certSigner, err := ssh.NewCertSigner(hostCert, hostCertKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.AddHostKey(certSigner)
+	})
+	host, port := srv.HostPort()
+	pattern := fmt.Sprintf("[%s]:%d", host, port)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	connect := func(knownHosts string) *errors.XError {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "known_hosts")
+		if err := os.WriteFile(path, []byte(knownHosts+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		opts := connectToTestServer(srv)
+		opts.SkipKnownHostsCheck = false
+		opts.KnownHostsFile = path
+		client, xe := Connect(ctx, opts)
+		if xe == nil {
+			client.Close()
+		}
+		return xe
+	}
+
+	// The host certificate is verified by the @cert-authority line.
+	if xe := connect("@cert-authority " + pattern + " " + string(ssh.MarshalAuthorizedKey(ca.PublicKey()))); xe != nil {
+		t.Errorf("cert-authority: %v", xe)
+	}
+	// With only the plain host key recorded, that key is negotiated instead of
+	// the certificate the server prefers.
+	if xe := connect(knownhosts.Line([]string{pattern}, srv.hostKey.PublicKey())); xe != nil {
+		t.Errorf("plain host key: %v", xe)
+	}
+	// A certificate from another authority is rejected.
+	other := newTestSigner(t)
+	if xe := connect("@cert-authority " + pattern + " " + string(ssh.MarshalAuthorizedKey(other.PublicKey()))); xe == nil {
+		t.Error("expected untrusted certificate authority to be rejected")
+	}
+}
+
+func TestConnect_AcceptNewHostKey(t *testing.T) {
+	srv := newTestSSHServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+
+	connect := func(acceptNew bool) *errors.XError {
+		t.Helper()
+		opts := connectToTestServer(srv)
+		opts.SkipKnownHostsCheck = false
+		opts.KnownHostsFile = path
+		opts.AcceptNewHostKey = acceptNew
+		client, xe := Connect(ctx, opts)
+		if xe == nil {
+			client.Close()
+		}
+		return xe
+	}
+
+	// Unknown hosts are rejected unless accept-new is set.
+	if xe := connect(false); xe == nil || xe.Code != errors.CodeSSHHostKeyMismatch {
+		t.Fatalf("missing known_hosts: got %v, want %s", xe, errors.CodeSSHHostKeyMismatch)
+	}
+	if xe := connect(true); xe != nil {
+		t.Fatalf("accept-new: %v", xe)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := knownhosts.Line([]string{knownhosts.Normalize(srv.Addr())}, srv.hostKey.PublicKey())
+	if strings.TrimSpace(string(data)) != want {
+		t.Errorf("known_hosts = %q, want %q", data, want)
+	}
+	// The recorded key is used from now on.
+	if xe := connect(false); xe != nil {
+		t.Errorf("recorded key: %v", xe)
+	}
+
+	// A changed key is rejected even with accept-new.
+	other := newTestSigner(t)
+	if err := os.WriteFile(path, []byte(knownhosts.Line([]string{knownhosts.Normalize(srv.Addr())}, other.PublicKey())), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	xe := connect(true)
+	if xe == nil || xe.Code != errors.CodeSSHHostKeyMismatch {
+		t.Fatalf("changed key: got %v, want %s", xe, errors.CodeSSHHostKeyMismatch)
+	}
+	if xe.Details["fingerprint"] != ssh.FingerprintSHA256(srv.hostKey.PublicKey()) {
+		t.Errorf("fingerprint = %v", xe.Details["fingerprint"])
+	}
+	if data, _ := os.ReadFile(path); strings.Count(string(data), "\n") != 0 {
+		t.Errorf("changed key was recorded: %q", data)
+	}
+}
+
+func TestScanAndTrustHostKey(t *testing.T) {
+	target := newTestSSHServer(t)
+	bastion := newTestSSHServer(t)
+	bastion.mu.Lock()
+	bastion.onDirectTCPIP = func(destHost string, destPort uint32) (net.Conn, error) {
+		return net.Dial("tcp", net.JoinHostPort(destHost, fmt.Sprint(destPort)))
+	}
+	bastion.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	// No trailing newline: the new entry must start on its own line.
+	if err := os.WriteFile(path, []byte("# hosts"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts := connectToTestServer(target)
+	opts.Name = "target"
+	opts.SkipKnownHostsCheck = false
+	opts.KnownHostsFile = path
+	opts.Jump = []Options{connectToTestServer(bastion)}
+
+	hk, xe := ScanHostKey(ctx, opts)
+	if xe != nil {
+		t.Fatalf("scan: %v", xe)
+	}
+	if hk.Name != "target" || hk.Status != HostKeyUnknown || hk.Fingerprint != ssh.FingerprintSHA256(target.hostKey.PublicKey()) {
+		t.Fatalf("scan = %+v", hk)
+	}
+	if xe := TrustHostKey(hk); xe != nil {
+		t.Fatalf("trust: %v", xe)
+	}
+	if hk.Status != HostKeyAdded {
+		t.Errorf("status = %s, want %s", hk.Status, HostKeyAdded)
+	}
+
+	hk, xe = ScanHostKey(ctx, opts)
+	if xe != nil {
+		t.Fatalf("rescan: %v", xe)
+	}
+	if hk.Status != HostKeyKnown {
+		t.Errorf("rescan status = %s, want %s", hk.Status, HostKeyKnown)
+	}
+	if xe := TrustHostKey(hk); xe == nil {
+		t.Error("expected known key to be refused")
+	}
+	opts.Jump = nil
+	client, xe := Connect(ctx, opts)
+	if xe != nil {
+		t.Fatalf("connect with trusted key: %v", xe)
+	}
+	client.Close()
+
+	// Another server on the same address presents a changed key.
+	if err := os.WriteFile(path, []byte(knownhosts.Line([]string{knownhosts.Normalize(target.Addr())}, bastion.hostKey.PublicKey())+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if hk, xe = ScanHostKey(ctx, opts); xe != nil || hk.Status != HostKeyChanged {
+		t.Errorf("changed key: %+v, %v", hk, xe)
+	}
+}
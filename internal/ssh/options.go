@@ -1,6 +1,10 @@
 package ssh
 
-import "time"
+import (
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
 
 // Options contains the parameters required for an SSH connection.
 type Options struct {
@@ -9,11 +13,40 @@ type Options struct {
 	User           string
 	IdentityFile   string // path to the private key
 	Passphrase     string // private key passphrase (if any)
-	KnownHostsFile string // defaults to ~/.ssh/known_hosts
+	KnownHostsFile string // defaults to ~/.ssh/known_hosts; @cert-authority lines verify host certificates
+
+	// CertificateFile is an OpenSSH user certificate, used with the key it was
+	// issued for. Without it, <key>-cert.pub next to a key file is used.
+	CertificateFile string
+
+	// Password enables password authentication and answers keyboard-interactive
+	// password prompts.
+	Password string
+
+	// KeyboardInteractive answers the keyboard-interactive prompts Password
+	// does not, such as one-time passwords; see TerminalChallenge.
+	KeyboardInteractive ssh.KeyboardInteractiveChallenge
+
+	// IdentityAgent is the ssh-agent socket; empty uses $SSH_AUTH_SOCK and
+	// "none" disables agent authentication.
+	IdentityAgent string
+
+	// AcceptNewHostKey records the keys of hosts missing from known_hosts
+	// instead of rejecting them (OpenSSH StrictHostKeyChecking=accept-new).
+	// Changed keys are still rejected.
+	AcceptNewHostKey bool
 
 	// SkipKnownHostsCheck disables known_hosts verification (strongly discouraged!).
 	SkipKnownHostsCheck bool
 
+	// Name identifies the host in errors (e.g. its ssh_proxies entry); defaults to Host.
+	Name string
+
+	// Jump lists the hosts to connect through first, first hop first (like
+	// OpenSSH ProxyJump). Each hop is authenticated and host-key checked with
+	// its own options; the Jump field of a hop is ignored.
+	Jump []Options
+
 	// KeepaliveInterval is the interval between SSH keepalive probes.
 	// Zero or negative disables keepalive. Default: DefaultKeepaliveInterval.
 	KeepaliveInterval time.Duration
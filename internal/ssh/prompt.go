@@ -0,0 +1,106 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// promptError reports a keyboard-interactive prompt that could not be answered;
+// the handshake fails with CodeSSHAuthFailed.
+type promptError struct {
+	msg string
+}
+
+func (e *promptError) Error() string { return e.msg }
+
+// promptMu keeps concurrent connections from interleaving their prompts.
+var promptMu sync.Mutex
+
+// TerminalChallenge answers keyboard-interactive prompts, such as one-time
+// passwords, on the controlling terminal. It never reads stdin, which may be a
+// pipe or carry a protocol (MCP over stdio), and fails when there is no
+// terminal.
+func TerminalChallenge(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	if len(questions) == 0 {
+		return []string{}, nil
+	}
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	in, out, err := openTerminal()
+	if err != nil {
+		return nil, &promptError{msg: fmt.Sprintf("keyboard-interactive authentication needs a terminal: %v", err)}
+	}
+	defer in.Close()
+	if out != in {
+		defer out.Close()
+	}
+
+	for _, line := range []string{name, instruction} {
+		if line = strings.TrimSpace(line); line != "" {
+			fmt.Fprintln(out, line)
+		}
+	}
+	answers := make([]string, len(questions))
+	for i, q := range questions {
+		fmt.Fprint(out, q)
+		if echos[i] {
+			answers[i], err = readLine(in)
+		} else {
+			var b []byte
+			b, err = term.ReadPassword(int(in.Fd()))
+			fmt.Fprintln(out)
+			answers[i] = string(b)
+		}
+		if err != nil {
+			return nil, &promptError{msg: fmt.Sprintf("failed to read keyboard-interactive answer: %v", err)}
+		}
+	}
+	return answers, nil
+}
+
+func openTerminal() (in, out *os.File, err error) {
+	if runtime.GOOS == "windows" {
+		if in, err = os.OpenFile("CONIN$", os.O_RDWR, 0); err != nil {
+			return nil, nil, err
+		}
+		if out, err = os.OpenFile("CONOUT$", os.O_WRONLY, 0); err != nil {
+			in.Close()
+			return nil, nil, err
+		}
+		return in, out, nil
+	}
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f, nil
+}
+
+// readLine reads one line byte by byte, so nothing past it is consumed from
+// the terminal before a following hidden prompt.
+func readLine(f *os.File) (string, error) {
+	var b strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			b.WriteByte(buf[0])
+		}
+		if err != nil {
+			if b.Len() > 0 {
+				break
+			}
+			return "", err
+		}
+	}
+	return strings.TrimRight(b.String(), "\r"), nil
+}
@@ -43,6 +43,9 @@ type ReconnectDialer struct {
 
 	onStatus func(StatusEvent)
 
+	// connectTimeout bounds each connection attempt; zero relies on ctx alone.
+	connectTimeout time.Duration
+
 	// connectFunc allows injecting a custom connect function for testing.
 	connectFunc func(ctx context.Context, opts Options) (*Client, error)
 
@@ -62,6 +65,14 @@ func WithStatusCallback(fn func(StatusEvent)) ReconnectOption {
 	}
 }
 
+// WithConnectTimeout bounds each connection attempt, the initial one and
+// every reconnect, so a host that accepts TCP but stalls cannot block forever.
+func WithConnectTimeout(d time.Duration) ReconnectOption {
+	return func(rd *ReconnectDialer) {
+		rd.connectTimeout = d
+	}
+}
+
 // NewReconnectDialer creates a ReconnectDialer that automatically reconnects
 // on SSH connection failures. It establishes the initial connection and starts
 // keepalive monitoring.
@@ -325,6 +336,11 @@ func (rd *ReconnectDialer) keepaliveLoop(ctx context.Context, interval time.Dura
 
 // connect calls the configured connect function or the default Connect.
 func (rd *ReconnectDialer) connect(ctx context.Context, opts Options) (*Client, error) {
+	if rd.connectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rd.connectTimeout)
+		defer cancel()
+	}
 	if rd.connectFunc != nil {
 		return rd.connectFunc(ctx, opts)
 	}
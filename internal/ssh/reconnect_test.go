@@ -820,3 +820,20 @@ func TestReconnectDialer_RealSSH_DialThroughTunnel(t *testing.T) {
 		t.Errorf("echo mismatch: got %q, want %q", buf, msg)
 	}
 }
+
+func TestReconnectDialer_ConnectTimeout(t *testing.T) {
+	stall := func(ctx context.Context, opts Options) (*Client, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	_, err := NewReconnectDialer(context.Background(), Options{Host: "stalled"},
+		withConnectFunc(stall), WithConnectTimeout(50*time.Millisecond))
+	if err == nil {
+		t.Fatal("expected connect timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("connect took %v", elapsed)
+	}
+}
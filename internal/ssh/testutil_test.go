@@ -34,7 +34,8 @@ type testSSHServer struct {
 }
 
 // newTestSSHServer creates and starts a test SSH server on a random local port.
-func newTestSSHServer(t *testing.T) *testSSHServer {
+// Options may adjust the server config, e.g. to require public key auth.
+func newTestSSHServer(t *testing.T, opts ...func(*gossh.ServerConfig)) *testSSHServer {
 	t.Helper()
 
 	_, privKey, err := ed25519.GenerateKey(rand.Reader)
@@ -50,6 +51,9 @@ func newTestSSHServer(t *testing.T) *testSSHServer {
 		NoClientAuth: true,
 	}
 	config.AddHostKey(hostSigner)
+	for _, opt := range opts {
+		opt(config)
+	}
 
 	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
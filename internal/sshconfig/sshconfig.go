@@ -0,0 +1,378 @@
+// Package sshconfig reads the subset of OpenSSH client configuration
+// (~/.ssh/config) that xsql uses to resolve ssh_proxies entries: HostName,
+// User, Port, IdentityFile, IdentityAgent, ProxyJump and UserKnownHostsFile.
+//
+// Host blocks (with *, ? and ! patterns) and Include are supported. Match
+// blocks are skipped, except "Match all". As with ssh, the first value found
+// for a keyword wins.
+package sshconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxIncludeDepth bounds nested Include directives, as ssh does.
+const maxIncludeDepth = 16
+
+// Host holds the settings that apply to one host alias.
+type Host struct {
+	Alias              string
+	HostName           string // the alias itself when not configured
+	Port               int    // 0 when not configured
+	User               string
+	IdentityFile       string // the first IdentityFile
+	IdentityAgent      string
+	ProxyJump          string // raw value, e.g. "bastion,admin@gw:2222"
+	UserKnownHostsFile string // the first file listed
+}
+
+// Config is a parsed ssh_config file.
+type Config struct {
+	Path    string
+	entries []entry
+}
+
+type entry struct {
+	patterns []string // nil applies to every host; see neverMatch
+	key      string   // lower-case keyword
+	args     []string
+}
+
+// neverMatch marks entries in unsupported Match blocks.
+var neverMatch = []string{}
+
+// DefaultPath returns ~/.ssh/config.
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join("~", ".ssh", "config")
+	}
+	return filepath.Join(home, ".ssh", "config")
+}
+
+// Load parses the ssh_config file at path.
+func Load(path string) (*Config, error) {
+	c := &Config{Path: path}
+	if err := c.parse(path, nil, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) parse(path string, patterns []string, depth int) error {
+	f, err := os.Open(path) // nolint:gosec // user ssh configuration
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		key, args, err := splitLine(sc.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		switch key {
+		case "":
+			continue
+		case "host":
+			patterns = args
+		case "match":
+			patterns = neverMatch
+			if len(args) == 1 && strings.EqualFold(args[0], "all") {
+				patterns = nil
+			}
+		case "include":
+			if depth >= maxIncludeDepth {
+				return fmt.Errorf("%s:%d: too many nested includes", path, lineNo)
+			}
+			for _, arg := range args {
+				matches, err := filepath.Glob(includePath(arg))
+				if err != nil {
+					return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+				}
+				for _, m := range matches {
+					if err := c.parse(m, patterns, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+		default:
+			c.entries = append(c.entries, entry{patterns: patterns, key: key, args: args})
+		}
+	}
+	return sc.Err()
+}
+
+// includePath resolves an Include argument; relative paths are relative to ~/.ssh.
+func includePath(p string) string {
+	p = expandHome(p)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(filepath.Dir(DefaultPath()), p)
+	}
+	return p
+}
+
+// splitLine returns the lower-case keyword and the arguments of a line, which
+// is "Keyword value..." or "Keyword=value...". Arguments may be double-quoted.
+func splitLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return "", nil, fmt.Errorf("missing value for %s", line)
+	}
+	key := strings.ToLower(line[:i])
+	rest := strings.TrimLeft(line[i:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var (
+		args   []string
+		cur    strings.Builder
+		quoted bool
+		inArg  bool
+	)
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quoted {
+		return "", nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("missing value for %s", key)
+	}
+	return key, args, nil
+}
+
+// Lookup returns the settings for alias. Tokens %h, %p, %r, %u, %d and %% are
+// expanded in HostName, IdentityFile, IdentityAgent and UserKnownHostsFile.
+func (c *Config) Lookup(alias string) (Host, error) {
+	h := Host{Alias: alias}
+	seen := map[string]bool{}
+	for _, e := range c.entries {
+		if seen[e.key] || !matchHost(e.patterns, alias) {
+			continue
+		}
+		value := e.args[0]
+		switch e.key {
+		case "hostname":
+			h.HostName = value
+		case "user":
+			h.User = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil || port <= 0 || port > 65535 {
+				return Host{}, fmt.Errorf("%s: invalid Port %q for host %s", c.Path, value, alias)
+			}
+			h.Port = port
+		case "identityfile":
+			h.IdentityFile = value
+		case "identityagent":
+			h.IdentityAgent = value
+		case "proxyjump":
+			h.ProxyJump = value
+		case "userknownhostsfile":
+			h.UserKnownHostsFile = value
+		default:
+			continue
+		}
+		seen[e.key] = true
+	}
+
+	if h.HostName == "" {
+		h.HostName = alias
+	} else {
+		h.HostName = expandTokens(h.HostName, map[byte]string{'h': alias})
+	}
+	tokens := map[byte]string{
+		'h': h.HostName,
+		'p': strconv.Itoa(portOrDefault(h.Port)),
+		'r': h.User,
+		'u': localUser(),
+		'd': homeDir(),
+	}
+	h.IdentityFile = expandTokens(h.IdentityFile, tokens)
+	h.IdentityAgent = expandTokens(h.IdentityAgent, tokens)
+	h.UserKnownHostsFile = expandTokens(h.UserKnownHostsFile, tokens)
+	return h, nil
+}
+
+// matchHost reports whether alias matches a Host line: at least one pattern
+// matches and no negated pattern does.
+func matchHost(patterns []string, alias string) bool {
+	if patterns == nil {
+		return true
+	}
+	matched := false
+	for _, p := range patterns {
+		if neg, ok := strings.CutPrefix(p, "!"); ok {
+			if MatchPattern(neg, alias) {
+				return false
+			}
+			continue
+		}
+		if MatchPattern(p, alias) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// MatchPattern reports whether s matches an ssh host pattern of literal
+// characters, * and ?, ignoring case.
+func MatchPattern(pattern, s string) bool {
+	return wildcard(strings.ToLower(pattern), strings.ToLower(s))
+}
+
+// wildcard matches s against a pattern of literal characters, * and ?.
+func wildcard(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if wildcard(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		default:
+			if s == "" || pattern[0] != s[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return s == ""
+}
+
+func expandTokens(s string, tokens map[byte]string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if s[i] == '%' {
+			b.WriteByte('%')
+		} else if v, ok := tokens[s[i]]; ok {
+			b.WriteString(v)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func portOrDefault(port int) int {
+	if port == 0 {
+		return 22
+	}
+	return port
+}
+
+func localUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func homeDir() string {
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return p
+}
+
+// Jump is one hop of a ProxyJump value.
+type Jump struct {
+	User string
+	Host string // host name or alias
+	Port int    // 0 when not given
+}
+
+// ParseProxyJump splits a ProxyJump value such as "bastion,admin@gw:2222" or
+// "ssh://admin@gw:2222" into hops, first hop first. "none" yields no hops.
+func ParseProxyJump(value string) ([]Jump, error) {
+	if value == "" || strings.EqualFold(value, "none") {
+		return nil, nil
+	}
+	var hops []Jump
+	for _, spec := range strings.Split(value, ",") {
+		spec = strings.TrimPrefix(strings.TrimSpace(spec), "ssh://")
+		var j Jump
+		if at := strings.LastIndex(spec, "@"); at >= 0 {
+			j.User, spec = spec[:at], spec[at+1:]
+		}
+		host, port := spec, ""
+		if strings.HasPrefix(spec, "[") {
+			// [ipv6]:port
+			end := strings.Index(spec, "]")
+			if end < 0 || (end+1 < len(spec) && spec[end+1] != ':') {
+				return nil, fmt.Errorf("invalid ProxyJump %q", value)
+			}
+			host = spec[1:end]
+			if end+1 < len(spec) {
+				port = spec[end+2:]
+			}
+		} else if i := strings.LastIndex(spec, ":"); i >= 0 {
+			host, port = spec[:i], spec[i+1:]
+		}
+		j.Host = host
+		if port != "" {
+			n, err := strconv.Atoi(port)
+			if err != nil || n <= 0 || n > 65535 {
+				return nil, fmt.Errorf("invalid port in ProxyJump %q", value)
+			}
+			j.Port = n
+		}
+		if j.Host == "" {
+			return nil, fmt.Errorf("invalid ProxyJump %q", value)
+		}
+		hops = append(hops, j)
+	}
+	return hops, nil
+}
@@ -0,0 +1,133 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookup(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	sshDir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(filepath.Join(sshDir, "conf.d"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, filepath.Join(sshDir, "conf.d"), "work.conf", `Host *.work
+  User worker
+  ProxyJump gw.work
+`)
+	path := writeConfig(t, sshDir, "config", `# comment
+Include conf.d/*.conf
+
+Host prod !prod-legacy prod-*
+  HostName %h.db.internal
+  Port=2222
+  IdentityFile "~/.ssh/id %r"
+  IdentityFile ~/.ssh/id_ignored
+
+Match exec "true"
+  User matched
+
+Host *
+  User fallback
+  UserKnownHostsFile ~/.ssh/known_hosts_%h ~/.ssh/known_hosts2
+  IdentityAgent %d/agent.sock
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alias string
+		want  Host
+	}{
+		{"prod-eu", Host{
+			Alias:              "prod-eu",
+			HostName:           "prod-eu.db.internal",
+			Port:               2222,
+			User:               "fallback",
+			IdentityFile:       "~/.ssh/id fallback",
+			IdentityAgent:      home + "/agent.sock",
+			UserKnownHostsFile: "~/.ssh/known_hosts_prod-eu.db.internal",
+		}},
+		{"prod-legacy", Host{
+			Alias:              "prod-legacy",
+			HostName:           "prod-legacy",
+			User:               "fallback",
+			IdentityAgent:      home + "/agent.sock",
+			UserKnownHostsFile: "~/.ssh/known_hosts_prod-legacy",
+		}},
+		{"ci.work", Host{
+			Alias:              "ci.work",
+			HostName:           "ci.work",
+			User:               "worker",
+			ProxyJump:          "gw.work",
+			IdentityAgent:      home + "/agent.sock",
+			UserKnownHostsFile: "~/.ssh/known_hosts_ci.work",
+		}},
+	}
+	for _, tt := range tests {
+		got, err := cfg.Lookup(tt.alias)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.alias, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lookup(%s) =\n%+v\nwant\n%+v", tt.alias, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Load(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing file: %v", err)
+	}
+	if _, err := Load(writeConfig(t, dir, "quote", "Host a\n  User \"bob\n")); err == nil {
+		t.Error("expected unterminated quote error")
+	}
+	cfg, err := Load(writeConfig(t, dir, "port", "Host a\n  Port ssh\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.Lookup("a"); err == nil {
+		t.Error("expected invalid port error")
+	}
+}
+
+func TestParseProxyJump(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []Jump
+		wantErr bool
+	}{
+		{in: "none"},
+		{in: "bastion", want: []Jump{{Host: "bastion"}}},
+		{in: "admin@gw:2222, edge", want: []Jump{{User: "admin", Host: "gw", Port: 2222}, {Host: "edge"}}},
+		{in: "ssh://ops@[fe80::1]:22", want: []Jump{{User: "ops", Host: "fe80::1", Port: 22}}},
+		{in: "gw:http", wantErr: true},
+		{in: "admin@", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseProxyJump(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseProxyJump(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseProxyJump(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -63,10 +63,6 @@ func FormatTableResult(result *db.QueryResult, colOffset int, rowOffset int, ter
 		return lipgloss.NewStyle().Foreground(MutedColor).Italic(true).Render("(0 rows returned)")
 	}
 
-	if termWidth <= 20 {
-		termWidth = 80
-	}
-
 	totalRows := len(result.Rows)
 	if rowOffset >= totalRows {
 		rowOffset = (totalRows - 1) / PageRowSize * PageRowSize
@@ -75,7 +71,6 @@ func FormatTableResult(result *db.QueryResult, colOffset int, rowOffset int, ter
 		rowOffset = 0
 	}
 
-	// Calculate maximum display width needed for each column using runewidth for CJK support
 	totalCols := len(result.Columns)
 	if colOffset >= totalCols {
 		colOffset = totalCols - 1
@@ -84,43 +79,11 @@ func FormatTableResult(result *db.QueryResult, colOffset int, rowOffset int, ter
 		colOffset = 0
 	}
 
-	colWidths := make([]int, totalCols)
-	for i, col := range result.Columns {
-		w := runewidth.StringWidth(col)
-		if w > MaxColumnWidth {
-			w = MaxColumnWidth
-		}
-		// Inspect current page rows for width calculation
-		endR := rowOffset + PageRowSize
-		if endR > totalRows {
-			endR = totalRows
-		}
-		for r := rowOffset; r < endR; r++ {
-			val := result.Rows[r][col]
-			if val != nil {
-				cellStr := fmt.Sprintf("%v", val)
-				cellStr = strings.ReplaceAll(cellStr, "\n", " ")
-				dispLen := runewidth.StringWidth(cellStr)
-				if dispLen > w {
-					w = dispLen
-				}
-			}
-		}
-		if w > MaxColumnWidth {
-			w = MaxColumnWidth
-		}
-		if w < 6 {
-			w = 6
-		}
-		// Add padding (2 chars) + border (1 char)
-		colWidths[i] = w + 3
-	}
+	// Calculate maximum display width needed for each column using runewidth for CJK support
+	colWidths := pageColumnWidths(result, rowOffset)
 
 	// Determine visible column range [startCol, endCol) that fits within termWidth - 8
-	availWidth := termWidth - 8
-	if availWidth < 30 {
-		availWidth = 30
-	}
+	availWidth := tableAvailWidth(termWidth)
 
 	startCol := colOffset
 	endCol := startCol
@@ -223,6 +186,69 @@ func FormatTableResult(result *db.QueryResult, colOffset int, rowOffset int, ter
 	return sb.String()
 }
 
+// pageColumnWidths calculates the display width (including padding and border) of each column
+// for the page starting at rowOffset, using runewidth for CJK support.
+func pageColumnWidths(result *db.QueryResult, rowOffset int) []int {
+	totalRows := len(result.Rows)
+	colWidths := make([]int, len(result.Columns))
+	for i, col := range result.Columns {
+		w := runewidth.StringWidth(col)
+		if w > MaxColumnWidth {
+			w = MaxColumnWidth
+		}
+		// Inspect current page rows for width calculation
+		endR := rowOffset + PageRowSize
+		if endR > totalRows {
+			endR = totalRows
+		}
+		for r := rowOffset; r < endR; r++ {
+			val := result.Rows[r][col]
+			if val != nil {
+				cellStr := fmt.Sprintf("%v", val)
+				cellStr = strings.ReplaceAll(cellStr, "\n", " ")
+				dispLen := runewidth.StringWidth(cellStr)
+				if dispLen > w {
+					w = dispLen
+				}
+			}
+		}
+		if w > MaxColumnWidth {
+			w = MaxColumnWidth
+		}
+		if w < 6 {
+			w = 6
+		}
+		// Add padding (2 chars) + border (1 char)
+		colWidths[i] = w + 3
+	}
+	return colWidths
+}
+
+// tableAvailWidth returns the width available to table columns inside the viewport.
+func tableAvailWidth(termWidth int) int {
+	if termWidth <= 20 {
+		termWidth = 80
+	}
+	availWidth := termWidth - 8
+	if availWidth < 30 {
+		availWidth = 30
+	}
+	return availWidth
+}
+
+// ExceedsTermWidth reports whether the first page of a result cannot show all columns
+// within termWidth, in which case the expanded (vertical) view is easier to read.
+func ExceedsTermWidth(result *db.QueryResult, termWidth int) bool {
+	if result == nil || len(result.Columns) == 0 || len(result.Rows) == 0 {
+		return false
+	}
+	total := 0
+	for _, w := range pageColumnWidths(result, 0) {
+		total += w
+	}
+	return total > tableAvailWidth(termWidth)
+}
+
 // FormatVerticalResult renders SQL query results in full vertical (psql \x expanded) format with NO truncation.
 func FormatVerticalResult(result *db.QueryResult) string {
 	if result == nil || len(result.Columns) == 0 {
